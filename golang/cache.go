@@ -0,0 +1,90 @@
+package jhon
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"sync"
+	"sync/atomic"
+)
+
+// maxParseCacheEntries bounds ParseCached's memory. When the cache is full it
+// is emptied and starts over, which keeps the common case (a handful of
+// embedded defaults parsed over and over) fast without an LRU.
+const maxParseCacheEntries = 256
+
+// parseCacheGen is one generation of ParseCached's cache. A full
+// generation is replaced by a fresh one rather than emptied in place, so a
+// store that races the flush lands in one generation or the other.
+type parseCacheGen struct {
+	entries sync.Map // [sha256.Size]byte → Value
+	size    atomic.Int64
+}
+
+var parseCache atomic.Pointer[parseCacheGen]
+
+func init() {
+	parseCache.Store(&parseCacheGen{})
+}
+
+// ParseCached is Parse memoized by a hash of the input. It is safe for
+// concurrent use. Every call returns a deep clone of the cached tree, so
+// callers may mutate the result freely. Inputs that fail to parse are not
+// cached.
+func ParseCached(input string) (Value, error) {
+	key := sha256.Sum256([]byte(input))
+	gen := parseCache.Load()
+	if v, ok := gen.entries.Load(key); ok {
+		return cloneValue(v), nil
+	}
+	v, err := Parse(input)
+	if err != nil {
+		return nil, err
+	}
+	// Concurrent misses on the same input all parse it, but only the first
+	// stores and counts it.
+	if cached, loaded := gen.entries.LoadOrStore(key, v); loaded {
+		return cloneValue(cached), nil
+	}
+	if gen.size.Add(1) > maxParseCacheEntries {
+		fresh := &parseCacheGen{}
+		fresh.entries.Store(key, v)
+		fresh.size.Store(1)
+		parseCache.CompareAndSwap(gen, fresh)
+	}
+	return cloneValue(v), nil
+}
+
+// cloneValue deep-copies containers and mutable numeric types; other
+// scalars are immutable and shared.
+func cloneValue(v Value) Value {
+	switch val := v.(type) {
+	case Object:
+		if val == nil {
+			return val
+		}
+		out := make(Object, len(val))
+		for k, inner := range val {
+			out[k] = cloneValue(inner)
+		}
+		return out
+	case Array:
+		if val == nil {
+			return val
+		}
+		out := make(Array, len(val))
+		for i, inner := range val {
+			out[i] = cloneValue(inner)
+		}
+		return out
+	case *big.Int:
+		return new(big.Int).Set(val)
+	case *big.Float:
+		return new(big.Float).Copy(val)
+	case []byte:
+		if val == nil {
+			return val
+		}
+		return append([]byte{}, val...)
+	}
+	return v
+}
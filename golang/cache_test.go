@@ -0,0 +1,105 @@
+package jhon
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestParseCachedMatchesParse(t *testing.T) {
+	want, err := Parse(mediumJHON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		got, err := ParseCached(mediumJHON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("call %d: got %#v want %#v", i, got, want)
+		}
+	}
+}
+
+func TestParseCachedReturnsClones(t *testing.T) {
+	first, err := ParseCached(`server={host="x"}, tags=["a"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first.(Object)["server"].(Object)["host"] = "mutated"
+	first.(Object)["tags"].(Array)[0] = "mutated"
+
+	second, err := ParseCached(`server={host="x"}, tags=["a"]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Object{"server": Object{"host": "x"}, "tags": Array{"a"}}
+	if !reflect.DeepEqual(second, want) {
+		t.Fatalf("cache was mutated: got %#v", second)
+	}
+}
+
+func TestParseCachedReturnsErrors(t *testing.T) {
+	for i := 0; i < 2; i++ {
+		if _, err := ParseCached(`a=`); err == nil {
+			t.Fatal("expected error")
+		}
+	}
+}
+
+func TestParseCachedConcurrent(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				v, err := ParseCached(smallJHON)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				v.(Object)["name"] = "changed"
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestParseCachedCountsConcurrentMissesOnce(t *testing.T) {
+	parseCache.Store(&parseCacheGen{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := ParseCached(smallJHON); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if n := parseCache.Load().size.Load(); n != 1 {
+		t.Fatalf("one input counted %d times", n)
+	}
+}
+
+func TestParseCachedFlushesWhenFull(t *testing.T) {
+	parseCache.Store(&parseCacheGen{})
+	for i := 0; i <= maxParseCacheEntries; i++ {
+		if _, err := ParseCached(fmt.Sprintf("n=%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	gen := parseCache.Load()
+	if n := gen.size.Load(); n != 1 {
+		t.Fatalf("after the flush: %d entries counted", n)
+	}
+	last := sha256.Sum256([]byte(fmt.Sprintf("n=%d", maxParseCacheEntries)))
+	if _, ok := gen.entries.Load(last); !ok {
+		t.Fatal("the entry that filled the cache was not kept")
+	}
+}
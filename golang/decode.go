@@ -0,0 +1,594 @@
+package jhon
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ============================================================================
+// Unmarshal — maps a parsed Value tree onto Go values via reflection.
+//
+// Struct fields match object keys by their `jhon:"name"` tag, falling back to
+// the field name compared case-insensitively (as encoding/json does). A tag
+// of `jhon:"-"` skips the field. Embedded structs without a tag have their
+// fields promoted. Maps with string keys take every key of an object; slices
+// and Go arrays take the elements of an array.
+// ============================================================================
+
+// DecodeOptions controls how Unmarshal maps a parsed document onto Go values.
+type DecodeOptions struct {
+	// DisallowUnknownFields makes decoding into a struct fail with an
+	// *UnknownFieldError when the input has a key with no matching field.
+	// This catches typos in config keys.
+	DisallowUnknownFields bool
+	// TypeField names the key that selects the concrete type of an object
+	// decoded into a non-empty interface type; see RegisterType. Defaults
+	// to "type".
+	TypeField string
+}
+
+// InvalidUnmarshalError is returned when Unmarshal is given a nil or
+// non-pointer target.
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "jhon: Unmarshal(nil)"
+	}
+	if e.Type.Kind() != reflect.Ptr {
+		return "jhon: Unmarshal(non-pointer " + e.Type.String() + ")"
+	}
+	return "jhon: Unmarshal(nil " + e.Type.String() + ")"
+}
+
+// UnmarshalTypeError reports a JHON value that cannot be stored in the Go
+// type at Path.
+type UnmarshalTypeError struct {
+	Path  string // e.g. "server.ports[1]"; empty for the document root
+	Value string // kind of the JHON value: "object", "string", "number", ...
+	Type  reflect.Type
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("jhon: cannot unmarshal %s into Go value of type %s", e.Value, e.Type)
+	}
+	return fmt.Sprintf("jhon: cannot unmarshal %s into Go value of type %s at %s", e.Value, e.Type, e.Path)
+}
+
+// UnknownFieldError is returned under DecodeOptions.DisallowUnknownFields
+// for an input key that matches no struct field. Line and Column locate the
+// key in the source; they are zero when the tree did not come from text.
+type UnknownFieldError struct {
+	Key    string
+	Path   string
+	Line   int
+	Column int
+}
+
+func (e *UnknownFieldError) Error() string {
+	if e.Line == 0 {
+		return fmt.Sprintf("jhon: unknown field %q", e.Path)
+	}
+	return fmt.Sprintf("jhon: unknown field %q at %d:%d", e.Path, e.Line, e.Column)
+}
+
+// UnregisteredTypeError is returned when an object decoded into an interface
+// type has no TypeField key, or names a type that was not registered with
+// RegisterType or does not implement the interface.
+type UnregisteredTypeError struct {
+	Path  string // e.g. "middleware[1]"; empty for the document root
+	Field string // the DecodeOptions.TypeField key
+	Name  string // the type name found, or "" when the key was missing
+	Type  reflect.Type
+}
+
+func (e *UnregisteredTypeError) Error() string {
+	at := ""
+	if e.Path != "" {
+		at = " at " + e.Path
+	}
+	if e.Name == "" {
+		return fmt.Sprintf("jhon: object%s has no %q string to select a %s", at, e.Field, e.Type)
+	}
+	return fmt.Sprintf("jhon: %q%s is not a registered type implementing %s", e.Name, at, e.Type)
+}
+
+var (
+	typeRegistryMu sync.RWMutex
+	typeRegistry   = map[string]reflect.Type{}
+)
+
+// RegisterType makes name select the type of proto when Unmarshal decodes
+// an object into a non-empty interface type, so a list of differently
+// shaped entries can decode into a []Middleware:
+//
+//	jhon.RegisterType("gzip", &Gzip{})
+//	jhon.RegisterType("auth", &Auth{})
+//
+//	middleware = [
+//	  { type = "gzip", level = 5 }
+//	  { type = "auth", realm = "admin" }
+//	]
+//
+// The value stored has proto's type, so register a pointer when the
+// pointer type implements the interface. The key that names the type
+// ("type" unless DecodeOptions.TypeField says otherwise) is not treated as
+// an unknown field. Like gob.Register, RegisterType is meant to be called
+// from init functions, and it panics if name is already registered with a
+// different type.
+func RegisterType(name string, proto interface{}) {
+	t := reflect.TypeOf(proto)
+	if t == nil {
+		panic("jhon: RegisterType with nil proto")
+	}
+	typeRegistryMu.Lock()
+	defer typeRegistryMu.Unlock()
+	if prev, ok := typeRegistry[name]; ok && prev != t {
+		panic(fmt.Sprintf("jhon: RegisterType: %q already registered as %s", name, prev))
+	}
+	typeRegistry[name] = t
+}
+
+// Unmarshal parses a JHON document and stores the result in the value
+// pointed to by v.
+func Unmarshal(input string, v interface{}) error {
+	return UnmarshalWithOptions(input, v, DecodeOptions{})
+}
+
+// UnmarshalWithOptions is Unmarshal with decode options.
+func UnmarshalWithOptions(input string, v interface{}, opts DecodeOptions) error {
+	rv, err := decodeTarget(v)
+	if err != nil {
+		return err
+	}
+	p := newParser([]byte(input))
+	p.keyPos = map[string]nodePos{}
+	tree, err := p.parseDocument()
+	if err != nil {
+		return err
+	}
+	d := &decoder{opts: opts, keyPos: p.keyPos}
+	return d.decode(tree, rv)
+}
+
+// decodeTarget checks that v is a non-nil pointer and returns its element.
+func decodeTarget(v interface{}) (reflect.Value, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return reflect.Value{}, &InvalidUnmarshalError{Type: reflect.TypeOf(v)}
+	}
+	return rv.Elem(), nil
+}
+
+// ParseInto parses a JHON document straight into a new T, e.g.
+// `cfg, err := jhon.ParseInto[ServerConfig](text)`. On failure it returns the
+// zero T along with the error.
+func ParseInto[T any](input string) (T, error) {
+	var v T
+	if err := Unmarshal(input, &v); err != nil {
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
+
+// Decode stores o in the value pointed to by v with the same field
+// matching and conversions as Unmarshal, without serializing o back to
+// text first. Use it for a sub-object of a parsed document, or a tree built
+// or edited in memory:
+//
+//	var tls TLSConfig
+//	err := cfg["server"].(jhon.Object)["tls"].(jhon.Object).Decode(&tls)
+//
+// Paths in errors are relative to o. An UnknownFieldError has no line and
+// column, as o carries no source positions. Object, Array and []byte
+// targets share their contents with o rather than copying them.
+func (o Object) Decode(v interface{}) error {
+	rv, err := decodeTarget(v)
+	if err != nil {
+		return err
+	}
+	return (&decoder{}).decode(o, rv)
+}
+
+var (
+	objectType = reflect.TypeOf(Object(nil))
+	arrayType  = reflect.TypeOf(Array(nil))
+	bytesType  = reflect.TypeOf([]byte(nil))
+)
+
+type decoder struct {
+	opts   DecodeOptions
+	keyPos map[string]nodePos
+	path   []pathSeg
+	// typeKey is the TypeField key of the object about to be decoded into
+	// a registered type, which is not an unknown field there.
+	typeKey string
+}
+
+func (d *decoder) typeErr(v Value, t reflect.Type) error {
+	return &UnmarshalTypeError{Path: formatPath(d.path), Value: describeValue(v), Type: t}
+}
+
+func (d *decoder) decode(v Value, rv reflect.Value) error {
+	if v == nil {
+		// null clears nillable targets and leaves everything else untouched.
+		switch rv.Kind() {
+		case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+			rv.Set(reflect.Zero(rv.Type()))
+		}
+		return nil
+	}
+
+	switch rv.Type() {
+	case bytesType:
+		if _, ok := v.(Array); ok {
+			break // byte values, as Serialize writes a []byte by default
+		}
+		fallthrough
+	case objectType, arrayType:
+		if reflect.TypeOf(v) != rv.Type() {
+			return d.typeErr(v, rv.Type())
+		}
+		rv.Set(reflect.ValueOf(v))
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Interface:
+		if rv.NumMethod() != 0 {
+			obj, ok := v.(Object)
+			if !ok {
+				return d.typeErr(v, rv.Type())
+			}
+			return d.decodeRegistered(obj, rv)
+		}
+		rv.Set(reflect.ValueOf(v))
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return d.decode(v, rv.Elem())
+	case reflect.Struct:
+		obj, ok := v.(Object)
+		if !ok {
+			return d.typeErr(v, rv.Type())
+		}
+		return d.decodeStruct(obj, rv)
+	case reflect.Map:
+		obj, ok := v.(Object)
+		if !ok || rv.Type().Key().Kind() != reflect.String {
+			return d.typeErr(v, rv.Type())
+		}
+		return d.decodeMap(obj, rv)
+	case reflect.Slice, reflect.Array:
+		arr, ok := v.(Array)
+		if !ok {
+			return d.typeErr(v, rv.Type())
+		}
+		return d.decodeArray(arr, rv)
+	case reflect.Bool:
+		b, ok := v.(bool)
+		if !ok {
+			return d.typeErr(v, rv.Type())
+		}
+		rv.SetBool(b)
+	case reflect.String:
+		s, ok := v.(string)
+		if !ok {
+			return d.typeErr(v, rv.Type())
+		}
+		rv.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := toInt64(v)
+		if !ok || rv.OverflowInt(i) {
+			return d.typeErr(v, rv.Type())
+		}
+		rv.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, ok := toUint64(v)
+		if !ok || rv.OverflowUint(u) {
+			return d.typeErr(v, rv.Type())
+		}
+		rv.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, ok := toFloat64(v)
+		if !ok || rv.OverflowFloat(f) {
+			return d.typeErr(v, rv.Type())
+		}
+		rv.SetFloat(f)
+	default:
+		return d.typeErr(v, rv.Type())
+	}
+	return nil
+}
+
+// decodeRegistered decodes obj into the registered type its TypeField
+// names and stores the result in the interface rv.
+func (d *decoder) decodeRegistered(obj Object, rv reflect.Value) error {
+	field := d.opts.TypeField
+	if field == "" {
+		field = "type"
+	}
+	name, _ := obj[field].(string)
+	typeRegistryMu.RLock()
+	t, ok := typeRegistry[name]
+	typeRegistryMu.RUnlock()
+	if !ok || !t.AssignableTo(rv.Type()) {
+		return &UnregisteredTypeError{Path: formatPath(d.path), Field: field, Name: name, Type: rv.Type()}
+	}
+	nv := reflect.New(t).Elem()
+	d.typeKey = field
+	err := d.decode(obj, nv)
+	d.typeKey = ""
+	if err != nil {
+		return err
+	}
+	rv.Set(nv)
+	return nil
+}
+
+func (d *decoder) decodeStruct(obj Object, rv reflect.Value) error {
+	typeKey := d.typeKey
+	d.typeKey = ""
+	fields := structFields(rv.Type())
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		d.path = append(d.path, pathSeg{key: k, index: -1})
+		f, ok := matchField(fields, k)
+		if !ok {
+			if d.opts.DisallowUnknownFields && k != typeKey {
+				path := formatPath(d.path)
+				pos := d.keyPos[path]
+				return &UnknownFieldError{Key: k, Path: path, Line: pos.line, Column: pos.col}
+			}
+			d.path = d.path[:len(d.path)-1]
+			continue
+		}
+		fv, err := d.fieldByIndexAlloc(rv, f.index)
+		if err != nil {
+			return err
+		}
+		if s, ok := obj[k].(string); ok && f.hasOption("char") && isCharKind(fv.Kind()) {
+			if !decodeChar(s, fv) {
+				return d.typeErr(obj[k], fv.Type())
+			}
+		} else if c, ok := obj[k].(Object); ok && f.hasOption("complex") && isComplexKind(fv.Kind()) {
+			if !decodeComplex(c, fv) {
+				return d.typeErr(obj[k], fv.Type())
+			}
+		} else if err := d.decode(obj[k], fv); err != nil {
+			return err
+		}
+		d.path = d.path[:len(d.path)-1]
+	}
+	return nil
+}
+
+// decodeMap stores each entry of obj in the map rv, allocating it if nil.
+// Entries already in the map are kept unless obj overwrites them.
+func (d *decoder) decodeMap(obj Object, rv reflect.Value) error {
+	t := rv.Type()
+	if rv.IsNil() {
+		rv.Set(reflect.MakeMapWithSize(t, len(obj)))
+	}
+	keys := make([]string, 0, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		d.path = append(d.path, pathSeg{key: k, index: -1})
+		elem := reflect.New(t.Elem()).Elem()
+		if err := d.decode(obj[k], elem); err != nil {
+			return err
+		}
+		rv.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), elem)
+		d.path = d.path[:len(d.path)-1]
+	}
+	return nil
+}
+
+// decodeArray fills the slice or Go array rv from arr. A slice is replaced
+// by one of len(arr); a Go array takes as many elements as fit and zeroes
+// the rest, as encoding/json does.
+func (d *decoder) decodeArray(arr Array, rv reflect.Value) error {
+	n := len(arr)
+	if rv.Kind() == reflect.Slice {
+		rv.Set(reflect.MakeSlice(rv.Type(), n, n))
+	} else if n > rv.Len() {
+		n = rv.Len()
+	}
+	for i := 0; i < n; i++ {
+		d.path = append(d.path, pathSeg{index: i})
+		if err := d.decode(arr[i], rv.Index(i)); err != nil {
+			return err
+		}
+		d.path = d.path[:len(d.path)-1]
+	}
+	for i := n; i < rv.Len(); i++ {
+		rv.Index(i).Set(reflect.Zero(rv.Type().Elem()))
+	}
+	return nil
+}
+
+// fieldByIndexAlloc is reflect.Value.FieldByIndex that allocates nil
+// embedded struct pointers on the way down. As in encoding/json, a nil
+// pointer to an unexported struct cannot be set, so reaching a field
+// through one is an error.
+func (d *decoder) fieldByIndexAlloc(rv reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				if !rv.CanSet() {
+					return reflect.Value{}, fmt.Errorf("jhon: cannot set embedded pointer to unexported struct %s at %s", rv.Type().Elem(), formatPath(d.path))
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, nil
+}
+
+// field describes one decodable struct field.
+type field struct {
+	name  string
+	index []int
+	opts  string // comma-separated tag options after the name
+}
+
+// structFields lists the exported fields of t, promoting the fields of
+// untagged embedded structs.
+func structFields(t reflect.Type) []field {
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("jhon")
+		if tag == "-" {
+			continue
+		}
+		name, opts := parseTag(tag)
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for _, inner := range structFields(ft) {
+				inner.index = append([]int{i}, inner.index...)
+				fields = append(fields, inner)
+			}
+			continue
+		}
+		if sf.PkgPath != "" {
+			continue // unexported
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, field{name: name, index: []int{i}, opts: opts})
+	}
+	return fields
+}
+
+// matchField prefers an exact name match, then a case-insensitive one.
+func matchField(fields []field, key string) (field, bool) {
+	for _, f := range fields {
+		if f.name == key {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, key) {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+func parseTag(tag string) (name, opts string) {
+	if i := strings.IndexByte(tag, ','); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
+
+// describeValue names the JHON kind of v for error messages.
+func describeValue(v Value) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case Object:
+		return "object"
+	case Array:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "bool"
+	case int64, uint64, int, float64, Number, *big.Int, *big.Float:
+		return "number"
+	case []byte:
+		return "bytes"
+	}
+	switch reflect.ValueOf(v).Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+func toInt64(v Value) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case int:
+		return int64(n), true
+	case uint64:
+		if n > 1<<63-1 {
+			return 0, false
+		}
+		return int64(n), true
+	case float64:
+		if n != float64(int64(n)) || n < -9.2e18 || n > 9.2e18 {
+			return 0, false
+		}
+		return int64(n), true
+	case Number:
+		i, err := n.Int64()
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func toUint64(v Value) (uint64, bool) {
+	switch n := v.(type) {
+	case uint64:
+		return n, true
+	case Number:
+		u, err := strconv.ParseUint(n.digits(), n.base(), 64)
+		return u, err == nil
+	case float64:
+		if n < 0 || n != float64(uint64(n)) || n > 1.8e19 {
+			return 0, false
+		}
+		return uint64(n), true
+	}
+	i, ok := toInt64(v)
+	if !ok || i < 0 {
+		return 0, false
+	}
+	return uint64(i), true
+}
+
+func toFloat64(v Value) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
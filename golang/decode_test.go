@@ -0,0 +1,341 @@
+package jhon
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type testTLS struct {
+	Enabled  bool   `jhon:"enabled"`
+	CertPath string `jhon:"cert_path"`
+}
+
+type testServer struct {
+	Host    string   `jhon:"host"`
+	Port    int      `jhon:"port"`
+	Timeout float64  `jhon:"timeout"`
+	TLS     *testTLS `jhon:"tls"`
+	Ignored string   `jhon:"-"`
+}
+
+func TestUnmarshalStruct(t *testing.T) {
+	var got testServer
+	err := Unmarshal(`host="localhost", port=8080, timeout=2.5, tls={enabled=true, cert_path="/etc/cert.pem"}`, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := testServer{
+		Host:    "localhost",
+		Port:    8080,
+		Timeout: 2.5,
+		TLS:     &testTLS{Enabled: true, CertPath: "/etc/cert.pem"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v want %#v", got, want)
+	}
+}
+
+func TestUnmarshalMatchesFieldNameCaseInsensitively(t *testing.T) {
+	var got struct {
+		Name  string
+		Count uint8
+	}
+	if err := Unmarshal(`name="x", COUNT=3`, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "x" || got.Count != 3 {
+		t.Fatalf("got %#v", got)
+	}
+}
+
+func TestUnmarshalIntoValue(t *testing.T) {
+	var got Value
+	if err := Unmarshal(`a=[1, "two"]`, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := Object{"a": Array{int64(1), "two"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v want %#v", got, want)
+	}
+}
+
+func TestUnmarshalMapOfStructs(t *testing.T) {
+	var got map[string]testServer
+	err := Unmarshal(`
+web = { host = "a", port = 80 }
+api = { host = "b", port = 8080, tls = { enabled = true } }
+`, &got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]testServer{
+		"web": {Host: "a", Port: 80},
+		"api": {Host: "b", Port: 8080, TLS: &testTLS{Enabled: true}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v want %#v", got, want)
+	}
+}
+
+func TestUnmarshalSlices(t *testing.T) {
+	var ints []int
+	if err := Unmarshal(`1, 2, 3`, &ints); err != nil || !reflect.DeepEqual(ints, []int{1, 2, 3}) {
+		t.Fatalf("got %#v, %v", ints, err)
+	}
+	var cfg struct {
+		Tags  []string          `jhon:"tags"`
+		Env   map[string]string `jhon:"env"`
+		Pair  [2]int            `jhon:"pair"`
+		Empty []int             `jhon:"empty"`
+	}
+	cfg.Pair = [2]int{7, 7}
+	err := Unmarshal(`tags=["a", "b"], env={HOME="/root"}, pair=[1], empty=[]`, &cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cfg.Tags, []string{"a", "b"}) || cfg.Env["HOME"] != "/root" || cfg.Pair != [2]int{1, 0} || cfg.Empty == nil {
+		t.Fatalf("got %#v", cfg)
+	}
+}
+
+func TestUnmarshalContainerMismatchNamesPath(t *testing.T) {
+	cases := []struct {
+		input string
+		into  interface{}
+		path  string
+	}{
+		{`web = { host = "a", port = "80" }`, new(map[string]testServer), "web.port"},
+		{`servers = [{ host = "a" }, { host = 1 }]`, new(map[string][]testServer), "servers[1].host"},
+		{`1, "two"`, new([]int), "[1]"},
+		{`a = [1]`, new(map[string]string), "a"},
+		{`a = 1`, new(map[int]int), ""},
+	}
+	for _, c := range cases {
+		err := Unmarshal(c.input, c.into)
+		var te *UnmarshalTypeError
+		if !errors.As(err, &te) || te.Path != c.path {
+			t.Errorf("%q: got %v, want error at %q", c.input, err, c.path)
+		}
+	}
+}
+
+func TestUnmarshalTypeMismatchNamesPath(t *testing.T) {
+	var got testServer
+	err := Unmarshal(`tls={enabled="yes"}`, &got)
+	var te *UnmarshalTypeError
+	if !errors.As(err, &te) {
+		t.Fatalf("expected *UnmarshalTypeError, got %v", err)
+	}
+	if te.Path != "tls.enabled" || te.Value != "string" {
+		t.Fatalf("got path %q value %q", te.Path, te.Value)
+	}
+}
+
+func TestUnmarshalOverflowIsError(t *testing.T) {
+	var got struct{ N int8 }
+	if err := Unmarshal(`n=300`, &got); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestUnmarshalNonPointerIsError(t *testing.T) {
+	var got testServer
+	var ie *InvalidUnmarshalError
+	if err := Unmarshal(`host="x"`, got); !errors.As(err, &ie) {
+		t.Fatalf("expected *InvalidUnmarshalError, got %v", err)
+	}
+}
+
+func TestUnmarshalIgnoresUnknownFieldsByDefault(t *testing.T) {
+	var got testServer
+	if err := Unmarshal(`host="x", prot=80`, &got); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDisallowUnknownFields(t *testing.T) {
+	var got testServer
+	err := UnmarshalWithOptions("host=\"x\"\ntls={\n  enabeld=true\n}", &got, DecodeOptions{DisallowUnknownFields: true})
+	var ue *UnknownFieldError
+	if !errors.As(err, &ue) {
+		t.Fatalf("expected *UnknownFieldError, got %v", err)
+	}
+	if ue.Key != "enabeld" || ue.Path != "tls.enabeld" {
+		t.Fatalf("got key %q path %q", ue.Key, ue.Path)
+	}
+	if ue.Line != 3 || ue.Column != 3 {
+		t.Fatalf("got %d:%d, want 3:3", ue.Line, ue.Column)
+	}
+}
+
+type testListen struct {
+	Port int `jhon:"port"`
+}
+
+type testEmbedded struct {
+	*TestExported
+	*testListen
+	Name string `jhon:"name"`
+}
+
+type TestExported struct {
+	Debug bool `jhon:"debug"`
+}
+
+func TestUnmarshalEmbeddedPointers(t *testing.T) {
+	var got testEmbedded
+	if err := Unmarshal(`name="x", debug=true`, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "x" || got.TestExported == nil || !got.Debug || got.testListen != nil {
+		t.Fatalf("got %#v", got)
+	}
+	// A nil pointer to an unexported struct cannot be allocated.
+	got = testEmbedded{}
+	err := Unmarshal(`port=80`, &got)
+	if err == nil || err.Error() != "jhon: cannot set embedded pointer to unexported struct jhon.testListen at port" {
+		t.Fatalf("got %v", err)
+	}
+	// One the caller allocated is filled in.
+	got = testEmbedded{testListen: &testListen{}}
+	if err := Unmarshal(`port=80`, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Port != 80 {
+		t.Fatalf("got %#v", got.testListen)
+	}
+}
+
+func TestParseInto(t *testing.T) {
+	got, err := ParseInto[testServer](`host="db", port=5432`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Host != "db" || got.Port != 5432 {
+		t.Fatalf("got %#v", got)
+	}
+}
+
+func TestParseIntoReturnsZeroOnError(t *testing.T) {
+	got, err := ParseInto[testServer](`host="db", port="x"`)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !reflect.DeepEqual(got, testServer{}) {
+		t.Fatalf("expected zero value, got %#v", got)
+	}
+}
+
+func TestObjectDecode(t *testing.T) {
+	obj := Object{
+		"host":    "localhost",
+		"port":    int64(8080),
+		"timeout": 2.5,
+		"tls":     Object{"enabled": true, "cert_path": "/etc/cert.pem"},
+	}
+	var got testServer
+	if err := obj.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	want := testServer{
+		Host:    "localhost",
+		Port:    8080,
+		Timeout: 2.5,
+		TLS:     &testTLS{Enabled: true, CertPath: "/etc/cert.pem"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v want %#v", got, want)
+	}
+}
+
+func TestObjectDecodeSubObject(t *testing.T) {
+	v, err := Parse(`servers = { main = { host = "a", port = 1, tls = { enabled = true } } }`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	main := v.(Object)["servers"].(Object)["main"].(Object)
+	var got testServer
+	if err := main.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Host != "a" || got.Port != 1 || got.TLS == nil || !got.TLS.Enabled {
+		t.Fatalf("got %#v", got)
+	}
+
+	err = Object{"tls": Object{"enabled": "yes"}}.Decode(&got)
+	var typeErr *UnmarshalTypeError
+	if !errors.As(err, &typeErr) || typeErr.Path != "tls.enabled" {
+		t.Fatalf("expected UnmarshalTypeError at tls.enabled, got %v", err)
+	}
+	var invalid *InvalidUnmarshalError
+	if err := main.Decode(got); !errors.As(err, &invalid) {
+		t.Fatalf("expected InvalidUnmarshalError, got %v", err)
+	}
+}
+
+type testMiddleware interface{ Name() string }
+
+type testGzip struct {
+	Level int `jhon:"level"`
+}
+
+type testAuth struct {
+	Realm string `jhon:"realm"`
+}
+
+func (*testGzip) Name() string { return "gzip" }
+func (testAuth) Name() string  { return "auth" }
+
+func init() {
+	RegisterType("test-gzip", &testGzip{})
+	RegisterType("test-auth", testAuth{})
+}
+
+func TestUnmarshalRegisteredTypes(t *testing.T) {
+	var cfg struct {
+		Middleware []testMiddleware `jhon:"middleware"`
+		Main       testMiddleware   `jhon:"main"`
+	}
+	input := `
+middleware = [
+  { type = "test-gzip", level = 5 }
+  { type = "test-auth", realm = "admin" }
+]
+main = { type = "test-auth", realm = "root" }
+`
+	if err := UnmarshalWithOptions(input, &cfg, DecodeOptions{DisallowUnknownFields: true}); err != nil {
+		t.Fatal(err)
+	}
+	want := []testMiddleware{&testGzip{Level: 5}, testAuth{Realm: "admin"}}
+	if !reflect.DeepEqual(cfg.Middleware, want) || cfg.Main != (testAuth{Realm: "root"}) {
+		t.Fatalf("got %#v, %#v", cfg.Middleware, cfg.Main)
+	}
+
+	var list []testMiddleware
+	err := UnmarshalWithOptions(`{ "@type" = "test-gzip", level = 1 }`, &list, DecodeOptions{TypeField: "@type"})
+	if err != nil || !reflect.DeepEqual(list, []testMiddleware{&testGzip{Level: 1}}) {
+		t.Fatalf("TypeField: got %#v, %v", list, err)
+	}
+}
+
+func TestUnmarshalRegisteredTypeErrors(t *testing.T) {
+	var list []testMiddleware
+	cases := map[string]string{
+		`{ level = 1 }`:       `jhon: object at [0] has no "type" string to select a jhon.testMiddleware`,
+		`{ type = "brotli" }`: `jhon: "brotli" at [0] is not a registered type implementing jhon.testMiddleware`,
+	}
+	for input, want := range cases {
+		if err := Unmarshal(input, &list); err == nil || err.Error() != want {
+			t.Errorf("%q: got %v", input, err)
+		}
+	}
+	var te *UnmarshalTypeError
+	if err := Unmarshal(`{ type = "test-gzip" }, "x"`, &list); !errors.As(err, &te) || te.Path != "[1]" {
+		t.Errorf("non-object: got %v", err)
+	}
+	var ue *UnknownFieldError
+	if err := UnmarshalWithOptions(`{ type = "test-gzip", levle = 1 }`, &list, DecodeOptions{DisallowUnknownFields: true}); !errors.As(err, &ue) || ue.Path != "[0].levle" {
+		t.Errorf("unknown field: got %v", err)
+	}
+}
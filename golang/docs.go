@@ -0,0 +1,70 @@
+package jhon
+
+import (
+	"bytes"
+	"strings"
+)
+
+// ============================================================================
+// Doc comments
+// ============================================================================
+
+// Docs maps key paths, as rendered in error messages (`server.ports`), to
+// the doc comment written above each key.
+type Docs map[string]string
+
+// ParseWithDocs is ParseWithOptions that also collects doc comments: `///`
+// lines directly above a key, each on a line of its own, document that key.
+// A tool can turn them into a reference table of every setting:
+//
+//	/// Port the server listens on.
+//	/// Must be free at startup.
+//	port = 8080
+//
+// yields docs["port"] == "Port the server listens on.\nMust be free at
+// startup.". One space after `///` is dropped. A blank line, a value or any
+// other content between the doc comment and the key detaches it; plain `//`
+// and block comments are never doc comments. Keys without one are absent
+// from the map.
+func ParseWithDocs(input string, opts ParseOptions) (Value, Docs, error) {
+	p := newParser([]byte(input))
+	p.opts = opts
+	p.docs = Docs{}
+	v, err := p.parseDocument()
+	if err != nil {
+		return nil, nil, err
+	}
+	return v, p.docs, nil
+}
+
+// noteDocComment looks at the line comment that started at start and ends
+// at the current position, and adds it to the pending doc comment if it is
+// a `///` line standing alone.
+func (p *parser) noteDocComment(start nodePos) {
+	text := p.input[start.offset:p.pos]
+	if !bytes.HasPrefix(text, []byte("///")) || bytes.HasPrefix(text, []byte("////")) {
+		return
+	}
+	lineStart := bytes.LastIndexByte(p.input[:start.offset], '\n') + 1
+	if len(bytes.TrimLeft(p.input[lineStart:start.offset], " \t")) != 0 {
+		return // trails other content
+	}
+	switch {
+	case start.line <= p.docLine:
+		return // already seen, when the parser looks ahead and backs up
+	case start.line != p.docLine+1:
+		p.docLines = p.docLines[:0]
+	}
+	line := strings.TrimRight(string(text[3:]), " \t\r")
+	p.docLines = append(p.docLines, strings.TrimPrefix(line, " "))
+	p.docLine = start.line
+}
+
+// takeDoc records the pending doc comment for the key at the current path
+// if the doc comment ends on the line above the key, which starts at start.
+func (p *parser) takeDoc(start nodePos) {
+	if len(p.docLines) > 0 && p.docLine == start.line-1 {
+		p.docs[formatPath(p.path)] = strings.Join(p.docLines, "\n")
+	}
+	p.docLines = p.docLines[:0]
+}
@@ -0,0 +1,60 @@
+package jhon
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseWithDocs(t *testing.T) {
+	input := `/// Name shown in the UI.
+name = "app"
+
+// Not a doc comment.
+debug = false
+
+/// Detached by the blank line below.
+
+server = {
+  /// Port the server listens on.
+  ///   Must be free at startup.
+  port = 8080
+  host = "x" /// trails a value, so documents nothing
+  /// Read timeout.
+  timeouts = [
+    {
+      /// Per-entry doc.
+      read = 5
+      write = 5 /// trails a value
+    }
+  ]
+}
+//// Four slashes are a plain comment.
+tags = []
+`
+	v, docs, err := ParseWithDocs(input, ParseOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := MustParse(input); !reflect.DeepEqual(v, want) {
+		t.Fatalf("value differs from Parse: %#v", v)
+	}
+	want := Docs{
+		"name":                    "Name shown in the UI.",
+		"server.port":             "Port the server listens on.\n  Must be free at startup.",
+		"server.timeouts":         "Read timeout.",
+		"server.timeouts[0].read": "Per-entry doc.",
+	}
+	if !reflect.DeepEqual(docs, want) {
+		t.Fatalf("got %#v", docs)
+	}
+}
+
+func TestParseWithDocsDottedKeys(t *testing.T) {
+	_, docs, err := ParseWithDocs("/// Listen address.\nserver.host = \"x\"", ParseOptions{DottedKeysAsNesting: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if docs["server.host"] != "Listen address." {
+		t.Fatalf("got %#v", docs)
+	}
+}
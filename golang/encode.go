@@ -0,0 +1,265 @@
+package jhon
+
+import (
+	"fmt"
+	"math/big"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// Marshal — builds a Value tree from Go values via reflection, the inverse
+// of Unmarshal. Struct fields use the same `jhon:"name"` tags.
+// ============================================================================
+
+// UnsupportedTypeError is returned by Marshal for a Go value with no JHON
+// form, such as a channel, a function or a map with non-string keys.
+type UnsupportedTypeError struct {
+	Path string
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	if e.Path == "" {
+		return "jhon: unsupported type: " + e.Type.String()
+	}
+	return fmt.Sprintf("jhon: unsupported type %s at %s", e.Type, e.Path)
+}
+
+// Marshal returns the JHON encoding of v, compact as Serialize writes it.
+// Structs become objects keyed by their `jhon` tags or field names, maps
+// with string keys become objects, and slices and arrays become arrays;
+// []byte is an array of byte values, or a byte string with
+// SerializeOptions.Base64. Nil pointers, maps and slices become null.
+//
+// Integer fields, byte and rune (int32) included, are written as numbers.
+// A `jhon:",char"` tag on a byte or rune field writes it as a
+// one-character string instead, and Unmarshal reads it back the same way:
+//
+//	type Key struct {
+//		Code rune `jhon:"code,char"` // code = "q"
+//	}
+//
+// JHON has no complex numbers, so complex64 and complex128 are unsupported
+// unless the field is tagged `jhon:",complex"`, which writes it as an
+// object of its real and imaginary parts; Unmarshal reads that shape back:
+//
+//	type Signal struct {
+//		Z complex128 `jhon:"z,complex"` // z = {im = 2, re = 1}
+//	}
+func Marshal(v interface{}) (string, error) {
+	return MarshalWithOptions(v, SerializeOptions{})
+}
+
+// MarshalWithOptions is Marshal with serialize options.
+func MarshalWithOptions(v interface{}, opts SerializeOptions) (string, error) {
+	e := &encoder{}
+	val, err := e.toValue(reflect.ValueOf(v))
+	if err != nil {
+		return "", err
+	}
+	return SerializeWithOptions(val, opts), nil
+}
+
+type encoder struct {
+	path []pathSeg
+}
+
+func (e *encoder) unsupported(t reflect.Type) error {
+	return &UnsupportedTypeError{Path: formatPath(e.path), Type: t}
+}
+
+func (e *encoder) toValue(rv reflect.Value) (Value, error) {
+	if !rv.IsValid() {
+		return nil, nil
+	}
+	switch v := rv.Interface().(type) {
+	case Number, *big.Int, *big.Float:
+		return v, nil
+	}
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		return e.toValue(rv.Elem())
+	case reflect.Struct:
+		return e.structToObject(rv)
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, e.unsupported(rv.Type())
+		}
+		if rv.IsNil() {
+			return nil, nil
+		}
+		obj := make(Object, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k := iter.Key().String()
+			e.path = append(e.path, pathSeg{key: k, index: -1})
+			val, err := e.toValue(iter.Value())
+			e.path = e.path[:len(e.path)-1]
+			if err != nil {
+				return nil, err
+			}
+			obj[k] = val
+		}
+		return obj, nil
+	case reflect.Slice:
+		if rv.IsNil() {
+			return nil, nil
+		}
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return rv.Bytes(), nil
+		}
+		fallthrough
+	case reflect.Array:
+		arr := make(Array, rv.Len())
+		for i := range arr {
+			e.path = append(e.path, pathSeg{index: i})
+			val, err := e.toValue(rv.Index(i))
+			e.path = e.path[:len(e.path)-1]
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = val
+		}
+		return arr, nil
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool:
+		return rv.Bool(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint(), nil
+	case reflect.Float32:
+		return float32(rv.Float()), nil
+	case reflect.Float64:
+		return rv.Float(), nil
+	}
+	return nil, e.unsupported(rv.Type())
+}
+
+func (e *encoder) structToObject(rv reflect.Value) (Value, error) {
+	obj := Object{}
+	for _, f := range structFields(rv.Type()) {
+		fv, ok := fieldByIndex(rv, f.index)
+		if !ok {
+			continue // behind a nil embedded pointer
+		}
+		e.path = append(e.path, pathSeg{key: f.name, index: -1})
+		var val Value
+		var err error
+		switch {
+		case f.hasOption("char") && isCharKind(fv.Kind()):
+			val = string(rune(charCode(fv)))
+		case f.hasOption("complex") && isComplexKind(fv.Kind()):
+			val = complexObject(fv)
+		default:
+			val, err = e.toValue(fv)
+		}
+		e.path = e.path[:len(e.path)-1]
+		if err != nil {
+			return nil, err
+		}
+		obj[f.name] = val
+	}
+	return obj, nil
+}
+
+// fieldByIndex is reflect.Value.FieldByIndex that reports false instead of
+// panicking at a nil embedded struct pointer.
+func fieldByIndex(rv reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				return reflect.Value{}, false
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+	return rv, true
+}
+
+// hasOption reports whether the field's tag lists opt after its name.
+func (f field) hasOption(opt string) bool {
+	for _, o := range strings.Split(f.opts, ",") {
+		if o == opt {
+			return true
+		}
+	}
+	return false
+}
+
+// isCharKind reports whether a `,char` tag applies to a field of kind k:
+// rune (int32) and byte (uint8).
+func isCharKind(k reflect.Kind) bool {
+	return k == reflect.Int32 || k == reflect.Uint8
+}
+
+func charCode(rv reflect.Value) int64 {
+	if rv.Kind() == reflect.Uint8 {
+		return int64(rv.Uint())
+	}
+	return rv.Int()
+}
+
+// decodeChar stores the single character of s in the byte or rune rv, for
+// a `,char` field.
+func decodeChar(s string, rv reflect.Value) bool {
+	r, size := utf8.DecodeRuneInString(s)
+	if size == 0 || size != len(s) || (r == utf8.RuneError && size == 1) {
+		return false
+	}
+	if rv.Kind() == reflect.Uint8 {
+		if r > 0xff {
+			return false
+		}
+		rv.SetUint(uint64(r))
+		return true
+	}
+	rv.SetInt(int64(r))
+	return true
+}
+
+// isComplexKind reports whether a `,complex` tag applies to a field of
+// kind k.
+func isComplexKind(k reflect.Kind) bool {
+	return k == reflect.Complex64 || k == reflect.Complex128
+}
+
+// complexObject returns the `{re, im}` form of the complex rv, with parts
+// of the float type matching its size.
+func complexObject(rv reflect.Value) Object {
+	c := rv.Complex()
+	if rv.Kind() == reflect.Complex64 {
+		return Object{"re": float32(real(c)), "im": float32(imag(c))}
+	}
+	return Object{"re": real(c), "im": imag(c)}
+}
+
+// decodeComplex stores the complex number obj holds, in the form
+// complexObject writes, in rv, for a `,complex` field. Both parts must be
+// present and no other key.
+func decodeComplex(obj Object, rv reflect.Value) bool {
+	if len(obj) != 2 {
+		return false
+	}
+	re, ok := toFloat64(obj["re"])
+	if !ok {
+		return false
+	}
+	im, ok := toFloat64(obj["im"])
+	if !ok {
+		return false
+	}
+	c := complex(re, im)
+	if rv.OverflowComplex(c) {
+		return false
+	}
+	rv.SetComplex(c)
+	return true
+}
@@ -0,0 +1,138 @@
+package jhon
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalStruct(t *testing.T) {
+	got, err := MarshalWithOptions(testServer{
+		Host:    "localhost",
+		Port:    8080,
+		Timeout: 2.5,
+		TLS:     &testTLS{Enabled: true, CertPath: "/etc/cert.pem"},
+		Ignored: "x",
+	}, SerializeOptions{SortKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `host="localhost",port=8080,timeout=2.5,tls={cert_path="/etc/cert.pem",enabled=true}`
+	if got != want {
+		t.Fatalf("got %s want %s", got, want)
+	}
+	var back testServer
+	if err := Unmarshal(got, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back.TLS == nil || back.TLS.CertPath != "/etc/cert.pem" || back.Port != 8080 {
+		t.Fatalf("round trip: %#v", back)
+	}
+}
+
+type testKeys struct {
+	Rune     rune  `jhon:"rune"`
+	Byte     byte  `jhon:"byte"`
+	CharRune rune  `jhon:"char_rune,char"`
+	CharByte byte  `jhon:"char_byte,char"`
+	Count    int32 `jhon:"count"`
+}
+
+func TestMarshalRuneAndByte(t *testing.T) {
+	in := testKeys{Rune: 'q', Byte: 'A', CharRune: 'é', CharByte: 'z', Count: 7}
+	got, err := MarshalWithOptions(in, SerializeOptions{SortKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `byte=65,char_byte="z",char_rune="é",count=7,rune=113`
+	if got != want {
+		t.Fatalf("got %s want %s", got, want)
+	}
+	var back testKeys
+	if err := Unmarshal(got, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back != in {
+		t.Fatalf("round trip: got %#v want %#v", back, in)
+	}
+}
+
+func TestUnmarshalCharRejectsLongString(t *testing.T) {
+	for _, input := range []string{`char_rune="ab"`, `char_rune=""`, `char_byte="€"`} {
+		var got testKeys
+		var te *UnmarshalTypeError
+		if err := Unmarshal(input, &got); !errors.As(err, &te) {
+			t.Errorf("%s: got %v, want an UnmarshalTypeError", input, err)
+		}
+	}
+}
+
+type testSignal struct {
+	Z    complex128 `jhon:"z,complex"`
+	Half complex64  `jhon:"half,complex"`
+}
+
+func TestMarshalComplex(t *testing.T) {
+	in := testSignal{Z: complex(1, -2.5), Half: complex(0.5, 3)}
+	got, err := Marshal(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `half={im=3,re=0.5},z={im=-2.5,re=1}`
+	if got != want {
+		t.Fatalf("got %s want %s", got, want)
+	}
+	var back testSignal
+	if err := Unmarshal(got, &back); err != nil {
+		t.Fatal(err)
+	}
+	if back != in {
+		t.Fatalf("round trip: got %#v want %#v", back, in)
+	}
+	if _, err := Marshal(struct{ Z complex128 }{}); err == nil {
+		t.Error("complex128 without the complex tag: expected an error")
+	}
+	for _, input := range []string{`z={re=1}`, `z={re=1, im=2, extra=3}`, `z={re="1", im=2}`, `z=1`, `half={re=1e300, im=0}`} {
+		var got testSignal
+		var te *UnmarshalTypeError
+		if err := Unmarshal(input, &got); !errors.As(err, &te) {
+			t.Errorf("%s: got %v, want an UnmarshalTypeError", input, err)
+		}
+	}
+}
+
+func TestMarshalCollections(t *testing.T) {
+	got, err := MarshalWithOptions(map[string]interface{}{
+		"list":  []int{1, 2},
+		"fixed": [2]string{"a", "b"},
+		"bytes": []byte("hi"),
+		"nil":   (*testTLS)(nil),
+	}, SerializeOptions{Base64: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := ParseWithOptions(got, ParseOptions{AllowBase64: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Object{
+		"list":  Array{int64(1), int64(2)},
+		"fixed": Array{"a", "b"},
+		"bytes": []byte("hi"),
+		"nil":   nil,
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v want %#v", v, want)
+	}
+}
+
+func TestMarshalUnsupportedType(t *testing.T) {
+	_, err := Marshal(map[string]interface{}{"items": []interface{}{1, make(chan int)}})
+	var ue *UnsupportedTypeError
+	if !errors.As(err, &ue) {
+		t.Fatalf("got %v, want an UnsupportedTypeError", err)
+	}
+	if ue.Path != "items[1]" {
+		t.Errorf("path = %q", ue.Path)
+	}
+}
@@ -0,0 +1,82 @@
+package jhon
+
+import (
+	"os"
+	"strings"
+)
+
+// ============================================================================
+// Environment variables
+// ============================================================================
+
+// FromEnv builds an Object from the environment variables whose names start
+// with prefix, for overriding file-based config the twelve-factor way. For
+// each such variable the prefix and any '_' after it are stripped, the rest
+// is lowercased, and "__" marks nesting:
+//
+//	APP_PORT=8080          → port = 8080
+//	APP__DB__HOST=db.local → db = { host = "db.local" }
+//
+// A variable matches only when its name continues with '_' after the
+// prefix (or the prefix ends with one), so prefix "APP" does not pick up
+// APPLE. Values that are a JHON number, boolean or null take that type;
+// anything else is kept as the raw string, as is a number with a leading
+// zero (a ZIP code such as 01234), which would lose it. Nesting follows
+// Unflatten, so numbered levels (APP__HOSTS__0, APP__HOSTS__1) become
+// arrays.
+func FromEnv(prefix string) Object {
+	return FromEnviron(prefix, os.Environ())
+}
+
+// FromEnviron is FromEnv over a list of "NAME=value" entries in the format
+// of os.Environ, which makes it easy to test or to use a captured
+// environment.
+func FromEnviron(prefix string, environ []string) Object {
+	flat := map[string]Value{}
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := name[len(prefix):]
+		if !strings.HasSuffix(prefix, "_") && !strings.HasPrefix(rest, "_") {
+			continue
+		}
+		rest = strings.TrimLeft(rest, "_")
+		if rest == "" {
+			continue
+		}
+		flat[strings.ToLower(rest)] = envScalar(value)
+	}
+	return Unflatten(flat, "__")
+}
+
+// envScalar reads s as a JHON number, boolean or null when it is exactly
+// one, and returns it unchanged otherwise. Digits after a leading zero keep
+// s a string, so that 01234 is not read as 1234.
+func envScalar(s string) Value {
+	p := newParser([]byte(s))
+	c, ok := p.current()
+	if !ok {
+		return s
+	}
+	if digits := strings.TrimPrefix(s, "-"); len(digits) > 1 && digits[0] == '0' && (isDigit(digits[1]) || digits[1] == '_') {
+		return s
+	}
+	var v Value
+	var err error
+	switch {
+	case c == '-' || (c >= '0' && c <= '9'):
+		v, err = p.parseNumber()
+	case c == 't' || c == 'f':
+		v, err = p.parseBoolean()
+	case c == 'n':
+		v, err = p.parseNull()
+	default:
+		return s
+	}
+	if err != nil || p.pos != len(p.input) {
+		return s
+	}
+	return v
+}
@@ -0,0 +1,61 @@
+package jhon
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFromEnviron(t *testing.T) {
+	environ := []string{
+		"APP_PORT=8080",
+		"APP__DB__HOST=db.local",
+		"APP__DB__POOL=1_000",
+		"APP_DEBUG=true",
+		"APP_RATIO=0.25",
+		"APP_ZIP=01234",
+		"APP_OFFSET=-007",
+		"APP_ZERO=0",
+		"APP_EMPTY=",
+		"APP_NOTHING=null",
+		"APP_VERSION=1.2.3",
+		"APP_PATH=/usr/bin:/bin",
+		"APP__HOSTS__0=a",
+		"APP__HOSTS__1=b",
+		"APPLE=fruit",
+		"APP=bare",
+		"HOME=/root",
+		"MALFORMED",
+	}
+	got := FromEnviron("APP", environ)
+	want := Object{
+		"port":    int64(8080),
+		"db":      Object{"host": "db.local", "pool": int64(1000)},
+		"debug":   true,
+		"ratio":   0.25,
+		"zip":     "01234",
+		"offset":  "-007",
+		"zero":    int64(0),
+		"empty":   "",
+		"nothing": nil,
+		"version": "1.2.3",
+		"path":    "/usr/bin:/bin",
+		"hosts":   Array{"a", "b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got  %#v\nwant %#v", got, want)
+	}
+}
+
+func TestFromEnvironPrefixWithUnderscore(t *testing.T) {
+	got := FromEnviron("APP_", []string{"APP_NAME=x", "APPX_NAME=y"})
+	if !reflect.DeepEqual(got, Object{"name": "x"}) {
+		t.Fatalf("got %#v", got)
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv("JHONTEST_LEVEL", "3")
+	if got := FromEnv("JHONTEST"); !reflect.DeepEqual(got, Object{"level": int64(3)}) {
+		t.Fatalf("got %#v", got)
+	}
+}
@@ -0,0 +1,536 @@
+package jhon
+
+import (
+	"bytes"
+	"sort"
+	"strings"
+)
+
+// ============================================================================
+// Comment-preserving formatting
+//
+// SPEC §3.2 drops comments from the parsed Value, so this layer keeps them
+// beside it, keyed by value path as Positions and Docs are.
+// ============================================================================
+
+// Comments are the comments attached to one entry of a document: an object
+// member, an array element, or (for Inner) a container. Each comment is
+// kept verbatim, including its `//` or `/* */`.
+type Comments struct {
+	// Before holds the comments on the lines above the entry.
+	Before []string
+	// After is the comment that trails the entry on its last line.
+	After string
+	// Inner holds the comments after a container's last entry, before its
+	// closing delimiter — or, for the document root, at the end of input.
+	Inner []string
+	// BlankBefore holds the number of blank lines above each comment of
+	// Before and, last, above the entry itself; BlankInner those above
+	// each comment of Inner. Each is nil when there are none.
+	BlankBefore []int
+	BlankInner  []int
+}
+
+// A Document is a parsed value with the comments and key order of its
+// source, so it can be written back out by Format without losing either.
+type Document struct {
+	Value Value
+	// Comments maps value paths, as rendered in error messages
+	// (`features[0]`, with "" for the document root), to their comments.
+	Comments map[string]Comments
+	// order holds the rank of each key, by path — its source offset in a
+	// parsed Document — so keys are written in source order. Keys added
+	// later go after, sorted.
+	order map[string]int
+}
+
+// ParseWithComments is ParseWithOptions that keeps the comments of input:
+//
+//	features = [
+//	  "a", // primary
+//	  "b"
+//	]
+//
+// records Comments{After: "// primary"} for `features[0]`. A comment on a
+// line of its own belongs to the entry below it; one that follows an entry
+// on the same line belongs to that entry. Comments between the last entry
+// of a container and its closing delimiter are the container's Inner
+// comments.
+func ParseWithComments(input string, opts ParseOptions) (*Document, error) {
+	p := newParser([]byte(input))
+	p.opts = opts
+	p.comments = map[string]Comments{}
+	p.keyPos = map[string]nodePos{}
+	v, err := p.parseDocument()
+	if err != nil {
+		return nil, err
+	}
+	p.noteClose() // trailing comments of the document
+	order := make(map[string]int, len(p.keyPos))
+	for path, pos := range p.keyPos {
+		order[path] = pos.offset
+	}
+	return &Document{Value: v, Comments: p.comments, order: order}, nil
+}
+
+// Format parses input and writes it back pretty-printed with its comments
+// and key order intact. opts selects the indent (two spaces by default),
+// the quoting of keys and strings and AlignEquals; key ordering options are
+// ignored.
+func Format(input string, opts SerializeOptions) (string, error) {
+	doc, err := ParseWithComments(input, ParseOptions{})
+	if err != nil {
+		return "", err
+	}
+	return doc.Format(opts), nil
+}
+
+// Format writes d pretty-printed with its comments, as the package-level
+// Format does. d.Value may have been edited since it was parsed: comments
+// of entries that no longer exist are dropped, and new keys have none.
+func (d *Document) Format(opts SerializeOptions) string {
+	opts = resolveIndent(opts)
+	if opts.Indent == "" {
+		opts.Indent = "  "
+	}
+	f := &formatter{doc: d, opts: opts, opened: true}
+	v, _ := normalizeValue(d.Value)
+	switch val := v.(type) {
+	case Object:
+		f.members(val, 0)
+	case Array:
+		for i, el := range val {
+			f.entry(pathSeg{index: i}, "", el, 0)
+		}
+	case nil:
+	default:
+		renderPrettyInline(v, opts, 0, &f.sb)
+		f.sb.WriteByte('\n')
+	}
+	f.lines(d.Comments[""].Inner, d.Comments[""].BlankInner, 0)
+	if opts.VersionHeader {
+		return withVersionHeader(f.sb.String())
+	}
+	return f.sb.String()
+}
+
+// MergeDocuments layers override on top of base as Merge does, for
+// layered configs that are written back out with their comments. Neither
+// argument is modified. Keys keep base's order, followed by the keys only
+// override has, in its order. Comments follow the values:
+//
+//   - an entry present on one side keeps that side's comments, including
+//     those inside its value;
+//   - an entry on both sides takes each of its comments — those above it,
+//     the one trailing it, and those before its closing delimiter — from
+//     override if override has one there, else from base;
+//   - but where override's value replaces base's rather than merging into
+//     it, the comments inside the value come from override alone, as the
+//     base value is gone.
+//
+// The trailing comments of the document are chosen as for an entry. If
+// either value is not an Object, the result is a copy of override.
+func MergeDocuments(base, override *Document) *Document {
+	out := &Document{Comments: map[string]Comments{}, order: map[string]int{}}
+	m := &docMerger{out: out}
+	b, bok := base.Value.(Object)
+	o, ook := override.Value.(Object)
+	if !bok || !ook {
+		out.Value = override.Value
+		m.copy(override, nil, override.Value)
+		return out
+	}
+	out.Value = m.merge(base, override, nil, b, o)
+	out.Comments[""] = pickComments(base.Comments[""], override.Comments[""])
+	return out
+}
+
+// docMerger builds the Document of MergeDocuments; next is the rank the
+// next key written takes.
+type docMerger struct {
+	out  *Document
+	next int
+}
+
+func (m *docMerger) merge(base, override *Document, path []pathSeg, b, o Object) Object {
+	keys := (&formatter{doc: base, path: path}).keys(b)
+	for _, k := range (&formatter{doc: override, path: path}).keys(o) {
+		if _, ok := b[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	out := make(Object, len(keys))
+	for _, k := range keys {
+		at := append(path[:len(path):len(path)], pathSeg{key: k, index: -1})
+		name := formatPath(at)
+		bv, inBase := b[k]
+		ov, inOverride := o[k]
+		bo, bIsObj := bv.(Object)
+		oo, oIsObj := ov.(Object)
+		switch {
+		case inBase && inOverride && bIsObj && oIsObj:
+			m.rank(name)
+			out[k] = m.merge(base, override, at, bo, oo)
+			m.out.Comments[name] = pickComments(base.Comments[name], override.Comments[name])
+		case inOverride:
+			out[k] = ov
+			m.copy(override, at, ov)
+			if inBase {
+				c := pickComments(base.Comments[name], override.Comments[name])
+				c.Inner, c.BlankInner = override.Comments[name].Inner, override.Comments[name].BlankInner
+				m.setComments(name, c)
+			}
+		default:
+			out[k] = bv
+			m.copy(base, at, bv)
+		}
+	}
+	return out
+}
+
+// copy carries the comments and key order of v, at path in src, over to
+// the result.
+func (m *docMerger) copy(src *Document, path []pathSeg, v Value) {
+	name := formatPath(path)
+	if len(path) > 0 && path[len(path)-1].index < 0 {
+		m.rank(name)
+	}
+	m.setComments(name, src.Comments[name])
+	switch val := v.(type) {
+	case Object:
+		for _, k := range (&formatter{doc: src, path: path}).keys(val) {
+			m.copy(src, append(path[:len(path):len(path)], pathSeg{key: k, index: -1}), val[k])
+		}
+	case Array:
+		for i, el := range val {
+			m.copy(src, append(path[:len(path):len(path)], pathSeg{index: i}), el)
+		}
+	}
+}
+
+func (m *docMerger) rank(name string) {
+	m.out.order[name] = m.next
+	m.next++
+}
+
+func (m *docMerger) setComments(name string, c Comments) {
+	if len(c.Before) > 0 || c.After != "" || len(c.Inner) > 0 || c.BlankBefore != nil || c.BlankInner != nil {
+		m.out.Comments[name] = c
+	} else {
+		delete(m.out.Comments, name)
+	}
+}
+
+// pickComments takes each of an entry's comments from override where it
+// has one, else from base.
+func pickComments(base, override Comments) Comments {
+	if len(override.Before) > 0 {
+		base.Before, base.BlankBefore = override.Before, override.BlankBefore
+	}
+	if override.After != "" {
+		base.After = override.After
+	}
+	if len(override.Inner) > 0 {
+		base.Inner, base.BlankInner = override.Inner, override.BlankInner
+	}
+	return base
+}
+
+type formatter struct {
+	doc  *Document
+	opts SerializeOptions
+	path []pathSeg
+	sb   strings.Builder
+	// opened is set when nothing has been written in the current container
+	// yet, where blank lines are dropped.
+	opened bool
+}
+
+// members writes the entries of obj in source order.
+func (f *formatter) members(obj Object, depth int) {
+	keys := f.keys(obj)
+	written := alignedKeys(keys, f.opts)
+	for i, k := range keys {
+		f.entry(pathSeg{key: k, index: -1}, written[i], obj[k], depth)
+	}
+}
+
+// entry writes one object member or array element on its own lines; key is
+// the member's key as written, padded per SerializeOptions.AlignEquals.
+func (f *formatter) entry(seg pathSeg, key string, v Value, depth int) {
+	f.path = append(f.path, seg)
+	c := f.doc.Comments[formatPath(f.path)]
+	f.lines(c.Before, c.BlankBefore, depth)
+	if len(c.BlankBefore) > len(c.Before) {
+		f.blank(c.BlankBefore[len(c.Before)])
+	}
+	f.opened = false
+	writeIndent(&f.sb, f.opts.Indent, depth)
+	if seg.index < 0 {
+		f.sb.WriteString(key)
+		f.sb.WriteString(" = ")
+	}
+	f.value(v, c, depth)
+	if c.After != "" {
+		after := c.After
+		if restyled := restyleComment(after, f.opts.CommentStyle); len(restyled) == 1 {
+			after = restyled[0]
+		}
+		f.sb.WriteByte(' ')
+		f.sb.WriteString(after)
+	}
+	f.sb.WriteByte('\n')
+	f.path = f.path[:len(f.path)-1]
+}
+
+// value writes v; c holds its own comments, whose Inner go before the
+// closing delimiter of a container.
+func (f *formatter) value(v Value, c Comments, depth int) {
+	switch val := v.(type) {
+	case Object:
+		if len(val) == 0 && len(c.Inner) == 0 {
+			f.sb.WriteString("{}")
+			return
+		}
+		f.sb.WriteString("{\n")
+		f.opened = true
+		f.members(val, depth+1)
+		f.lines(c.Inner, c.BlankInner, depth+1)
+		writeIndent(&f.sb, f.opts.Indent, depth)
+		f.sb.WriteByte('}')
+	case Array:
+		if len(val) == 0 && len(c.Inner) == 0 {
+			f.sb.WriteString("[]")
+			return
+		}
+		f.sb.WriteString("[\n")
+		f.opened = true
+		for i, el := range val {
+			f.entry(pathSeg{index: i}, "", el, depth+1)
+		}
+		f.lines(c.Inner, c.BlankInner, depth+1)
+		writeIndent(&f.sb, f.opts.Indent, depth)
+		f.sb.WriteByte(']')
+	default:
+		if !serializeScalar(v, f.opts, &f.sb) {
+			renderPrettyInline(v, f.opts, depth, &f.sb)
+		}
+	}
+}
+
+// lines writes comments on lines of their own, each below the number of
+// blank lines blank gives it.
+func (f *formatter) lines(comments []string, blank []int, depth int) {
+	for i, c := range comments {
+		if i < len(blank) {
+			f.blank(blank[i])
+		}
+		f.opened = false
+		for _, line := range restyleComment(c, f.opts.CommentStyle) {
+			writeIndent(&f.sb, f.opts.Indent, depth)
+			f.sb.WriteString(line)
+			f.sb.WriteByte('\n')
+		}
+	}
+}
+
+// blank writes n blank lines, or as many as SerializeOptions.MaxBlankLines
+// allows, unless nothing has been written in the container yet.
+func (f *formatter) blank(n int) {
+	if f.opened {
+		return
+	}
+	if n > f.opts.MaxBlankLines {
+		n = f.opts.MaxBlankLines
+	}
+	for ; n > 0; n-- {
+		f.sb.WriteByte('\n')
+	}
+}
+
+// restyleComment rewrites the comment c, as written in the source, with the
+// marker style selects (see SerializeOptions.CommentStyle), one string per
+// line it takes.
+func restyleComment(c, style string) []string {
+	var text []string
+	switch {
+	case style != "//" && style != "#" && style != "/*":
+		return []string{c}
+	case strings.HasPrefix(c, "/*"):
+		if style == "/*" {
+			return []string{c}
+		}
+		// Drop the delimiters, the leading '*' of each line and the blank
+		// lines they leave at either end.
+		for _, line := range strings.Split(strings.TrimSuffix(c[2:], "*/"), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "*") {
+				line = strings.TrimSpace(line[1:])
+			}
+			text = append(text, line)
+		}
+		for len(text) > 0 && text[0] == "" {
+			text = text[1:]
+		}
+		for len(text) > 0 && text[len(text)-1] == "" {
+			text = text[:len(text)-1]
+		}
+		if len(text) == 0 {
+			text = []string{""}
+		}
+	case strings.HasPrefix(c, "//"):
+		if style == "//" {
+			return []string{c}
+		}
+		text = []string{strings.TrimSpace(strings.TrimLeft(c, "/"))}
+	default:
+		return []string{c}
+	}
+	out := make([]string, len(text))
+	for i, line := range text {
+		switch {
+		case style == "/*" && strings.Contains(line, "*/"):
+			return []string{c}
+		case style == "/*" && line == "":
+			out[i] = "/* */"
+		case style == "/*":
+			out[i] = "/* " + line + " */"
+		case line == "":
+			out[i] = style
+		default:
+			out[i] = style + " " + line
+		}
+	}
+	return out
+}
+
+// keys orders the keys of obj as they appeared in the source.
+func (f *formatter) keys(obj Object) []string {
+	keys := make([]string, 0, len(obj))
+	offset := make(map[string]int, len(obj))
+	for k := range obj {
+		keys = append(keys, k)
+		f.path = append(f.path, pathSeg{key: k, index: -1})
+		if off, ok := f.doc.order[formatPath(f.path)]; ok {
+			offset[k] = off
+		} else {
+			offset[k] = -1
+		}
+		f.path = f.path[:len(f.path)-1]
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := offset[keys[i]], offset[keys[j]]
+		switch {
+		case a < 0 && b < 0:
+			return keys[i] < keys[j]
+		case a < 0 || b < 0:
+			return b < 0
+		}
+		return a < b
+	})
+	return keys
+}
+
+// noteComment files the comment that started at start and ends at the
+// current position: after the entry that ends on the same line, or as
+// pending until the next entry or closing delimiter claims it.
+func (p *parser) noteComment(start nodePos) {
+	if start.offset < p.commentsTo {
+		return // already seen, when the parser looks ahead and backs up
+	}
+	p.commentsTo = p.pos
+	text := string(p.input[start.offset:p.pos])
+	lineStart := bytes.LastIndexByte(p.input[:start.offset], '\n') + 1
+	alone := len(bytes.TrimLeft(p.input[lineStart:start.offset], " \t")) == 0
+	if !alone && p.lastItemLine == start.line {
+		c := p.comments[p.lastItem]
+		if c.After != "" {
+			c.After += " "
+		}
+		c.After += strings.TrimRight(text, " \t\r")
+		p.comments[p.lastItem] = c
+		return
+	}
+	p.pending = append(p.pending, strings.TrimRight(text, " \t\r"))
+	p.pendingBlank = append(p.pendingBlank, p.blanksAbove(start.offset))
+}
+
+// blanksAbove counts the blank lines right above the line holding offset,
+// when nothing precedes offset on that line.
+func (p *parser) blanksAbove(offset int) int {
+	lineStart := bytes.LastIndexByte(p.input[:offset], '\n') + 1
+	if len(bytes.TrimLeft(p.input[lineStart:offset], " \t")) > 0 {
+		return 0
+	}
+	n := 0
+	for lineStart > 0 {
+		prev := bytes.LastIndexByte(p.input[:lineStart-1], '\n') + 1
+		if len(bytes.TrimSpace(p.input[prev:lineStart-1])) > 0 {
+			break
+		}
+		n++
+		lineStart = prev
+	}
+	return n
+}
+
+// blankCounts returns counts, or nil when they are all zero.
+func blankCounts(counts []int) []int {
+	for _, n := range counts {
+		if n > 0 {
+			return counts
+		}
+	}
+	return nil
+}
+
+// noteItemStart gives the pending comments to the entry at the current
+// path, which starts at start.
+func (p *parser) noteItemStart(start nodePos) {
+	if p.comments == nil {
+		return
+	}
+	p.lastItemLine = 0
+	blank := p.blanksAbove(start.offset)
+	if len(p.pending) > 0 || blank > 0 {
+		path := formatPath(p.path)
+		c := p.comments[path]
+		counts := c.BlankBefore
+		if counts == nil {
+			counts = make([]int, len(c.Before)+1)
+		}
+		counts = append(append(counts[:len(counts)-1], p.pendingBlank...), blank)
+		c.Before = append(c.Before, p.pending...)
+		c.BlankBefore = blankCounts(counts)
+		p.comments[path] = c
+		p.pending, p.pendingBlank = nil, nil
+	}
+}
+
+// noteItemEnd records that the entry at the current path ends here, so a
+// comment later on this line trails it.
+func (p *parser) noteItemEnd() {
+	if p.comments == nil {
+		return
+	}
+	p.lastItem = formatPath(p.path)
+	p.lastItemLine = p.line
+}
+
+// noteClose gives the pending comments to the container being closed, at
+// the current path, as its Inner comments.
+func (p *parser) noteClose() {
+	if p.comments == nil || len(p.pending) == 0 {
+		return
+	}
+	path := formatPath(p.path)
+	c := p.comments[path]
+	counts := c.BlankInner
+	if counts == nil {
+		counts = make([]int, len(c.Inner))
+	}
+	c.Inner = append(c.Inner, p.pending...)
+	c.BlankInner = blankCounts(append(counts, p.pendingBlank...))
+	p.comments[path] = c
+	p.pending, p.pendingBlank = nil, nil
+}
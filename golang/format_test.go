@@ -0,0 +1,238 @@
+package jhon
+
+import (
+	"reflect"
+	"testing"
+)
+
+const commentedDoc = `// Service settings.
+name = "svc" // shown in the UI
+
+features = [
+  "a", // primary
+  // experimental:
+  "b"
+  /* more to come */
+]
+server = { host = "x"
+  /* Port. */ port = 80, tls = {} // none yet
+}
+// end of file
+`
+
+func TestFormatKeepsComments(t *testing.T) {
+	got, err := Format(commentedDoc, SerializeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `// Service settings.
+name = "svc" // shown in the UI
+features = [
+  "a" // primary
+  // experimental:
+  "b"
+  /* more to come */
+]
+server = {
+  host = "x"
+  /* Port. */
+  port = 80
+  tls = {} // none yet
+}
+// end of file
+`
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if again, _ := Format(got, SerializeOptions{}); again != got {
+		t.Fatalf("Format is not idempotent:\n%s", again)
+	}
+	if !reflect.DeepEqual(MustParse(got), MustParse(commentedDoc)) {
+		t.Fatal("Format changed the value")
+	}
+}
+
+func TestParseWithCommentsArrayElements(t *testing.T) {
+	doc, err := ParseWithComments("features=[ \"a\", // primary\n \"b\" ]", ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Comments{"features[0]": {After: "// primary"}}
+	if !reflect.DeepEqual(doc.Comments, want) {
+		t.Fatalf("got %#v", doc.Comments)
+	}
+
+	// Elements of a top-level array, and of arrays in arrays.
+	doc, err = ParseWithComments("// one\n1\n[2, // two\n  3]", ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = map[string]Comments{"[0]": {Before: []string{"// one"}}, "[1][0]": {After: "// two"}}
+	if !reflect.DeepEqual(doc.Comments, want) {
+		t.Fatalf("got %#v", doc.Comments)
+	}
+	if got := doc.Format(SerializeOptions{}); got != "// one\n1\n[\n  2 // two\n  3\n]\n" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestDocumentFormatAfterEdit(t *testing.T) {
+	doc, err := ParseWithComments("b = 1 // bee\na = [1, // first\n 2]", ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := doc.Value.(Object)
+	obj["c"] = true
+	obj["a"] = Array{int64(9)}
+	got := doc.Format(SerializeOptions{Indent: "\t"})
+	if want := "b = 1 // bee\na = [\n\t9 // first\n]\nc = true\n"; got != want {
+		t.Fatalf("got %q\nwant %q", got, want)
+	}
+}
+
+func TestFormatAlignEquals(t *testing.T) {
+	got, err := Format("name = \"x\" // the name\nlong_name = 1\nsub = {a = 1, bb = 2}", SerializeOptions{AlignEquals: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "name      = \"x\" // the name\nlong_name = 1\nsub       = {\n  a  = 1\n  bb = 2\n}\n"
+	if got != want {
+		t.Fatalf("got %q\nwant %q", got, want)
+	}
+}
+
+func TestFormatCommentStyle(t *testing.T) {
+	input := "// head\na = 1 /* one */\n/*\n * two\n * lines\n */\nb = 2\n/// doc\nc = 3 // trailing"
+	cases := map[string]string{
+		"":   input + "\n",
+		"//": "// head\na = 1 // one\n// two\n// lines\nb = 2\n/// doc\nc = 3 // trailing\n",
+		"#":  "# head\na = 1 # one\n# two\n# lines\nb = 2\n# doc\nc = 3 # trailing\n",
+		"/*": "/* head */\na = 1 /* one */\n/*\n * two\n * lines\n */\nb = 2\n/* doc */\nc = 3 /* trailing */\n",
+	}
+	for style, want := range cases {
+		got, err := Format(input, SerializeOptions{CommentStyle: style})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("CommentStyle %q: got %q\nwant %q", style, got, want)
+		}
+	}
+}
+
+func TestFormatCommentStyleKeepsUnconvertible(t *testing.T) {
+	got, err := Format("a = 1 /* two\nlines */\n// has */ inside\nb = 2", SerializeOptions{CommentStyle: "/*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a = 1 /* two\nlines */\n// has */ inside\nb = 2\n"; got != want {
+		t.Fatalf("got %q\nwant %q", got, want)
+	}
+	got, err = Format("a = 1 /* two\nlines */", SerializeOptions{CommentStyle: "#"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "a = 1 /* two\nlines */\n"; got != want {
+		t.Fatalf("got %q\nwant %q", got, want)
+	}
+}
+
+func TestFormatMaxBlankLines(t *testing.T) {
+	input := "\n\n// head\na = 1\n\n\n\nb = 2 // bee\n\n// c doc\n\n\nc = {\n\n  x = 1\n\n  y = [1, 2]\n\n}\nd = 1, e = 2\n\n\n// tail\n"
+	cases := map[int]string{
+		0: "// head\na = 1\nb = 2 // bee\n// c doc\nc = {\n  x = 1\n  y = [\n    1\n    2\n  ]\n}\nd = 1\ne = 2\n// tail\n",
+		1: "// head\na = 1\n\nb = 2 // bee\n\n// c doc\n\nc = {\n  x = 1\n\n  y = [\n    1\n    2\n  ]\n}\nd = 1\ne = 2\n\n// tail\n",
+		2: "// head\na = 1\n\n\nb = 2 // bee\n\n// c doc\n\n\nc = {\n  x = 1\n\n  y = [\n    1\n    2\n  ]\n}\nd = 1\ne = 2\n\n\n// tail\n",
+	}
+	for max, want := range cases {
+		got, err := Format(input, SerializeOptions{MaxBlankLines: max})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("MaxBlankLines %d: got %q\nwant %q", max, got, want)
+		}
+		if again, _ := Format(got, SerializeOptions{MaxBlankLines: max}); again != got {
+			t.Errorf("MaxBlankLines %d: not stable, reformats as %q", max, again)
+		}
+	}
+	doc, err := ParseWithComments(input, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := doc.Comments["c"]; !reflect.DeepEqual(got.BlankBefore, []int{1, 2}) {
+		t.Errorf("c: got BlankBefore %v, want [1 2]", got.BlankBefore)
+	}
+	if got := doc.Comments["b"]; !reflect.DeepEqual(got.BlankBefore, []int{3}) {
+		t.Errorf("b: got BlankBefore %v, want [3]", got.BlankBefore)
+	}
+}
+
+func TestMergeDocuments(t *testing.T) {
+	base, err := ParseWithComments(`// defaults
+port = 80 // base port
+host = "a" // base host
+// server settings
+server = {
+  // base timeout
+  timeout = 5
+  retries = 3
+}
+tags = [1, // base tag
+  2]`, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	override, err := ParseWithComments(`host = "b"
+port = 8080 // prod port
+server = {timeout = 9
+  // new key
+  tls = true}
+tags = [3 // prod tag
+]
+// extra
+extra = 1`, ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc := MergeDocuments(base, override)
+	got := doc.Format(SerializeOptions{})
+	want := `// defaults
+port = 8080 // prod port
+host = "b" // base host
+// server settings
+server = {
+  // base timeout
+  timeout = 9
+  retries = 3
+  // new key
+  tls = true
+}
+tags = [
+  3 // prod tag
+]
+// extra
+extra = 1
+`
+	if got != want {
+		t.Fatalf("got %q\nwant %q", got, want)
+	}
+	if !Equal(doc.Value, Merge(base.Value.(Object), override.Value.(Object))) {
+		t.Errorf("value %#v differs from Merge", doc.Value)
+	}
+	if base.Value.(Object)["port"] != int64(80) || base.Comments["port"].After != "// base port" {
+		t.Errorf("base was modified: %q", base.Format(SerializeOptions{}))
+	}
+}
+
+func TestMergeDocumentsNonObject(t *testing.T) {
+	base, _ := ParseWithComments("a = 1 // one", ParseOptions{})
+	override, err := ParseWithComments("[1, // first\n2]", ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := MergeDocuments(base, override).Format(SerializeOptions{})
+	if want := override.Format(SerializeOptions{}); got != want {
+		t.Fatalf("got %q\nwant %q", got, want)
+	}
+}
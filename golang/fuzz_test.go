@@ -0,0 +1,193 @@
+package jhon
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// FuzzParse checks that Parse, its relaxed modes and the other entry points
+// that read text, ArrayReader included, return an error, never panic, on
+// any input.
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		``,
+		`a=1, b="two", c=[3, 4.5, true, null]`,
+		"server={host=\"x\"\nports=[80, 443]}\n// comment",
+		`{a=1}, [1, 2], "s"`,
+		`s = r#"raw "quoted""#, t = 'é\x41\n'`,
+		`n = 0x_ff, m = -1_000.5e-3, big = 123456789012345678901234567890`,
+		`a.b.c = 1; d = b64"SGk=" + "x"`,
+		`a = {b = [1, {c =`,
+		"a = \"\\",
+		`/* open`,
+		`[[[[[[[[[[`,
+		`tru`, `nul`, `"\u12`,
+	} {
+		f.Add(seed)
+	}
+	relaxed := ParseOptions{
+		AllowPartial:          true,
+		AllowStringConcat:     true,
+		DottedKeysAsNesting:   true,
+		DottedArrayIndexes:    true,
+		AllowSemicolons:       true,
+		AllowBase64:           true,
+		MergeDuplicateObjects: true,
+		AllowDuplicateKeys:    true,
+		BigNumbers:            true,
+		AllowMergeKeys:        true,
+		AllowHexFloat:         true,
+		ExtendedBooleans:      true,
+	}
+	f.Fuzz(func(t *testing.T, input string) {
+		for _, opts := range []ParseOptions{{}, relaxed, {KeepNumberLiterals: true}} {
+			if v, err := ParseWithOptions(input, opts); err == nil {
+				Serialize(v)
+			}
+		}
+		ParseJSON5(input)
+		ParseStrict(input)
+		MinifyBytes([]byte(input))
+		Format(input, SerializeOptions{})
+		if ar, err := NewDecoder(strings.NewReader(input)).ArrayReader(); err == nil {
+			for ar.More() {
+				var v Value
+				if ar.Decode(&v) != nil {
+					break
+				}
+			}
+		}
+	})
+}
+
+// FuzzSerializeRoundTrip checks that Serialize output, compact and pretty,
+// parses back to a value Equal to the one serialized. The tree is built
+// from the fuzzer's bytes by fuzzTree.
+func FuzzSerializeRoundTrip(f *testing.F) {
+	for _, seed := range []string{
+		"\x06\x02\x05\x011\x02\x00\x00\x00\x00\x00\x00\x00\x07",
+		"\x06\x01\x05\x00\x06\x00",
+		"\x07\x03\x05\x031e3\x05\x04true\x05\x02-0",
+		"\x06\x01\x05\x03a b\x04\x3f\xf0\x00\x00\x00\x00\x00\x01",
+		"\x06\x01\x05\x01\x7f\x05\x02\"\x7f",
+	} {
+		f.Add([]byte(seed))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		v := (&fuzzTree{data: data}).root()
+		for _, opts := range []SerializeOptions{
+			{},
+			{Indent: "  "},
+			{Indent: "\t", MaxInlineWidth: 40, AlignEquals: true, TrailingComma: true},
+			{QuoteAllKeys: true, ExponentThreshold: 1e6},
+		} {
+			text := SerializeWithOptions(v, opts)
+			back, err := Parse(text)
+			if err != nil {
+				t.Fatalf("%#v serialized with %+v as %q does not parse: %v", v, opts, text, err)
+			}
+			if !Equal(back, v) {
+				t.Fatalf("%#v serialized with %+v as %q parses back as %#v", v, opts, text, back)
+			}
+		}
+	})
+}
+
+// fuzzTree builds a Value tree from fuzzer bytes, using only the types
+// Parse produces: finite numbers, strings, booleans, null, and containers.
+// Its root is a non-empty object or array, whose top-level form SPEC §7.1
+// lets round-trip.
+type fuzzTree struct {
+	data  []byte
+	depth int
+}
+
+func (g *fuzzTree) byte() byte {
+	if len(g.data) == 0 {
+		return 0
+	}
+	b := g.data[0]
+	g.data = g.data[1:]
+	return b
+}
+
+func (g *fuzzTree) uint64() uint64 {
+	var u uint64
+	for i := 0; i < 8; i++ {
+		u = u<<8 | uint64(g.byte())
+	}
+	return u
+}
+
+func (g *fuzzTree) string() string {
+	n := int(g.byte() % 16)
+	if n > len(g.data) {
+		n = len(g.data)
+	}
+	s := string(g.data[:n])
+	g.data = g.data[n:]
+	return s
+}
+
+func (g *fuzzTree) root() Value {
+	if g.byte()%2 == 0 {
+		obj := g.object()
+		if len(obj) == 0 {
+			obj["k"] = nil
+		}
+		return obj
+	}
+	arr := g.array()
+	if len(arr) == 0 {
+		arr = append(arr, false)
+	}
+	return arr
+}
+
+func (g *fuzzTree) object() Object {
+	obj := Object{}
+	for n := g.byte() % 4; n > 0; n-- {
+		obj[g.string()] = g.value()
+	}
+	return obj
+}
+
+func (g *fuzzTree) array() Array {
+	arr := Array{}
+	for n := g.byte() % 4; n > 0; n-- {
+		arr = append(arr, g.value())
+	}
+	return arr
+}
+
+func (g *fuzzTree) value() Value {
+	kind := g.byte() % 8
+	if g.depth >= 4 && kind >= 6 {
+		kind = 0
+	}
+	switch kind {
+	case 1:
+		return g.byte()%2 == 0
+	case 2:
+		return int64(g.uint64())
+	case 3:
+		return g.uint64() | 1<<63
+	case 4:
+		f := math.Float64frombits(g.uint64())
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return 0.5
+		}
+		return f
+	case 5:
+		return g.string()
+	case 6, 7:
+		g.depth++
+		defer func() { g.depth-- }()
+		if kind == 6 {
+			return g.object()
+		}
+		return g.array()
+	}
+	return nil
+}
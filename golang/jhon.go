@@ -6,11 +6,18 @@
 package jhon
 
 import (
+	"bytes"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"math"
 	"math/big"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
 // ============================================================================
@@ -18,12 +25,20 @@ import (
 // ============================================================================
 
 // Value represents any JHON value (object, array, string, number, boolean, null).
+//
+// Parse keeps integers and decimals apart by Go type, so a type switch tells
+// them apart without guessing: integer literals (`42`, `-7`, `0xFF`) are
+// int64, or uint64 when too large for int64, and literals with a fraction
+// or exponent (`1.5`, `1.0`, `1e3`) are float64. ParseOptions.UseNumber
+// and BigNumbers select other representations. Code that asserted float64
+// for every number, as with encoding/json, should switch on both types or
+// use Object.GetFloatOr, which accepts either.
 type Value interface{}
 
-// Object represents a JHON object — a map of string keys to Values. Key
-// insertion order is preserved by parse; serialize emits keys in
-// Object.keys() order (Go map iteration is random, so callers that want a
-// stable order should sort first or use the sortKeys serialize option).
+// Object represents a JHON object — a map of string keys to Values. Being a
+// Go map, it does not remember the order keys were written in; serialize
+// emits them sorted, so output is stable (see SerializeOptions).
+// ParseWithComments keeps the source order for Format.
 type Object map[string]Value
 
 // Array represents a JHON array.
@@ -39,37 +54,95 @@ const (
 )
 
 // ParseError is returned by Parse on invalid input. It carries 1-based line
-// and column for diagnostic placement.
+// and column for diagnostic placement, and the path of the value being
+// parsed for context in deep documents.
 type ParseError struct {
-	Kind     ParseErrorKind
-	Line     int
-	Column   int
-	EndLine  int
+	Kind      ParseErrorKind
+	Line      int
+	Column    int
+	EndLine   int
 	EndColumn int
-	Position int
-	Message  string
-	Key      string // populated when Kind == ParseErrorDuplicateKey
+	Position  int
+	Message   string
+	Key       string // populated when Kind == ParseErrorDuplicateKey
+	// Path is where in the document the error occurred, in the form
+	// `server.middleware[0].name`; empty at the top level. For a duplicate
+	// key it is the object holding the key.
+	Path string
 }
 
 func (e *ParseError) Error() string {
+	where := fmt.Sprintf("%d:%d", e.Line, e.Column)
+	if e.Path != "" {
+		where += " in " + e.Path
+	}
 	switch e.Kind {
 	case ParseErrorEOF:
-		return fmt.Sprintf("unexpected end of input at %d:%d: %s", e.Line, e.Column, e.Message)
+		return fmt.Sprintf("unexpected end of input at %s: %s", where, e.Message)
 	case ParseErrorDuplicateKey:
-		return fmt.Sprintf("duplicate key at %d:%d: %q", e.Line, e.Column, e.Key)
+		return fmt.Sprintf("duplicate key at %s: %q", where, e.Key)
 	default:
-		return fmt.Sprintf("parse error at %d:%d: %s", e.Line, e.Column, e.Message)
+		return fmt.Sprintf("parse error at %s: %s", where, e.Message)
 	}
 }
 
 // SerializeOptions controls compact and pretty serializer output.
+//
+// Output is deterministic: the same value and options always give the same
+// text, so it can be committed to version control or hashed. An Object is
+// a Go map, which has no insertion order to preserve (SPEC §5.4), so keys
+// are written in bytewise order unless KeyOrder or NaturalSort says
+// otherwise; Format and MinifyBytes keep the order of their source text.
 type SerializeOptions struct {
-	// SortKeys emits object keys in lexicographic order. Default false — per
-	// SPEC §5.4, insertion order is preserved.
+	// SortKeys emits object keys in bytewise order.
+	//
+	// Deprecated: keys are always sorted, as above, so SortKeys has no
+	// effect. Output without it used to follow Go's random map order.
 	SortKeys bool
 	// Indent is the indent string used per depth level in pretty mode.
 	// Defaults to "  " (two spaces) when empty.
 	Indent string
+	// IndentWidth selects pretty mode indented by this many spaces. Ignored
+	// when Indent is set.
+	IndentWidth int
+	// UseTabs selects pretty mode indented by one tab per level. Ignored when
+	// Indent is set; takes precedence over IndentWidth.
+	UseTabs bool
+	// KeyOrder, when non-empty, emits the keys it names first and in its
+	// order, then the remaining keys sorted — e.g. []string{"name",
+	// "description", "value"} keeps a canonical field order. It applies to
+	// every object at every nesting level and overrides SortKeys.
+	//
+	// One flat list, rather than a template keyed by path, is deliberate:
+	// names an object lacks are skipped, so a single list can rank the
+	// fields of several kinds of object, and a key name that means
+	// different things at different depths takes the same rank in each.
+	KeyOrder []string
+	// TrailingComma appends a comma after the last entry of every multi-line
+	// pretty container, so appending an entry later touches only one line of
+	// a diff. Ignored in compact mode. The parser accepts trailing commas, so
+	// the output still round-trips.
+	TrailingComma bool
+	// SortArrays emits set-like arrays in sorted order for stable diffs.
+	// Only arrays whose elements are all strings, all numbers or all bools
+	// are sorted (strings bytewise, numbers by value, false before true);
+	// arrays holding objects, arrays, nulls or a mix of kinds keep their
+	// order, since order usually matters there. The caller's values are
+	// not modified.
+	SortArrays bool
+	// EscapeHTML writes '<', '>' and '&' in strings and keys as \u003c,
+	// \u003e and \u0026, as encoding/json does, so output can be embedded
+	// in an HTML page (inside <script>, say) safely. Keys containing them
+	// are quoted. Off by default: JHON is config, not web content.
+	EscapeHTML bool
+	// Base64 writes a []byte as a `b64"..."` byte string, which only
+	// ParseOptions.AllowBase64 reads back. Without it a []byte is written as
+	// an array of its byte values on one line, [104,105], which any parser
+	// reads as an Array of integers and Unmarshal reads back into a []byte.
+	Base64 bool
+	// minify picks the shortest spelling of each string and float; see
+	// Minify.
+	minify bool
 	// MaxInlineWidth controls short-container inlining in pretty mode.
 	// 0 (default): every non-empty container renders multi-line.
 	// >0: a container whose single-line form fits within this many characters
@@ -77,6 +150,285 @@ type SerializeOptions struct {
 	// whose joined children fit but the whole doesn't use a 3-line wrapper.
 	// Otherwise expands multi-line with one child per line.
 	MaxInlineWidth int
+	// ExponentThreshold, when positive, writes a float whose magnitude is at
+	// least this large, or nonzero and below its reciprocal, in exponent form
+	// (1.5e9, 2.5e-7); other floats are written in plain decimal, never in
+	// exponent form. With 1e6, 1500000.0 is written 1.5e6 and 0.0000025 is
+	// 2.5e-6, while 999999.5 and 0.5 stay plain. Integer types are always
+	// written in full, so their values stay integers when read back.
+	ExponentThreshold float64
+	// NaturalSort sorts keys in natural order, comparing runs of digits by
+	// numeric value, so key_2 comes before key_10. It also orders the keys
+	// KeyOrder leaves unranked.
+	NaturalSort bool
+	// QuoteAllKeys writes every key as a double-quoted string, even where a
+	// bare key would do, for stricter downstream parsers or visual
+	// consistency in generated files.
+	QuoteAllKeys bool
+	// InlineObjectMaxLen, when positive, replaces MaxInlineWidth for nested
+	// objects: an object whose single-line form `{ k = v, ... }` is at most
+	// this many characters stays inline, and a longer one expands. Arrays
+	// still follow MaxInlineWidth, so short objects can stay compact while
+	// lists expand one element per line, or the reverse.
+	InlineObjectMaxLen int
+	// AlignEquals pads keys in pretty mode so the '=' signs of each
+	// multi-line object line up, one column per object (nested objects
+	// align on their own):
+	//
+	//	host    = "localhost"
+	//	port    = 8080
+	//	enabled = true
+	//
+	// Inline objects and compact output are unaffected.
+	AlignEquals bool
+	// Color wraps keys and scalars in ANSI escape codes for display on a
+	// terminal, using ColorScheme or, when that is nil, DefaultColorScheme.
+	// The output is for people, not for parsing back: JHON has no escape
+	// codes outside strings. Layout is unchanged, as line widths are
+	// measured without the codes.
+	Color       bool
+	ColorScheme *ColorScheme
+	// BareKeyExtraChars writes keys containing these delimiters bare, for
+	// a parser with the same ParseOptions.BareKeyExtraChars; as there, only
+	// '/' and '#' take effect, and a key containing "//" or "/*" is still
+	// quoted.
+	BareKeyExtraChars string
+	// CommentStyle selects the marker of the comments Format and Skeleton
+	// write: "//", "#" or "/*" (for `/* ... */`). A multi-line block
+	// comment becomes one line comment per line, and a comment that cannot
+	// be converted (a trailing multi-line block, or text containing `*/`)
+	// is kept as written. The zero value keeps comments as written and
+	// writes Skeleton's doc comments as `///`. JHON itself does not accept
+	// `#` comments; choose it only for a reader that does.
+	CommentStyle string
+	// MaxBlankLines is how many consecutive blank lines Format keeps where
+	// the source has them, between entries and comments; longer runs are
+	// collapsed to it. Blank lines at the start of a container are always
+	// dropped. The zero value drops them all.
+	MaxBlankLines int
+	// VersionHeader starts the output with a `// jhon: v2.1` comment
+	// naming SpecVersion, the grammar it is written in, for files that
+	// outlive the grammar they were written for; see HeaderVersion and
+	// ParseOptions.CheckVersion. Format replaces any header the source
+	// has.
+	VersionHeader bool
+}
+
+// ColorScheme holds the ANSI escape sequence SerializeOptions.Color writes
+// before each kind of token, such as "\x1b[32m" for green; "\x1b[0m"
+// follows each one. An empty sequence leaves that kind uncolored.
+type ColorScheme struct {
+	Key    string
+	String string // strings and byte strings
+	Number string
+	Bool   string
+	Null   string
+}
+
+// DefaultColorScheme is the palette of SerializeOptions.Color when
+// ColorScheme is nil: bold blue keys, green strings, cyan numbers, yellow
+// booleans and gray null.
+var DefaultColorScheme = ColorScheme{
+	Key:    "\x1b[1;34m",
+	String: "\x1b[32m",
+	Number: "\x1b[36m",
+	Bool:   "\x1b[33m",
+	Null:   "\x1b[90m",
+}
+
+const colorReset = "\x1b[0m"
+
+// ParseOptions controls optional parser behavior. The zero value parses
+// exactly like Parse.
+type ParseOptions struct {
+	// UseNumber makes numeric literals decode as Number (the literal text with
+	// underscores removed) instead of int64/uint64/float64, mirroring
+	// encoding/json's Decoder.UseNumber. Serialize emits a Number verbatim, so
+	// values round-trip without float64 precision loss.
+	UseNumber bool
+	// BigNumbers returns *big.Int for integer literals that fit neither int64
+	// nor uint64, and *big.Float for decimal literals outside float64 range,
+	// instead of rounding them to float64. UseNumber takes precedence.
+	BigNumbers bool
+	// AllowPartial closes arrays and nested objects that are still open at
+	// end of input instead of failing, so tooling such as a language server
+	// can work with a half-typed document. Each container closed this way is
+	// reported as a Warning by ParseWithWarnings. Other errors, such as an
+	// unterminated string, still fail.
+	AllowPartial bool
+	// ScalarResolver, when non-nil, extends the set of unquoted values.
+	// Built-in types are always tried first: the resolver is called only
+	// when an unquoted token (a run of bytes up to whitespace, a separator,
+	// a bracket or a comment) is not entirely a number, boolean or null,
+	// e.g. `10MB`, `1h30m`, `2024-01-02` or `#ff8800`. If it returns ok, its
+	// Value is used; otherwise the parser reports the error it would
+	// without a resolver.
+	ScalarResolver func(literal string) (Value, bool)
+	// ExtendedBooleans reads the unquoted words yes and on as true, and no
+	// and off as false, in any case (`Yes`, `OFF`), for configs migrated
+	// from INI or YAML. Serialize still writes true and false. Without it
+	// these words are a syntax error, as JHON has no unquoted strings:
+	// write "yes" to mean the string.
+	ExtendedBooleans bool
+	// CheckVersion rejects a document whose `// jhon: vX.Y` header (see
+	// SerializeOptions.VersionHeader) names a grammar this package cannot
+	// read: another major version, or a minor one later than SpecVersion.
+	// Without it, as without a header, the header is just a comment.
+	CheckVersion bool
+	// StrictNumbers rejects decimal integer parts with leading zeros, such
+	// as `007` or `-01.5`, as JSON does. A lone `0` (including `0.5` and
+	// `0e3`) is still fine. By default leading zeros are accepted and
+	// ignored, so `007` reads as 7. Misplaced underscores (`1_`, `1__0`,
+	// `1_.0`) are errors with or without it, per SPEC §3.5.
+	StrictNumbers bool
+	// AllowStringConcat joins string values written as `"Hello " + "world"`
+	// into one string at parse time, so long strings can be split across
+	// lines. Quoted and raw strings mix freely; any other operand is an
+	// error.
+	AllowStringConcat bool
+	// KeepNumberLiterals is UseNumber that keeps each literal exactly as
+	// written — `1_000`, `1.50`, `1E3`, `0xFF` — so a formatter can
+	// re-serialize numbers without reformatting them. Number's methods
+	// ignore the underscores.
+	KeepNumberLiterals bool
+	// DottedKeysAsNesting reads a top-level bare key containing dots as a
+	// path, TOML-style: `server.host = "x"` and `server.port = 8080` build
+	// server = { host = "x", port = 8080 }. Quoted keys stay literal. Using
+	// a key both as a value and as a prefix (`a = 1` then `a.b = 2`) is an
+	// error, as is setting the same path twice.
+	DottedKeysAsNesting bool
+	// DottedArrayIndexes extends DottedKeysAsNesting to arrays: a segment
+	// of decimal digits (`0`, `12`, not `01`) indexes an array, so
+	// `items.0 = "a"` and `items.2 = "c"` build items = ["a", null, "c"].
+	// Indexes may come in any order, and may extend an array written out
+	// in full; positions no key sets are null, and setting one later is
+	// not a duplicate. A digit segment on an object,
+	// or a named one on an array, is an error, and an index may be at most
+	// 65535 past the end of its array. It has no effect without
+	// DottedKeysAsNesting.
+	DottedArrayIndexes bool
+	// AllowSemicolons accepts ';' wherever ',' may separate items, for
+	// users coming from C-like configs: `a=1; b=2`. Like a comma, one may
+	// trail the last item. Semicolons have no other meaning in JHON, so
+	// this cannot change how any valid document parses.
+	AllowSemicolons bool
+	// AllowBase64 reads `b64"SGVsbG8="` — standard, padded base64 in a
+	// double-quoted string — as a []byte, for small binary blobs such as
+	// keys. SerializeOptions.Base64 writes a []byte in this form.
+	AllowBase64 bool
+	// AllowDuplicateKeys lets a key repeat within an object; the last value
+	// wins. By default a repeated key is a ParseErrorDuplicateKey error.
+	AllowDuplicateKeys bool
+	// MergeDuplicateObjects deep-merges a repeated key whose earlier and
+	// later values are both objects, as Merge does, so a config assembled
+	// from fragments can write `db = { host = "x" }` and later
+	// `db = { port = 5432 }`. Other repeats are last-wins under
+	// AllowDuplicateKeys and an error otherwise.
+	MergeDuplicateObjects bool
+	// MaxDepth limits how deeply objects and arrays may nest, so hostile
+	// input like `[[[[...` fails with an error instead of exhausting the
+	// stack. 0 selects DefaultMaxDepth.
+	MaxDepth int
+	// AllowMergeKeys expands YAML-style merge keys at parse time. An object
+	// entry `<< = "name"` copies in the entries of the object stored under
+	// name in an enclosing object, looked up from the innermost outward, so
+	// shared settings can be written once:
+	//
+	//	_defaults = { timeout = 30, retries = 3 }
+	//	api = { << = "_defaults", timeout = 60 }  // timeout 60, retries 3
+	//
+	// The named object must appear before the merge key. The value may also
+	// be an object, or an array of names and objects merged in order with
+	// later ones winning. The merge is shallow, and the object's own keys
+	// win over merged ones wherever they appear. The `<<` entry itself is
+	// dropped; the named source stays in the result.
+	AllowMergeKeys bool
+	// AllowHexFloat reads hexadecimal floating-point numbers as Go and C
+	// write them, `0x1.8p3` (12.0): hex digits, an optional hex fraction
+	// and a required binary exponent 'p' with a decimal power of two, so a
+	// config can state the exact bits of a float64. They decode as float64
+	// (or as the literal under UseNumber); Serialize writes the shortest
+	// decimal form, which parses back to the same bits.
+	AllowHexFloat bool
+	// BareKeyExtraChars lists delimiters that may also appear in bare keys,
+	// for key conventions such as `path/to/thing = 1`. Only '/' and '#'
+	// can be added: a '/' still starts a comment when followed by '/' or
+	// '*', and the other delimiters in the set are ignored. Letters,
+	// digits, '.', ':' and the rest are always allowed (SPEC §3.3).
+	BareKeyExtraChars string
+}
+
+// maxBigBinaryExp bounds the binary exponent of a ParseOptions.BigNumbers
+// float, about 1e±10000.
+const maxBigBinaryExp = 33220
+
+// DefaultMaxDepth is the nesting limit used when ParseOptions.MaxDepth is 0.
+const DefaultMaxDepth = 10000
+
+// Warning describes input the parser accepted only because a relaxed
+// ParseOptions setting allowed it. Line and Column locate the construct the
+// warning is about.
+type Warning struct {
+	Line    int
+	Column  int
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%d:%d: %s", w.Line, w.Column, w.Message)
+}
+
+// Number is a numeric literal kept as text, returned by ParseWithOptions
+// when ParseOptions.UseNumber is set. Radix prefixes (0x, 0o, 0b) and a
+// leading '-' are kept; digit-separator underscores are not, unless
+// ParseOptions.KeepNumberLiterals asked for the exact source text.
+type Number string
+
+// String returns the literal text of the number.
+func (n Number) String() string { return string(n) }
+
+// Int64 returns the number as an int64. Hex, octal and binary literals are
+// honored; fractional or exponent forms return an error.
+func (n Number) Int64() (int64, error) {
+	return strconv.ParseInt(n.digits(), n.base(), 64)
+}
+
+// Float64 returns the number as a float64. Hexadecimal floats (0x1.8p3,
+// under ParseOptions.AllowHexFloat) are honored.
+func (n Number) Float64() (float64, error) {
+	if n.base() == 0 && !n.hexFloat() {
+		// Radix literals are integer-valued; go through big.Int so values
+		// beyond int64 still convert.
+		bi, ok := new(big.Int).SetString(n.digits(), 0)
+		if !ok {
+			return 0, fmt.Errorf("jhon: invalid number %q", string(n))
+		}
+		f, _ := new(big.Float).SetInt(bi).Float64()
+		return f, nil
+	}
+	return strconv.ParseFloat(n.digits(), 64)
+}
+
+// digits returns the literal without digit-separator underscores.
+func (n Number) digits() string {
+	return strings.ReplaceAll(string(n), "_", "")
+}
+
+// hexFloat reports whether n is a hexadecimal floating-point literal: a
+// 0x literal with a '.' or a 'p' exponent.
+func (n Number) hexFloat() bool {
+	s := strings.TrimPrefix(string(n), "-")
+	return len(s) > 1 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X') && strings.ContainsAny(s, ".pP")
+}
+
+// base returns 0 (prefix-detected) for radix literals and 10 otherwise, so
+// that a decimal literal with leading zeros is never read as octal.
+func (n Number) base() int {
+	s := strings.TrimPrefix(string(n), "-")
+	if len(s) > 1 && s[0] == '0' && (s[1] == 'x' || s[1] == 'o' || s[1] == 'b') {
+		return 0
+	}
+	return 10
 }
 
 // ============================================================================
@@ -88,6 +440,98 @@ type parser struct {
 	pos   int
 	line  int
 	col   int
+	opts  ParseOptions
+
+	// path is the key/index path of the value being parsed, innermost last.
+	path []pathSeg
+	// keyPos, when non-nil, records where each object key starts, by path.
+	// Unmarshal uses it to position unknown-field errors.
+	keyPos map[string]nodePos
+	// valuePos, when non-nil, records where each value starts, by path.
+	// ParseWithPositions returns it.
+	valuePos map[string]nodePos
+	// scopes holds the objects being built, innermost last, for resolving
+	// merge keys under ParseOptions.AllowMergeKeys.
+	scopes []Object
+	// valueEnd, when non-nil, records where each value ends, just past its
+	// last byte, by path; Patch pairs it with valuePos.
+	valueEnd map[string]nodePos
+	// spans, when non-nil, collects the source span of each top-level
+	// value, for ScanSpans.
+	spans *[]Span
+	// openComment is set when a block comment runs to EOF. The comment
+	// swallows the rest of the input, so whatever error the parser would
+	// report next is really this one.
+	openComment *ParseError
+	// warnings collects what relaxed options let through.
+	warnings []Warning
+	// docs, when non-nil, records the `///` doc comment of each key, by
+	// path, for ParseWithDocs. docLines holds the doc comment being read,
+	// whose last line is docLine.
+	docs     Docs
+	docLines []string
+	docLine  int
+	// json5 selects the string escapes of ParseJSON5.
+	json5 bool
+	// strictJSON limits strings to JSON's escapes, for ParseStrict.
+	strictJSON bool
+	// comments, when non-nil, collects comments by the path of the entry
+	// they belong to, for ParseWithComments; see noteComment.
+	comments     map[string]Comments
+	pending      []string
+	pendingBlank []int
+	lastItem     string
+	lastItemLine int
+	commentsTo   int
+	// depth is the number of objects and arrays open; see enter.
+	depth int
+	// holes holds the paths of the array elements that are null only
+	// because a dotted key set a later index; see setDotted.
+	holes map[string]bool
+}
+
+// pathSeg is one step of a value path: an object key or, when index >= 0,
+// an array index.
+type pathSeg struct {
+	key   string
+	index int
+}
+
+// nodePos is a 0-based byte offset plus 1-based line and column.
+type nodePos struct {
+	offset, line, col int
+}
+
+func (p *parser) here() nodePos {
+	return nodePos{offset: p.pos, line: p.line, col: p.col}
+}
+
+func (p *parser) pushKey(key string) { p.path = append(p.path, pathSeg{key: key, index: -1}) }
+func (p *parser) pushIndex(i int)    { p.path = append(p.path, pathSeg{index: i}) }
+func (p *parser) pop()               { p.path = p.path[:len(p.path)-1] }
+
+// formatPath renders a path as `server.middleware[0].name`. Keys that would
+// be ambiguous in dotted form are bracket-quoted: `a["b.c"]`.
+func formatPath(path []pathSeg) string {
+	var sb strings.Builder
+	for i, seg := range path {
+		switch {
+		case seg.index >= 0:
+			sb.WriteByte('[')
+			sb.WriteString(strconv.Itoa(seg.index))
+			sb.WriteByte(']')
+		case seg.key == "" || strings.ContainsAny(seg.key, ".[]\"\\"):
+			sb.WriteByte('[')
+			sb.WriteString(strconv.Quote(seg.key))
+			sb.WriteByte(']')
+		default:
+			if i > 0 {
+				sb.WriteByte('.')
+			}
+			sb.WriteString(seg.key)
+		}
+	}
+	return sb.String()
 }
 
 func newParser(input []byte) *parser {
@@ -109,6 +553,19 @@ func (p *parser) peek(offset int) (byte, bool) {
 	return p.input[idx], true
 }
 
+// skip advances over the next n bytes, as n calls to advance would, for
+// scanning a comment in one step rather than byte by byte.
+func (p *parser) skip(n int) {
+	seg := p.input[p.pos : p.pos+n]
+	if i := bytes.LastIndexByte(seg, '\n'); i >= 0 {
+		p.line += bytes.Count(seg, []byte{'\n'})
+		p.col = n - i
+	} else {
+		p.col += n
+	}
+	p.pos += n
+}
+
 func (p *parser) advance() (byte, bool) {
 	b, ok := p.current()
 	if !ok {
@@ -124,20 +581,25 @@ func (p *parser) advance() (byte, bool) {
 	return b, true
 }
 
-// syntaxErr builds a ParseError at the current position.
+// syntaxErr builds a ParseError at the current position. An unterminated
+// block comment takes precedence, since it is what consumed the input.
 func (p *parser) syntaxErr(msg string) *ParseError {
+	if p.openComment != nil {
+		return p.openComment
+	}
 	kind := ParseErrorSyntax
 	if p.pos >= len(p.input) {
 		kind = ParseErrorEOF
 	}
 	return &ParseError{
-		Kind:     kind,
-		Line:     p.line,
-		Column:   p.col,
-		EndLine:  p.line,
+		Kind:      kind,
+		Line:      p.line,
+		Column:    p.col,
+		EndLine:   p.line,
 		EndColumn: p.col + 1,
-		Position: p.pos,
-		Message:  msg,
+		Position:  p.pos,
+		Message:   msg,
+		Path:      formatPath(p.path),
 	}
 }
 
@@ -164,44 +626,50 @@ func (p *parser) skipWsAndComments() bool {
 			if next == '/' {
 				// Line comment — consume up to (not including) the newline so
 				// the outer loop records the newline.
-				p.advance()
-				p.advance()
-				for {
-					c, ok := p.current()
-					if !ok || c == '\n' {
-						break
-					}
-					p.advance()
+				start := p.here()
+				rest := p.input[p.pos+2:]
+				n := bytes.IndexByte(rest, '\n')
+				if n < 0 {
+					n = len(rest)
+				}
+				p.skip(2 + n)
+				if p.docs != nil {
+					p.noteDocComment(start)
+				}
+				if p.comments != nil {
+					p.noteComment(start)
 				}
 			} else if next == '*' {
 				// Block comment — consume through the closing */.
-				start := p.line
-				startCol := p.col
-				p.advance()
-				p.advance()
-				closed := false
-				for {
-					c, ok := p.current()
-					if !ok {
-						break
-					}
-					if c == '*' {
-						if n, ok := p.peek(1); ok && n == '/' {
-							p.advance()
-							p.advance()
-							closed = true
-							break
-						}
-					}
-					if c == '\n' {
-						sawNewline = true
-					}
-					p.advance()
+				start := p.here()
+				rest := p.input[p.pos+2:]
+				n := bytes.Index(rest, []byte("*/"))
+				closed := n >= 0
+				if !closed {
+					n = len(rest)
+				}
+				if bytes.IndexByte(rest[:n], '\n') >= 0 {
+					sawNewline = true
 				}
+				if closed {
+					n += 2
+				}
+				p.skip(2 + n)
 				if !closed {
+					p.openComment = &ParseError{
+						Kind:      ParseErrorEOF,
+						Line:      start.line,
+						Column:    start.col,
+						EndLine:   p.line,
+						EndColumn: p.col,
+						Position:  start.offset,
+						Message:   "unterminated block comment",
+						Path:      formatPath(p.path),
+					}
 					return sawNewline
-					_ = start
-					_ = startCol
+				}
+				if p.comments != nil {
+					p.noteComment(start)
 				}
 			} else {
 				return sawNewline
@@ -213,10 +681,11 @@ func (p *parser) skipWsAndComments() bool {
 }
 
 // skipInterItemSeparator skips the separator between two consecutive items.
-// Returns (sawNewline, sawComma). Per SPEC §5.3, same-line items need a comma.
+// Returns (sawNewline, sawComma). Per SPEC §5.3, same-line items need a comma
+// (or, under ParseOptions.AllowSemicolons, a semicolon, which counts as one).
 func (p *parser) skipInterItemSeparator() (sawNewline, sawComma bool) {
 	sawNewline = p.skipWsAndComments()
-	if c, ok := p.current(); ok && c == ',' {
+	if c, ok := p.current(); ok && (c == ',' || (c == ';' && p.opts.AllowSemicolons)) {
 		sawComma = true
 		p.advance()
 		if p.skipWsAndComments() {
@@ -226,38 +695,63 @@ func (p *parser) skipInterItemSeparator() (sawNewline, sawComma bool) {
 	return
 }
 
-// Parse parses a JHON document into a Value.
+// Parse parses a JHON document into a Value. It returns an error for any
+// invalid input and never panics, so it is safe on untrusted input such as
+// network messages; see ParseOptions.MaxDepth for the nesting limit.
 func Parse(input string) (Value, error) {
+	return ParseWithOptions(input, ParseOptions{})
+}
+
+// ParseWithOptions parses a JHON document with the given options.
+func ParseWithOptions(input string, opts ParseOptions) (Value, error) {
+	v, _, err := ParseWithWarnings(input, opts)
+	return v, err
+}
+
+// ParseWithWarnings is ParseWithOptions that also returns the warnings
+// collected under relaxed options such as AllowPartial. With the default
+// options there are never any warnings.
+func ParseWithWarnings(input string, opts ParseOptions) (Value, []Warning, error) {
 	p := newParser([]byte(input))
+	p.opts = opts
+	v, err := p.parseDocument()
+	if err != nil {
+		return nil, nil, err
+	}
+	return v, p.warnings, nil
+}
+
+// parseDocument parses the whole input as a JHON document.
+func (p *parser) parseDocument() (Value, error) {
+	if p.opts.CheckVersion {
+		if err := p.checkVersion(); err != nil {
+			return nil, err
+		}
+	}
 	p.skipWsAndComments()
+	if p.openComment != nil {
+		return nil, p.openComment
+	}
 	if p.pos >= len(p.input) {
 		// Empty input (including whitespace-only and comments-only) → nil.
 		// Per SPEC §2, this is the "Empty" form, distinct from {} and [].
 		return nil, nil
 	}
 
-	// Mode detection (SPEC §2): the first top-level element decides whether
-	// the document is parsed as an object (key=value pairs) or as an implicit
-	// array (bare values). `{...}` and `[...]` always begin array mode since
-	// they cannot start a `key=` pair.
-	first, _ := p.current()
-	objectMode := false
-	if first != '{' && first != '[' {
-		// Save parser state, try to parse a key, look ahead for '='.
-		savedPos, savedLine, savedCol := p.pos, p.line, p.col
-		if _, err := p.parseKey(); err == nil {
-			p.skipWsAndComments()
-			if c, ok := p.current(); ok && c == '=' {
-				objectMode = true
-			}
-		}
-		p.pos, p.line, p.col = savedPos, savedLine, savedCol
+	if p.valuePos != nil {
+		p.valuePos[""] = p.here()
 	}
-
-	if objectMode {
-		return p.parseJhonObject()
+	var v Value
+	var err error
+	if p.objectMode() || p.looksLikeProperty() {
+		v, err = p.parseJhonObject()
+	} else {
+		v, err = p.parseJhonArray()
 	}
-	return p.parseJhonArray()
+	if err == nil && p.openComment != nil {
+		return nil, p.openComment
+	}
+	return v, err
 }
 
 // MustParse parses a JHON config string and panics on error.
@@ -274,16 +768,51 @@ func ParseJSON(input string) (interface{}, error) {
 	return Parse(input)
 }
 
+// objectMode performs mode detection (SPEC §2): the first top-level element
+// decides whether the document is parsed as an object (key=value pairs) or
+// as an implicit array (bare values). `{...}` and `[...]` always begin array
+// mode since they cannot start a `key=` pair. The parser position is left
+// unchanged.
+func (p *parser) objectMode() bool {
+	first, _ := p.current()
+	if first == '{' || first == '[' {
+		return false
+	}
+	// Save parser state, try to parse a key, look ahead for '='.
+	savedPos, savedLine, savedCol := p.pos, p.line, p.col
+	defer func() { p.pos, p.line, p.col = savedPos, savedLine, savedCol }()
+	if _, err := p.parseKey(); err != nil {
+		return false
+	}
+	p.skipWsAndComments()
+	c, ok := p.current()
+	return ok && c == '='
+}
+
 // parseJhonObject parses a bare top-level object (no surrounding braces).
 func (p *parser) parseJhonObject() (Value, error) {
 	obj := Object{}
+	p.openScope(obj)
+	defer p.closeScope()
 	p.skipWsAndComments()
 	for p.pos < len(p.input) {
+		start := p.here()
 		key, val, err := p.parseProperty(obj)
 		if err != nil {
 			return nil, err
 		}
-		obj[key] = val
+		if key == mergeKey && p.opts.AllowMergeKeys {
+			if val, err = p.mergeSources(val, start); err != nil {
+				return nil, err
+			}
+		}
+		if segs := p.dottedKey(start, key); segs != nil {
+			if err := p.setDotted(obj, segs, key, val, start); err != nil {
+				return nil, err
+			}
+		} else {
+			obj[key] = val
+		}
 		sawNewline, sawComma := p.skipInterItemSeparator()
 		if p.pos >= len(p.input) {
 			break // trailing separator at EOF is fine
@@ -292,9 +821,66 @@ func (p *parser) parseJhonObject() (Value, error) {
 			return nil, p.syntaxErr("items on the same line must be separated by a comma")
 		}
 	}
+	applyMergeKey(obj, p.opts)
 	return obj, nil
 }
 
+// looksLikeProperty reports whether the first line, though not a clean
+// `key = value`, has an '=' before any ',' or bracket outside quotes — e.g.
+// `a b = 1`, `"a"x = 1` or `= 1`. Such a document is meant to be in object
+// mode, and parsing it that way yields an error about the key instead of a
+// confusing one about array elements.
+func (p *parser) looksLikeProperty() bool {
+	var quote byte
+	for i := p.pos; i < len(p.input); i++ {
+		c := p.input[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case (c == 'r' || c == 'R') && (i == p.pos || !isWordByte(p.input[i-1])):
+			if end, ok := rawStringEnd(p.input, i); ok {
+				i = end - 1 // no escapes inside, and it may span lines
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '=':
+			return true
+		case c == '\n' || c == ',' || c == '{' || c == '[' || c == '/':
+			return false
+		}
+	}
+	return false
+}
+
+// rawStringEnd returns the offset just past the raw string starting at
+// input[i], or past the end of input if it is unterminated, and whether one
+// starts there at all.
+func rawStringEnd(input []byte, i int) (int, bool) {
+	j := i + 1
+	for j < len(input) && input[j] == '#' {
+		j++
+	}
+	if j >= len(input) || input[j] != '"' {
+		return 0, false
+	}
+	closing := append([]byte{'"'}, input[i+1:j]...)
+	end := bytes.Index(input[j+1:], closing)
+	if end < 0 {
+		return len(input), true
+	}
+	return j + 1 + end + len(closing), true
+}
+
+// isWordByte reports whether c can be part of a bare word, so that the
+// 'r' in `bar"` is not taken for the start of a raw string.
+func isWordByte(c byte) bool {
+	return c == '_' || c == '-' || isDigit(c) || (c|0x20 >= 'a' && c|0x20 <= 'z')
+}
+
 // parseJhonArray parses a top-level implicit array (no surrounding []).
 // Per SPEC §2: when the first top-level element is not a key=value pair, the
 // whole document is treated as an array. Mixing pairs into array mode is an
@@ -307,10 +893,18 @@ func (p *parser) parseJhonArray() (Value, error) {
 		if c, ok := p.current(); ok && c == '=' {
 			return nil, p.syntaxErr("cannot mix key=value pairs and bare values at top level")
 		}
+		p.pushIndex(len(arr))
+		p.noteItemStart(p.here())
+		valStart := p.here()
 		val, err := p.parseValue()
+		p.noteItemEnd()
+		p.pop()
 		if err != nil {
 			return nil, err
 		}
+		if p.spans != nil {
+			*p.spans = append(*p.spans, Span{Index: len(arr), Start: valStart.position(), End: p.here().position()})
+		}
 		arr = append(arr, val)
 		sawNewline, sawComma := p.skipInterItemSeparator()
 		if p.pos >= len(p.input) {
@@ -325,68 +919,415 @@ func (p *parser) parseJhonArray() (Value, error) {
 
 // parseNestedObject parses a braced object: { k=v, ... }.
 func (p *parser) parseNestedObject() (Value, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	open := p.here()
 	p.advance() // {
 	obj := Object{}
+	p.openScope(obj)
+	defer p.closeScope()
 	p.skipWsAndComments()
 	for {
 		c, ok := p.current()
 		if !ok {
-			return nil, p.syntaxErr("unterminated nested object")
+			if p.autoClose("object", open) {
+				return applyMergeKey(obj, p.opts), nil
+			}
+			return nil, p.unterminatedErr("nested object", open)
 		}
 		if c == '}' {
+			p.noteClose()
 			p.advance()
-			return obj, nil
+			return applyMergeKey(obj, p.opts), nil
+		}
+		if c == ']' {
+			return nil, p.mismatchErr('}', "object", open)
 		}
+		start := p.here()
 		key, val, err := p.parseProperty(obj)
 		if err != nil {
 			return nil, err
 		}
+		if key == mergeKey && p.opts.AllowMergeKeys {
+			if val, err = p.mergeSources(val, start); err != nil {
+				return nil, err
+			}
+		}
 		obj[key] = val
 		sawNewline, sawComma := p.skipInterItemSeparator()
-		if c, ok := p.current(); ok && c == '}' {
+		c, ok = p.current()
+		switch {
+		case !ok:
+			if p.autoClose("object", open) {
+				return applyMergeKey(obj, p.opts), nil
+			}
+			return nil, p.unterminatedErr("nested object", open)
+		case c == '}':
+			p.noteClose()
 			p.advance()
-			return obj, nil
+			return applyMergeKey(obj, p.opts), nil
+		case c == ']':
+			return nil, p.mismatchErr('}', "object", open)
+		case !sawNewline && !sawComma:
+			return nil, p.syntaxErr("items on the same line must be separated by a comma")
+		}
+	}
+}
+
+// mergeKey is the key of a merge entry under ParseOptions.AllowMergeKeys.
+const mergeKey = "<<"
+
+func (p *parser) openScope(obj Object) {
+	if p.opts.AllowMergeKeys {
+		p.scopes = append(p.scopes, obj)
+	}
+}
+
+func (p *parser) closeScope() {
+	if p.opts.AllowMergeKeys {
+		p.scopes = p.scopes[:len(p.scopes)-1]
+	}
+}
+
+// mergeSources resolves the value of a merge entry, which starts at start,
+// to the one object whose entries it contributes.
+func (p *parser) mergeSources(v Value, start nodePos) (Object, error) {
+	merged := Object{}
+	add := func(v Value) error {
+		if name, ok := v.(string); ok {
+			var found bool
+			// The innermost scope is the object holding the merge key.
+			for i := len(p.scopes) - 2; i >= 0 && !found; i-- {
+				v, found = p.scopes[i][name]
+			}
+			if !found {
+				return p.errAt(start, fmt.Sprintf("merge key names %q, which is not defined in an enclosing object before it", name))
+			}
 		}
+		src, ok := v.(Object)
 		if !ok {
-			return nil, p.syntaxErr("unterminated nested object")
+			return p.errAt(start, fmt.Sprintf("merge key source must be an object, not a %s", describeValue(v)))
 		}
-		if !sawNewline && !sawComma {
-			return nil, p.syntaxErr("items on the same line must be separated by a comma")
+		for k, el := range src {
+			merged[k] = cloneValue(el) // edits to one copy must not show in the others
+		}
+		return nil
+	}
+	if arr, ok := v.(Array); ok {
+		for _, el := range arr {
+			if err := add(el); err != nil {
+				return nil, err
+			}
+		}
+		return merged, nil
+	}
+	return merged, add(v)
+}
+
+// applyMergeKey replaces the merge entry of obj, if any, with the entries
+// it contributes that obj does not set itself.
+func applyMergeKey(obj Object, opts ParseOptions) Object {
+	merged, ok := obj[mergeKey].(Object)
+	if !ok || !opts.AllowMergeKeys {
+		return obj
+	}
+	delete(obj, mergeKey)
+	for k, v := range merged {
+		if _, exists := obj[k]; !exists {
+			obj[k] = v
+		}
+	}
+	return obj
+}
+
+// dottedKey splits a top-level bare key at its dots under
+// ParseOptions.DottedKeysAsNesting, and returns nil when the key is to be
+// taken literally. start is where the key begins.
+func (p *parser) dottedKey(start nodePos, key string) []string {
+	if !p.opts.DottedKeysAsNesting || len(p.path) != 0 || !strings.Contains(key, ".") {
+		return nil
+	}
+	if c := p.input[start.offset]; c == '"' || c == '\'' {
+		return nil
+	}
+	return strings.Split(key, ".")
+}
+
+// maxDottedIndexGap bounds how far past the end of an array a dotted key
+// may index, so a stray `items.999999999` cannot allocate gigabytes.
+const maxDottedIndexGap = 1 << 16
+
+// dottedIndex reports whether seg of a dotted key is an array index under
+// ParseOptions.DottedArrayIndexes, and which.
+func (p *parser) dottedIndex(seg string) (int, bool) {
+	if !p.opts.DottedArrayIndexes || !isDecimalRun(seg) || (len(seg) > 1 && seg[0] == '0') || len(seg) > 9 {
+		return 0, false
+	}
+	i, _ := strconv.Atoi(seg)
+	return i, true
+}
+
+// setDotted stores val at the path segs of a dotted key, creating the
+// intermediate objects, and arrays under DottedArrayIndexes. Errors point
+// at the key.
+func (p *parser) setDotted(obj Object, segs []string, key string, val Value, start nodePos) error {
+	var cur Value = obj
+	store := func(Value) {} // the top-level object is never replaced
+	var path []pathSeg
+	for i, seg := range segs {
+		seg := seg // captured by put
+		if seg == "" {
+			return p.errAt(start, fmt.Sprintf("empty segment in dotted key %q", key))
+		}
+		var existing Value
+		var exists bool
+		var put func(Value)
+		if index, isIndex := p.dottedIndex(seg); isIndex && i > 0 {
+			arr, ok := cur.(Array)
+			if !ok {
+				prefix := strings.Join(segs[:i], ".")
+				return p.errAt(start, fmt.Sprintf("dotted key %q indexes %q as an array, but it is already set to %s", key, prefix, withArticle(describeValue(cur))))
+			}
+			if index >= len(arr)+maxDottedIndexGap {
+				return p.errAt(start, fmt.Sprintf("dotted key %q indexes too far past the end of %q", key, strings.Join(segs[:i], ".")))
+			}
+			if p.holes == nil {
+				p.holes = map[string]bool{}
+			}
+			for len(arr) <= index {
+				p.holes[formatPath(append(path, pathSeg{index: len(arr)}))] = true
+				arr = append(arr, nil)
+			}
+			store(arr)
+			path = append(path, pathSeg{index: index})
+			at := formatPath(path)
+			existing, exists = arr[index], !p.holes[at]
+			put = func(v Value) {
+				arr[index] = v
+				delete(p.holes, at)
+			}
+		} else {
+			o, ok := cur.(Object)
+			if !ok {
+				prefix := strings.Join(segs[:i], ".")
+				return p.errAt(start, fmt.Sprintf("dotted key %q needs %q to be an object, but it is already set to %s", key, prefix, withArticle(describeValue(cur))))
+			}
+			path = append(path, pathSeg{key: seg, index: -1})
+			existing, exists = o[seg]
+			put = func(v Value) { o[seg] = v }
+		}
+		if i == len(segs)-1 {
+			if merged, ok := p.resolveDuplicate(existing, val); exists && ok {
+				put(merged)
+				return nil
+			}
+			if exists {
+				err := p.errAt(start, fmt.Sprintf("duplicate key %q", key))
+				err.Kind = ParseErrorDuplicateKey
+				err.Key = key
+				return err
+			}
+			put(val)
+			return nil
+		}
+		if !exists {
+			existing = Object{}
+			if _, ok := p.dottedIndex(segs[i+1]); ok {
+				existing = Array{}
+			}
+			put(existing)
+		}
+		cur, store = existing, put
+	}
+	return nil
+}
+
+// withArticle prefixes the kind of value desc names with "a" or "an".
+func withArticle(desc string) string {
+	if strings.ContainsRune("aeiou", rune(desc[0])) {
+		return "an " + desc
+	}
+	return "a " + desc
+}
+
+// resolveDuplicate returns the value a repeated key takes, prev being the
+// value already stored, or false when the repeat is an error.
+func (p *parser) resolveDuplicate(prev, val Value) (Value, bool) {
+	if p.opts.MergeDuplicateObjects {
+		if a, ok := prev.(Object); ok {
+			if b, ok := val.(Object); ok {
+				return Merge(a, b), true
+			}
 		}
 	}
+	return val, p.opts.AllowDuplicateKeys
+}
+
+// enter records that an object or array opens at the current position,
+// failing if that nests deeper than ParseOptions.MaxDepth allows.
+func (p *parser) enter() error {
+	max := p.opts.MaxDepth
+	if max <= 0 {
+		max = DefaultMaxDepth
+	}
+	if p.depth >= max {
+		return p.syntaxErr(fmt.Sprintf("nesting exceeds the maximum depth of %d", max))
+	}
+	p.depth++
+	return nil
+}
+
+func (p *parser) leave() { p.depth-- }
+
+// errAt builds a syntax ParseError at pos rather than the current position.
+func (p *parser) errAt(pos nodePos, msg string) *ParseError {
+	return &ParseError{
+		Kind:      ParseErrorSyntax,
+		Line:      pos.line,
+		Column:    pos.col,
+		EndLine:   pos.line,
+		EndColumn: pos.col + 1,
+		Position:  pos.offset,
+		Message:   msg,
+		Path:      formatPath(p.path),
+	}
+}
+
+// autoClose decides what to do with a construct still open at end of input.
+// Under ParseOptions.AllowPartial it records a warning and reports true, and
+// the caller returns what it has parsed so far. An unterminated block comment
+// is still an error, since it may have swallowed the closing delimiter.
+func (p *parser) autoClose(construct string, open nodePos) bool {
+	if !p.opts.AllowPartial || p.openComment != nil {
+		return false
+	}
+	p.warnings = append(p.warnings, Warning{
+		Line:    open.line,
+		Column:  open.col,
+		Message: "unterminated " + construct + " closed at end of input",
+	})
+	return true
+}
+
+// unterminatedErr reports a construct opened at open that is still open at
+// end of input. The error points at the end, and names where the
+// construct began, which is where the missing delimiter was forgotten.
+func (p *parser) unterminatedErr(construct string, open nodePos) *ParseError {
+	return p.syntaxErr(fmt.Sprintf("unterminated %s opened at %d:%d", construct, open.line, open.col))
+}
+
+// mismatchErr reports a closing delimiter that does not match the construct
+// opened at open, e.g. the `]` in `{a=1]`. The error points at the stray
+// delimiter.
+func (p *parser) mismatchErr(want byte, construct string, open nodePos) *ParseError {
+	c, _ := p.current()
+	return p.syntaxErr(fmt.Sprintf("expected '%c' to close %s opened at %d:%d but found '%c'", want, construct, open.line, open.col, c))
 }
 
 // parseProperty parses one k=v pair and validates duplicate keys.
 func (p *parser) parseProperty(seen Object) (string, Value, error) {
+	p.skipWsAndComments()
+	start := p.here()
 	key, err := p.parseKey()
 	if err != nil {
 		return "", nil, err
 	}
+	end := p.here()
 	p.skipWsAndComments()
 	if c, ok := p.current(); !ok || c != '=' {
+		if ok && p.line == end.line && !isKeyDelimiter(c) {
+			if quoted := p.input[start.offset] == '"' || p.input[start.offset] == '\''; quoted && p.pos == end.offset {
+				return "", nil, p.syntaxErr(fmt.Sprintf("unexpected %q after quoted key %q; expected '='", c, key))
+			}
+			if eq := bytes.IndexByte(p.input[p.pos:], '='); eq >= 0 {
+				spaced := strings.TrimRight(string(p.input[start.offset:p.pos+eq]), " \t")
+				if !strings.ContainsAny(spaced, "\n,{}[]\"'") {
+					return "", nil, p.syntaxErr(fmt.Sprintf("unquoted key cannot contain spaces; quote it as %q", spaced))
+				}
+			}
+		}
+		if isDecimalRun(key) {
+			// Most likely the tail of `n=1,000`: only '_' groups digits.
+			return "", nil, p.syntaxErr(fmt.Sprintf("expected '=' after key %q; ',' separates items, so group digits with '_' (1_000)", key))
+		}
 		return "", nil, p.syntaxErr("expected '=' after key")
 	}
+	eq := p.here()
 	p.advance()
-	p.skipWsAndComments()
+	sawNewline := p.skipWsAndComments()
+	segs := p.dottedKey(start, key)
+	if segs == nil {
+		segs = []string{key}
+	}
+	top := len(p.path) == 0
+	for j, seg := range segs {
+		if i, ok := p.dottedIndex(seg); ok && j > 0 {
+			p.pushIndex(i)
+		} else {
+			p.pushKey(seg)
+		}
+	}
+	if err := p.missingValue(key, eq, sawNewline); err != nil {
+		return "", nil, err
+	}
+	if p.keyPos != nil {
+		p.keyPos[formatPath(p.path)] = start
+	}
+	if p.docs != nil {
+		p.takeDoc(start)
+	}
+	p.noteItemStart(start)
+	valStart := p.here()
 	val, err := p.parseValue()
+	p.noteItemEnd()
+	p.path = p.path[:len(p.path)-len(segs)]
 	if err != nil {
 		return "", nil, err
 	}
-	if _, exists := seen[key]; exists {
+	if top && p.spans != nil {
+		*p.spans = append(*p.spans, Span{Key: key, Index: -1, Start: valStart.position(), End: p.here().position()})
+	}
+	if prev, exists := seen[key]; exists {
+		if merged, ok := p.resolveDuplicate(prev, val); ok {
+			return key, merged, nil
+		}
 		return "", nil, &ParseError{
-			Kind:     ParseErrorDuplicateKey,
-			Line:     p.line,
-			Column:   p.col,
-			EndLine:  p.line,
+			Kind:      ParseErrorDuplicateKey,
+			Line:      p.line,
+			Column:    p.col,
+			EndLine:   p.line,
 			EndColumn: p.col + 1,
-			Position: p.pos,
-			Message:  fmt.Sprintf("duplicate key %q", key),
-			Key:      key,
+			Position:  p.pos,
+			Message:   fmt.Sprintf("duplicate key %q", key),
+			Key:       key,
+			Path:      formatPath(p.path),
 		}
 	}
 	return key, val, nil
 }
 
+// missingValue reports a key whose '=', at eq, is followed by no value: by
+// a separator, a closing delimiter or the end of input, or by the next
+// key=value pair on a later line. It is the usual trace of a value deleted
+// with its '=' left behind.
+func (p *parser) missingValue(key string, eq nodePos, sawNewline bool) *ParseError {
+	c, ok := p.current()
+	switch {
+	case !ok:
+	case c == ',' || c == '}' || c == ']' || (c == ';' && p.opts.AllowSemicolons):
+	case sawNewline && p.objectMode():
+	default:
+		return nil
+	}
+	err := p.errAt(eq, fmt.Sprintf("expected value after '=' for key %q", key))
+	if !ok {
+		err.Kind = ParseErrorEOF
+	}
+	return err
+}
+
 // parseKey parses a bare or quoted key.
 func (p *parser) parseKey() (string, error) {
 	p.skipWsAndComments()
@@ -397,11 +1338,17 @@ func (p *parser) parseKey() (string, error) {
 	if c == '"' || c == '\'' {
 		return p.parseString(c)
 	}
+	if c == '=' {
+		return "", p.syntaxErr("missing key before '='")
+	}
 	// Bare key — scan bytes until a delimiter per SPEC §3.3.
 	start := p.pos
 	for p.pos < len(p.input) {
-		if isKeyDelimiter(p.input[p.pos]) {
-			break
+		if c := p.input[p.pos]; isKeyDelimiter(c) {
+			next, _ := p.peek(1)
+			if !extendsBareKey(c, next, p.opts.BareKeyExtraChars) {
+				break
+			}
 		}
 		p.advance()
 	}
@@ -418,41 +1365,173 @@ func (p *parser) parseValue() (Value, error) {
 	if !ok {
 		return nil, p.syntaxErr("expected value")
 	}
+	if p.valuePos != nil {
+		p.valuePos[formatPath(p.path)] = p.here()
+	}
+	if p.valueEnd != nil {
+		path := formatPath(p.path)
+		defer func() { p.valueEnd[path] = p.here() }()
+	}
+	if b, ok := p.extendedBoolean(); ok {
+		end := p.scalarTokenEnd()
+		if p.opts.ExtendedBooleans {
+			advanceN(p, end-p.pos)
+			return b, nil
+		}
+		if p.opts.ScalarResolver == nil {
+			word := string(p.input[p.pos:end])
+			return nil, p.syntaxErr(fmt.Sprintf("unquoted %s is not a value; quote it as %q, or set ParseOptions.ExtendedBooleans to read it as a boolean", word, word))
+		}
+	}
 	switch c {
 	case '"', '\'':
-		return p.parseString(c)
+		return p.parseStringValue()
 	case 'r', 'R':
-		next, ok := p.peek(1)
-		if ok && (next == '"' || next == '#') {
-			return p.parseRawString()
+		if p.atRawString() {
+			return p.parseStringValue()
+		}
+	case 'b':
+		if p.opts.AllowBase64 && matchesLiteral(p.input, p.pos, `b64"`) {
+			return p.parseBase64()
 		}
-		return nil, p.syntaxErr(fmt.Sprintf("unexpected character in value: %c", c))
 	case '[':
 		return p.parseArray()
 	case '{':
 		return p.parseNestedObject()
 	case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-		return p.parseNumber()
+		return p.resolveScalar(p.parseNumber)
 	case 't', 'f':
-		return p.parseBoolean()
+		return p.resolveScalar(p.parseBoolean)
 	case 'n':
-		return p.parseNull()
+		return p.resolveScalar(p.parseNull)
 	}
-	return nil, p.syntaxErr(fmt.Sprintf("unexpected character in value: %c", c))
+	return p.resolveScalar(func() (Value, error) {
+		return nil, p.syntaxErr(fmt.Sprintf("unexpected character in value: %c", c))
+	})
 }
 
-// parseString parses a double- or single-quoted string. Rejects literal
-// control chars and unknown escapes per SPEC §3.4.
-func (p *parser) parseString(quote byte) (string, error) {
+// parseBase64 parses a `b64"..."` byte-string literal (ParseOptions.AllowBase64).
+func (p *parser) parseBase64() (Value, error) {
+	start := p.here()
+	advanceN(p, 3) // b64
+	s, err := p.parseString('"')
+	if err != nil {
+		return nil, err
+	}
+	b, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, p.errAt(start, fmt.Sprintf("invalid base64 in b64 string: %v", err))
+	}
+	return b, nil
+}
+
+// atRawString reports whether a raw string (`r"..."`, `r#"..."#`) starts at
+// the current position.
+func (p *parser) atRawString() bool {
+	c, _ := p.current()
+	next, ok := p.peek(1)
+	return (c == 'r' || c == 'R') && ok && (next == '"' || next == '#')
+}
+
+// parseStringValue parses a quoted or raw string in value position. Under
+// ParseOptions.AllowStringConcat it also consumes any `+ "more"` parts that
+// follow, joining them at parse time.
+func (p *parser) parseStringValue() (Value, error) {
+	var sb strings.Builder
+	for {
+		var s string
+		var err error
+		if c, _ := p.current(); c == '"' || c == '\'' {
+			s, err = p.parseString(c)
+		} else if p.atRawString() {
+			s, err = p.parseRawString()
+		} else {
+			return nil, p.syntaxErr("only strings can be concatenated with '+'")
+		}
+		if err != nil {
+			return nil, err
+		}
+		sb.WriteString(s)
+		if !p.opts.AllowStringConcat {
+			break
+		}
+		// '+' can never start a value, so looking past newlines for one
+		// is unambiguous; without one, the separator is left in place.
+		save := p.here()
+		p.skipWsAndComments()
+		if c, ok := p.current(); !ok || c != '+' || p.openComment != nil {
+			p.pos, p.line, p.col = save.offset, save.line, save.col
+			break
+		}
+		p.advance() // +
+		p.skipWsAndComments()
+	}
+	return sb.String(), nil
+}
+
+// resolveScalar runs builtin and, when ParseOptions.ScalarResolver is set and
+// builtin did not consume the whole unquoted token, offers the token to the
+// resolver. If the resolver declines, builtin's result stands, including the
+// position it left the parser at.
+func (p *parser) resolveScalar(builtin func() (Value, error)) (Value, error) {
+	if p.opts.ScalarResolver == nil {
+		return builtin()
+	}
+	start := p.here()
+	end := p.scalarTokenEnd()
+	v, err := builtin()
+	if err == nil && p.pos == end {
+		return v, nil
+	}
+	after := p.here()
+	if end > start.offset {
+		if rv, ok := p.opts.ScalarResolver(string(p.input[start.offset:end])); ok {
+			p.pos, p.line, p.col = start.offset, start.line, start.col
+			advanceN(p, end-start.offset)
+			return rv, nil
+		}
+	}
+	p.pos, p.line, p.col = after.offset, after.line, after.col
+	return v, err
+}
+
+// scalarTokenEnd returns the offset just past the unquoted token starting at
+// the current position. Unlike a bare key, a token may contain '#' and '/'
+// (but not a comment opener), so colors and dates stay whole.
+func (p *parser) scalarTokenEnd() int {
+	i := p.pos
+	for ; i < len(p.input); i++ {
+		switch p.input[i] {
+		case ' ', '\t', '\n', '\r', '=', ',', '{', '}', '[', ']', '"', '\'':
+			return i
+		case ';':
+			if p.opts.AllowSemicolons {
+				return i
+			}
+		case '/':
+			if i+1 < len(p.input) && (p.input[i+1] == '/' || p.input[i+1] == '*') {
+				return i
+			}
+		}
+	}
+	return i
+}
+
+// parseString parses a double- or single-quoted string. Rejects literal
+// control chars and unknown escapes per SPEC §3.4. A backslash immediately
+// followed by a newline (LF or CRLF) is a line continuation and produces no
+// output.
+func (p *parser) parseString(quote byte) (string, error) {
 	quoteChar := quote
+	open := p.here()
 	p.advance() // opening quote
 	var sb strings.Builder
 	for {
 		c, ok := p.current()
 		if !ok {
-			return "", p.syntaxErr("unterminated string")
+			return "", p.unterminatedErr("string", open)
 		}
-		if c < 0x20 || c == 0x7f {
+		if c < 0x20 || (c == 0x7f && !p.strictJSON) {
 			return "", p.syntaxErr(fmt.Sprintf("literal control character 0x%02X in string; use an escape or a raw string", c))
 		}
 		if c == quoteChar {
@@ -460,10 +1539,21 @@ func (p *parser) parseString(quote byte) (string, error) {
 			return sb.String(), nil
 		}
 		if c == '\\' {
+			backslash := p.here()
 			p.advance()
 			esc, ok := p.current()
 			if !ok {
-				return "", p.syntaxErr("incomplete escape sequence")
+				// Point at the backslash: it is what swallowed the
+				// closing quote, if there was meant to be one.
+				err := p.errAt(backslash, "unterminated string: input ends after '\\'")
+				err.Kind = ParseErrorEOF
+				return "", err
+			}
+			if p.strictJSON && !strings.ContainsRune(`"\/bfnrtu`, rune(esc)) {
+				if esc == '\n' || esc == '\r' {
+					return "", p.syntaxErr("line continuations are not allowed in JSON")
+				}
+				return "", p.syntaxErr(fmt.Sprintf("escape \\%c is not allowed in JSON", esc))
 			}
 			p.advance()
 			switch esc {
@@ -485,22 +1575,42 @@ func (p *parser) parseString(quote byte) (string, error) {
 				sb.WriteByte('\'')
 			case '/':
 				sb.WriteByte('/')
+			case '\n':
+				// Line continuation: a backslash right before a newline
+				// elides the newline.
+			case '\r':
+				if n, ok := p.current(); !ok || n != '\n' {
+					return "", p.syntaxErr("line continuation backslash must be followed by a newline")
+				}
+				p.advance()
 			case 'x':
 				v, err := p.parseHexDigits(2, "\\x")
 				if err != nil {
 					return "", err
 				}
-				sb.WriteByte(byte(v))
+				// \xXX names the code point U+00XX, so \xe9 is "é" and
+				// the result stays valid UTF-8.
+				sb.WriteRune(rune(v))
 			case 'u':
 				v, err := p.parseHexDigits(4, "\\u")
 				if err != nil {
 					return "", err
 				}
 				if v >= 0xd800 && v <= 0xdfff {
-					return "", p.syntaxErr(fmt.Sprintf("surrogate code point U+%04X requires a pair; surrogate handling is not yet implemented", v))
+					r, err := p.parseSurrogatePair(v)
+					if err != nil {
+						return "", err
+					}
+					v = uint32(r)
 				}
 				sb.WriteRune(rune(v))
 			default:
+				if p.json5 {
+					if err := p.json5Escape(esc, &sb); err != nil {
+						return "", err
+					}
+					break
+				}
 				return "", p.syntaxErr(fmt.Sprintf("unknown escape \\%c", esc))
 			}
 			continue
@@ -527,8 +1637,31 @@ func (p *parser) parseHexDigits(count int, label string) (uint32, error) {
 	return v, nil
 }
 
+// parseSurrogatePair completes a \u escape whose value hi is a UTF-16
+// surrogate: hi must be a high surrogate followed directly by a \u escape
+// for a low one, as in JSON. It returns the code point the pair encodes.
+func (p *parser) parseSurrogatePair(hi uint32) (rune, error) {
+	if hi >= 0xdc00 {
+		return 0, p.syntaxErr(fmt.Sprintf("unpaired low surrogate U+%04X", hi))
+	}
+	if p.pos+1 >= len(p.input) || p.input[p.pos] != '\\' || p.input[p.pos+1] != 'u' {
+		return 0, p.syntaxErr(fmt.Sprintf("high surrogate U+%04X must be followed by a \\u low surrogate", hi))
+	}
+	p.advance()
+	p.advance()
+	lo, err := p.parseHexDigits(4, "\\u")
+	if err != nil {
+		return 0, err
+	}
+	if lo < 0xdc00 || lo > 0xdfff {
+		return 0, p.syntaxErr(fmt.Sprintf("high surrogate U+%04X must be followed by a \\u low surrogate, not U+%04X", hi, lo))
+	}
+	return utf16.DecodeRune(rune(hi), rune(lo)), nil
+}
+
 // parseRawString parses r"...", R"...", with optional # delimiters.
 func (p *parser) parseRawString() (string, error) {
+	open := p.here()
 	p.advance() // 'r' or 'R'
 	hashCount := 0
 	for {
@@ -555,7 +1688,9 @@ func (p *parser) parseRawString() (string, error) {
 		for p.pos < len(p.input) {
 			p.advance()
 		}
-		return "", p.syntaxErr(fmt.Sprintf("unterminated raw string (expected closing %q)", string(closing)))
+		err := p.unterminatedErr("raw string", open)
+		err.Message += fmt.Sprintf(" (expected closing %q)", string(closing))
+		return "", err
 	}
 	idx += start
 	value := string(p.input[start:idx])
@@ -564,9 +1699,27 @@ func (p *parser) parseRawString() (string, error) {
 	for p.pos < target {
 		p.advance()
 	}
+	if strings.HasSuffix(value, `\`) && !p.atValueEnd() {
+		// r"C:\"more": the backslash did not escape the quote, which ended
+		// the string early.
+		return "", p.errAt(open, fmt.Sprintf("raw string %s ends at the quote after its backslash; raw strings have no escapes, so write %s to include a quote", p.input[open.offset:target], rawHint(hashCount)))
+	}
 	return value, nil
 }
 
+// atValueEnd reports whether what follows a value may end it: the end of
+// input, whitespace, a separator, a closer, a comment or a '+'.
+func (p *parser) atValueEnd() bool {
+	c, ok := p.current()
+	return !ok || strings.IndexByte(" \t\r\n,;}]/+", c) >= 0
+}
+
+// rawHint shows the raw string form with one more '#' than hashCount.
+func rawHint(hashCount int) string {
+	fence := strings.Repeat("#", hashCount+1)
+	return "r" + fence + `"..."` + fence
+}
+
 func bytesIndex(haystack, needle []byte) int {
 	if len(needle) == 0 {
 		return 0
@@ -589,10 +1742,23 @@ func bytesIndex(haystack, needle []byte) int {
 // parseNumber parses integers, floats, hex/octal/binary literals with
 // underscores, exponents, and a leading minus — per SPEC §3.5.
 func (p *parser) parseNumber() (Value, error) {
+	start := p.pos
 	negative := false
 	if c, ok := p.current(); ok && c == '-' {
+		sign := p.here()
 		negative = true
 		p.advance()
+		if c, ok := p.current(); !ok || !isDigit(c) {
+			msg := "expected digits after '-'"
+			if c == ' ' || c == '\t' {
+				msg += "; a sign cannot be separated from its number"
+			}
+			err := p.errAt(sign, msg)
+			if !ok {
+				err.Kind = ParseErrorEOF
+			}
+			return nil, err
+		}
 	}
 	// Radix detection. Lowercase prefixes only.
 	var radix int
@@ -625,7 +1791,21 @@ func (p *parser) parseNumber() (Value, error) {
 			return nil, err
 		}
 		literal = digits
+		if c, _ := p.current(); radix == 16 && (c == '.' || c == 'p' || c == 'P') {
+			if !p.opts.AllowHexFloat {
+				return nil, p.syntaxErr("hexadecimal floating-point numbers need ParseOptions.AllowHexFloat")
+			}
+			if literal, err = p.scanHexFloatTail(literal); err != nil {
+				return nil, err
+			}
+			radix, isFloat = 0, true
+		}
 	} else {
+		if c, _ := p.current(); c == '0' && p.opts.StrictNumbers {
+			if next, ok := p.peek(1); ok && (next == '_' || (next >= '0' && next <= '9')) {
+				return nil, p.syntaxErr("leading zeros are not allowed in numbers")
+			}
+		}
 		intPart, err := p.scanDecDigits()
 		if err != nil {
 			return nil, err
@@ -668,6 +1848,24 @@ func (p *parser) parseNumber() (Value, error) {
 		signed = "-" + literal
 	}
 
+	if p.opts.KeepNumberLiterals {
+		return Number(p.input[start:p.pos]), nil
+	}
+	if p.opts.UseNumber {
+		switch radix {
+		case 16:
+			literal = "0x" + literal
+		case 8:
+			literal = "0o" + literal
+		case 2:
+			literal = "0b" + literal
+		}
+		if negative {
+			literal = "-" + literal
+		}
+		return Number(literal), nil
+	}
+
 	if radix != 0 {
 		// Parse as big int to handle large values, then convert.
 		bi := new(big.Int)
@@ -685,6 +1883,9 @@ func (p *parser) parseNumber() (Value, error) {
 		if bi.IsUint64() {
 			return bi.Uint64(), nil
 		}
+		if p.opts.BigNumbers {
+			return bi, nil
+		}
 		f, _ := new(big.Float).SetInt(bi).Float64()
 		return f, nil
 	}
@@ -697,17 +1898,37 @@ func (p *parser) parseNumber() (Value, error) {
 		if u, err := strconv.ParseUint(signed, 10, 64); err == nil {
 			return u, nil
 		}
+		if p.opts.BigNumbers {
+			if bi, ok := new(big.Int).SetString(signed, 10); ok {
+				return bi, nil
+			}
+		}
 	}
 	f, err := strconv.ParseFloat(signed, 64)
 	if err != nil {
+		if p.opts.BigNumbers && errors.Is(err, strconv.ErrRange) {
+			// ~3.33 bits per decimal digit keeps every digit of the literal.
+			prec := uint(len(signed))*4 + 64
+			if bf, _, err := big.ParseFloat(signed, 10, prec, big.ToNearestEven); err == nil {
+				// Printing a number grows costly with its exponent, and
+				// no real value needs one beyond about ±10000.
+				if exp := bf.MantExp(nil); exp > maxBigBinaryExp || exp < -maxBigBinaryExp {
+					return nil, p.syntaxErr(fmt.Sprintf("number out of range: %s", signed))
+				}
+				return bf, nil
+			}
+		}
 		return nil, p.syntaxErr(fmt.Sprintf("could not parse number: %s", signed))
 	}
 	return f, nil
 }
 
-// scanDecDigits scans a run of decimal digits with Rust-style underscores.
+// scanDecDigits scans a run of decimal digits with Rust-style underscores:
+// each underscore must sit between two digits (SPEC §3.5). Errors point at
+// the misplaced underscore.
 func (p *parser) scanDecDigits() (string, error) {
 	var sb strings.Builder
+	var under nodePos // the last underscore
 	lastWasUnder := false
 	hasDigit := false
 	for p.pos < len(p.input) {
@@ -721,6 +1942,7 @@ func (p *parser) scanDecDigits() (string, error) {
 			if !hasDigit || lastWasUnder {
 				return "", p.syntaxErr("invalid underscore placement in number")
 			}
+			under = p.here()
 			lastWasUnder = true
 			p.advance()
 		} else {
@@ -731,13 +1953,48 @@ func (p *parser) scanDecDigits() (string, error) {
 		return "", p.syntaxErr("number requires at least one digit")
 	}
 	if lastWasUnder {
-		return "", p.syntaxErr("number cannot end with underscore")
+		return "", p.errAt(under, "number cannot end with underscore")
 	}
 	return sb.String(), nil
 }
 
+// scanHexFloatTail scans the fraction and the required binary exponent of
+// a hexadecimal float (ParseOptions.AllowHexFloat) whose integer digits,
+// intPart, have been read, and returns the whole literal in the form
+// strconv.ParseFloat takes: 0x1.8p3.
+func (p *parser) scanHexFloatTail(intPart string) (string, error) {
+	literal := "0x" + intPart
+	if c, _ := p.current(); c == '.' {
+		p.advance()
+		c, _ = p.current()
+		if _, ok := hexDigit(c); !ok {
+			return "", p.syntaxErr("expected hex digits after '.' in hexadecimal float")
+		}
+		frac, err := p.scanRadixDigits(16)
+		if err != nil {
+			return "", err
+		}
+		literal += "." + frac
+	}
+	if c, _ := p.current(); c != 'p' && c != 'P' {
+		return "", p.syntaxErr("hexadecimal float requires a 'p' exponent, as in 0x1.8p3")
+	}
+	p.advance()
+	exp := "p"
+	if sign, ok := p.current(); ok && (sign == '+' || sign == '-') {
+		exp += string(sign)
+		p.advance()
+	}
+	digits, err := p.scanDecDigits()
+	if err != nil {
+		return "", err
+	}
+	return literal + exp + digits, nil
+}
+
 func (p *parser) scanRadixDigits(radix int) (string, error) {
 	var sb strings.Builder
+	var under nodePos // the last underscore
 	lastWasUnder := false
 	hasDigit := false
 	for p.pos < len(p.input) {
@@ -745,7 +2002,7 @@ func (p *parser) scanRadixDigits(radix int) (string, error) {
 		ok := false
 		switch radix {
 		case 16:
-			ok = (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+			_, ok = hexDigit(c)
 		case 8:
 			ok = c >= '0' && c <= '7'
 		case 2:
@@ -760,6 +2017,7 @@ func (p *parser) scanRadixDigits(radix int) (string, error) {
 			if !hasDigit || lastWasUnder {
 				return "", p.syntaxErr("invalid underscore placement in number")
 			}
+			under = p.here()
 			lastWasUnder = true
 			p.advance()
 		} else {
@@ -770,7 +2028,7 @@ func (p *parser) scanRadixDigits(radix int) (string, error) {
 		return "", p.syntaxErr("number requires at least one digit after radix prefix")
 	}
 	if lastWasUnder {
-		return "", p.syntaxErr("number cannot end with underscore")
+		return "", p.errAt(under, "number cannot end with underscore")
 	}
 	return sb.String(), nil
 }
@@ -787,6 +2045,27 @@ func (p *parser) parseBoolean() (Value, error) {
 	return nil, p.syntaxErr("invalid boolean value")
 }
 
+// extendedBoolean reports whether the unquoted token at the current
+// position is one of the words of ParseOptions.ExtendedBooleans, and its
+// value.
+func (p *parser) extendedBoolean() (bool, bool) {
+	switch p.input[p.pos] | 0x20 { // lower case
+	case 'y', 'n', 'o':
+	default:
+		return false, false
+	}
+	word := p.input[p.pos:p.scalarTokenEnd()]
+	for _, w := range [...]struct {
+		word  string
+		value bool
+	}{{"yes", true}, {"on", true}, {"no", false}, {"off", false}} {
+		if bytes.EqualFold(word, []byte(w.word)) {
+			return w.value, true
+		}
+	}
+	return false, false
+}
+
 func (p *parser) parseNull() (Value, error) {
 	if matchesLiteral(p.input, p.pos, "null") {
 		advanceN(p, 4)
@@ -814,32 +2093,54 @@ func matchesLiteral(input []byte, pos int, lit string) bool {
 }
 
 func (p *parser) parseArray() (Value, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	open := p.here()
 	p.advance() // [
 	arr := Array{}
 	p.skipWsAndComments()
 	for {
 		c, ok := p.current()
 		if !ok {
-			return nil, p.syntaxErr("unterminated array")
+			if p.autoClose("array", open) {
+				return arr, nil
+			}
+			return nil, p.unterminatedErr("array", open)
 		}
 		if c == ']' {
+			p.noteClose()
 			p.advance()
 			return arr, nil
 		}
+		if c == '}' {
+			return nil, p.mismatchErr(']', "array", open)
+		}
+		p.pushIndex(len(arr))
+		p.noteItemStart(p.here())
 		val, err := p.parseValue()
+		p.noteItemEnd()
+		p.pop()
 		if err != nil {
 			return nil, err
 		}
 		arr = append(arr, val)
 		sawNewline, sawComma := p.skipInterItemSeparator()
-		if c, ok := p.current(); ok && c == ']' {
+		c, ok = p.current()
+		switch {
+		case !ok:
+			if p.autoClose("array", open) {
+				return arr, nil
+			}
+			return nil, p.unterminatedErr("array", open)
+		case c == ']':
+			p.noteClose()
 			p.advance()
 			return arr, nil
-		}
-		if !ok {
-			return nil, p.syntaxErr("unterminated array")
-		}
-		if !sawNewline && !sawComma {
+		case c == '}':
+			return nil, p.mismatchErr(']', "array", open)
+		case !sawNewline && !sawComma:
 			return nil, p.syntaxErr("items on the same line must be separated by a comma")
 		}
 	}
@@ -861,6 +2162,19 @@ func hexDigit(c byte) (uint32, bool) {
 	return 0, false
 }
 
+// isDecimalRun reports whether s is non-empty and all ASCII digits.
+func isDecimalRun(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
 func isAsciiAlphanumeric(c byte) bool {
 	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
 }
@@ -879,34 +2193,134 @@ func isKeyDelimiter(b byte) bool {
 	return false
 }
 
+// extendsBareKey reports whether the delimiter b, followed by next (0 at
+// the end), continues a bare key under a BareKeyExtraChars setting of
+// extra.
+func extendsBareKey(b, next byte, extra string) bool {
+	switch b {
+	case '/':
+		return next != '/' && next != '*' && strings.IndexByte(extra, b) >= 0
+	case '#':
+		return strings.IndexByte(extra, b) >= 0
+	}
+	return false
+}
+
 // ============================================================================
 // Serializer
 // ============================================================================
 
 // Serialize produces compact JHON output: no spaces around =, no spaces after
 // commas, no trailing commas.
+//
+// Nil values are handled without panicking: a nil interface and any typed
+// nil pointer, map or slice (other than Object and Array) serialize as
+// null. A nil Object is empty and a nil Array is `[]`, just as their empty
+// counterparts.
+//
+// Serialize output, compact or pretty, always parses back to a value Equal
+// to v, except where SPEC §7.1 says otherwise — an empty container or null
+// at the top level parses as null, and a top-level scalar as an array of
+// one — and for values Parse does not produce: NaN and infinities, which
+// JHON cannot write, and []byte, which parses back as an Array of its byte
+// values, or with SerializeOptions.Base64 needs ParseOptions.AllowBase64.
+// SerializeOptions.Color output is not meant to be parsed.
 func Serialize(v Value) string {
 	return SerializeWithOptions(v, SerializeOptions{})
 }
 
 // SerializeWithOptions produces compact or pretty JHON output.
-// When opts.Indent is non-empty, the output is pretty-printed via the
-// inline-aware path: at MaxInlineWidth=0 nothing inlines (every non-empty
-// container lands in wrapper_multi with symmetric multi-line indent); at
-// MaxInlineWidth>0 short containers inline as `{ k = v, ... }` / `[ a, b, ... ]`.
-// The older legacy pretty path had an asymmetric-indent bug for objects
-// nested in arrays; routing both modes through the inline-aware path
-// eliminates that bug.
+// When opts.Indent is non-empty (or IndentWidth/UseTabs fill it in), the
+// output is pretty-printed via the inline-aware path: at MaxInlineWidth=0
+// nothing inlines (every non-empty container lands in wrapper_multi with
+// symmetric multi-line indent); at MaxInlineWidth>0 short containers
+// inline as `{ k = v, ... }` / `[ a, b, ... ]`.
+// The older legacy pretty path, whose depth arithmetic broke for objects and
+// arrays nested in arrays, has been removed.
 func SerializeWithOptions(v Value, opts SerializeOptions) string {
+	opts = resolveIndent(opts)
+	v, _ = normalizeValue(v)
+	if opts.SortArrays {
+		v = sortArrays(v)
+	}
 	var sb strings.Builder
 	if opts.Indent != "" {
 		serializeTopPrettyInline(v, opts, &sb)
 	} else {
 		serializeTopCompact(v, opts, &sb)
 	}
+	if opts.VersionHeader {
+		return withVersionHeader(sb.String())
+	}
 	return sb.String()
 }
 
+// resolveIndent fills in Indent from IndentWidth or UseTabs, so the rest of
+// the serializer only has to look at Indent to pick pretty mode.
+func resolveIndent(opts SerializeOptions) SerializeOptions {
+	if opts.Indent == "" {
+		switch {
+		case opts.UseTabs:
+			opts.Indent = "\t"
+		case opts.IndentWidth > 0:
+			opts.Indent = strings.Repeat(" ", opts.IndentWidth)
+		}
+	}
+	return opts
+}
+
+// normalizeValue rewrites the plain map[string]interface{} and []interface{}
+// containers that encoding/json produces into Object and Array, so such data
+// serializes exactly as the equivalent parsed tree would. Containers are only
+// copied when something beneath them changed; changed reports whether v was
+// rewritten.
+func normalizeValue(v Value) (out Value, changed bool) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		obj := make(Object, len(val))
+		for k, el := range val {
+			obj[k], _ = normalizeValue(el)
+		}
+		return obj, true
+	case []interface{}:
+		arr := make(Array, len(val))
+		for i, el := range val {
+			arr[i], _ = normalizeValue(el)
+		}
+		return arr, true
+	case Object:
+		var obj Object
+		for k, el := range val {
+			if n, ok := normalizeValue(el); ok {
+				if obj == nil {
+					obj = make(Object, len(val))
+					for k2, el2 := range val {
+						obj[k2] = el2
+					}
+				}
+				obj[k] = n
+			}
+		}
+		if obj != nil {
+			return obj, true
+		}
+	case Array:
+		var arr Array
+		for i, el := range val {
+			if n, ok := normalizeValue(el); ok {
+				if arr == nil {
+					arr = append(Array(nil), val...)
+				}
+				arr[i] = n
+			}
+		}
+		if arr != nil {
+			return arr, true
+		}
+	}
+	return v, false
+}
+
 // serializeTopCompact handles top-level serialization per SPEC §2:
 //   - empty containers and nil emit nothing (the "Empty" form);
 //   - top-level arrays emit bare (no surrounding []);
@@ -931,68 +2345,6 @@ func serializeTopCompact(v Value, opts SerializeOptions, sb *strings.Builder) {
 	}
 }
 
-// serializeTopPretty mirrors serializeTopCompact for pretty mode.
-func serializeTopPretty(v Value, opts SerializeOptions, sb *strings.Builder) {
-	switch val := v.(type) {
-	case Array:
-		if len(val) == 0 {
-			return
-		}
-		serializeTopArrayPretty(val, opts, sb)
-	case Object:
-		if len(val) == 0 {
-			return
-		}
-		serializePretty(v, opts, 0, false, sb)
-	case nil:
-		return
-	default:
-		serializePretty(v, opts, 0, false, sb)
-	}
-}
-
-// serializeTopArrayPretty emits a top-level implicit array (no surrounding []).
-// Each element appears on its own line at column 0; object/array literals keep
-// their braces/brackets since they are array elements, not the implicit form.
-func serializeTopArrayPretty(arr Array, opts SerializeOptions, sb *strings.Builder) {
-	indent := opts.Indent
-	if indent == "" {
-		indent = "  "
-	}
-	first := true
-	for _, v := range arr {
-		if !first {
-			sb.WriteByte('\n')
-		}
-		first = false
-		switch inner := v.(type) {
-		case Object:
-			if len(inner) == 0 {
-				sb.WriteString("{}")
-				continue
-			}
-			// Object element: braces required, body at indent 1, no leading indent.
-			sb.WriteString("{\n")
-			keys := objectKeys(inner, opts.SortKeys)
-			firstPair := true
-			for _, k := range keys {
-				if !firstPair {
-					sb.WriteByte('\n')
-				}
-				firstPair = false
-				sb.WriteString(indent)
-				serializeKey(k, sb)
-				sb.WriteString(" = ")
-				serializePretty(inner[k], opts, 1, false, sb)
-			}
-			sb.WriteByte('\n')
-			sb.WriteByte('}')
-		default:
-			serializePretty(v, opts, 0, false, sb)
-		}
-	}
-}
-
 // SerializePretty is a convenience wrapper that forces pretty mode.
 func SerializePretty(v Value, indent string) string {
 	return SerializeWithOptions(v, SerializeOptions{Indent: indent})
@@ -1011,39 +2363,23 @@ func serializeCompact(v Value, opts SerializeOptions, sb *strings.Builder) {
 			return
 		}
 		serializeArrayCompact(val, opts, sb)
-	case string:
-		serializeString(val, sb)
-	case int64:
-		sb.WriteString(strconv.FormatInt(val, 10))
-	case uint64:
-		sb.WriteString(strconv.FormatUint(val, 10))
-	case int:
-		sb.WriteString(strconv.Itoa(val))
-	case float64:
-		serializeFloat(val, sb)
-	case bool:
-		if val {
-			sb.WriteString("true")
-		} else {
-			sb.WriteString("false")
-		}
-	case nil:
-		sb.WriteString("null")
 	default:
-		// Best-effort fallback.
-		sb.WriteString(fmt.Sprintf("%v", val))
+		if !serializeScalar(v, opts, sb) {
+			// Best-effort fallback.
+			sb.WriteString(fmt.Sprintf("%v", val))
+		}
 	}
 }
 
 func serializeObjectCompact(obj Object, opts SerializeOptions, sb *strings.Builder) {
-	keys := objectKeys(obj, opts.SortKeys)
+	keys := objectKeys(obj, opts)
 	first := true
 	for _, k := range keys {
 		if !first {
 			sb.WriteByte(',')
 		}
 		first = false
-		serializeKey(k, sb)
+		serializeKey(k, opts, sb)
 		sb.WriteByte('=')
 		v := obj[k]
 		if inner, ok := v.(Object); ok {
@@ -1089,131 +2425,14 @@ func serializeArrayContentsCompact(arr Array, opts SerializeOptions, sb *strings
 	}
 }
 
-func serializePretty(v Value, opts SerializeOptions, depth int, inArray bool, sb *strings.Builder) {
-	switch val := v.(type) {
-	case Object:
-		if len(val) == 0 {
-			if inArray || depth > 0 {
-				sb.WriteString("{}")
-			}
-			return
-		}
-		serializeObjectPretty(val, opts, depth, inArray, sb)
-	case Array:
-		if len(val) == 0 {
-			sb.WriteString("[]")
-			return
-		}
-		serializeArrayPretty(val, opts, depth, sb)
-	case string:
-		serializeString(val, sb)
-	case int64:
-		sb.WriteString(strconv.FormatInt(val, 10))
-	case uint64:
-		sb.WriteString(strconv.FormatUint(val, 10))
-	case int:
-		sb.WriteString(strconv.Itoa(val))
-	case float64:
-		serializeFloat(val, sb)
-	case bool:
-		if val {
-			sb.WriteString("true")
-		} else {
-			sb.WriteString("false")
-		}
-	case nil:
-		sb.WriteString("null")
-	}
-}
-
-func serializeObjectPretty(obj Object, opts SerializeOptions, depth int, inArray bool, sb *strings.Builder) {
-	indent := opts.Indent
-	if indent == "" {
-		indent = "  "
-	}
-	if inArray {
-		for i := 0; i < depth+1; i++ {
-			sb.WriteString(indent)
-		}
-		sb.WriteString("{\n")
-	} else if depth > 0 {
-		sb.WriteString("{\n")
-	}
-
-	keys := objectKeys(obj, opts.SortKeys)
-	first := true
-	for _, k := range keys {
-		if !first {
-			sb.WriteByte('\n')
-		}
-		first = false
-		var innerDepth int
-		switch {
-		case inArray:
-			innerDepth = depth + 2
-		case depth == 0:
-			innerDepth = 0
-		default:
-			innerDepth = depth
-		}
-		for i := 0; i < innerDepth; i++ {
-			sb.WriteString(indent)
-		}
-		serializeKey(k, sb)
-		sb.WriteString(" = ")
-		serializePretty(obj[k], opts, depth+1, false, sb)
-	}
-
-	if inArray {
-		sb.WriteByte('\n')
-		for i := 0; i < depth+1; i++ {
-			sb.WriteString(indent)
-		}
-		sb.WriteByte('}')
-	} else if depth > 0 {
-		sb.WriteByte('\n')
-		for i := 0; i < depth-1; i++ {
-			sb.WriteString(indent)
-		}
-		sb.WriteByte('}')
-	}
-}
-
-func serializeArrayPretty(arr Array, opts SerializeOptions, depth int, sb *strings.Builder) {
-	indent := opts.Indent
-	if indent == "" {
-		indent = "  "
-	}
-	sb.WriteString("[\n")
-	first := true
-	for _, v := range arr {
-		if !first {
-			sb.WriteByte('\n')
-		}
-		first = false
-		if _, isObj := v.(Object); isObj {
-			serializePretty(v, opts, depth, true, sb)
-		} else {
-			for i := 0; i < depth+1; i++ {
-				sb.WriteString(indent)
-			}
-			serializePretty(v, opts, depth+1, false, sb)
-		}
-	}
-	sb.WriteByte('\n')
-	for i := 0; i < depth; i++ {
-		sb.WriteString(indent)
-	}
-	sb.WriteByte(']')
-}
-
 // ============================================================================
-// Inline-aware pretty printer (`MaxInlineWidth > 0` mode).
+// Inline-aware pretty printer — the only pretty path.
 //
 // Short containers render as `{ k = v, ... }` / `[ a, b, ... ]` on a single
 // line; medium containers use a 3-line wrapper with joined children on one
-// line; long containers expand one child per line. Separate from the legacy
-// `serializePretty` path, which is unchanged.
+// line; long containers expand one child per line. Every level indents one
+// step from its parent whatever the parent is, so arrays nested directly in
+// arrays need no special casing.
 // ============================================================================
 
 func serializeTopPrettyInline(v Value, opts SerializeOptions, sb *strings.Builder) {
@@ -1223,12 +2442,13 @@ func serializeTopPrettyInline(v Value, opts SerializeOptions, sb *strings.Builde
 			return
 		}
 		// Top-level object: keys at column 0, no surrounding braces.
-		keys := objectKeys(val, opts.SortKeys)
+		keys := objectKeys(val, opts)
+		written := alignedKeys(keys, opts)
 		for i, k := range keys {
 			if i > 0 {
 				sb.WriteByte('\n')
 			}
-			serializeKey(k, sb)
+			sb.WriteString(written[i])
 			sb.WriteString(" = ")
 			renderPrettyInline(val[k], opts, 0, sb)
 		}
@@ -1251,31 +2471,7 @@ func serializeTopPrettyInline(v Value, opts SerializeOptions, sb *strings.Builde
 }
 
 func renderPrettyInline(v Value, opts SerializeOptions, depth int, sb *strings.Builder) {
-	switch val := v.(type) {
-	case string:
-		serializeString(val, sb)
-		return
-	case int64:
-		sb.WriteString(strconv.FormatInt(val, 10))
-		return
-	case uint64:
-		sb.WriteString(strconv.FormatUint(val, 10))
-		return
-	case int:
-		sb.WriteString(strconv.Itoa(val))
-		return
-	case float64:
-		serializeFloat(val, sb)
-		return
-	case bool:
-		if val {
-			sb.WriteString("true")
-		} else {
-			sb.WriteString("false")
-		}
-		return
-	case nil:
-		sb.WriteString("null")
+	if serializeScalar(v, opts, sb) {
 		return
 	}
 
@@ -1289,17 +2485,32 @@ func renderPrettyInline(v Value, opts SerializeOptions, depth int, sb *strings.B
 			sb.WriteString("{}")
 			return
 		}
-		inline := inlineValue(v, opts)
-		if len(inline) <= opts.MaxInlineWidth {
+		limit := opts.MaxInlineWidth
+		if opts.InlineObjectMaxLen > 0 {
+			limit = opts.InlineObjectMaxLen
+		}
+		keys := objectKeys(obj, opts)
+		if inline, ok := fitInline(limit, opts, func(budget int, b *strings.Builder) bool {
+			b.WriteString("{ ")
+			if !writeInlineMembers(obj, keys, opts, budget, b) {
+				return false
+			}
+			b.WriteString(" }")
+			return true
+		}); ok {
 			sb.WriteString(inline)
 			return
 		}
-		joined := joinedObjectChildren(obj, opts)
-		if len(joined) > 0 && len(joined) <= opts.MaxInlineWidth {
+		if joined, ok := fitInline(limit, opts, func(budget int, b *strings.Builder) bool {
+			return writeInlineMembers(obj, keys, opts, budget, b)
+		}); ok && len(joined) > 0 {
 			sb.WriteByte('{')
 			sb.WriteByte('\n')
 			writeIndent(sb, indent, depth+1)
 			sb.WriteString(joined)
+			if opts.TrailingComma {
+				sb.WriteByte(',')
+			}
 			sb.WriteByte('\n')
 			writeIndent(sb, indent, depth)
 			sb.WriteByte('}')
@@ -1307,14 +2518,17 @@ func renderPrettyInline(v Value, opts SerializeOptions, depth int, sb *strings.B
 		}
 		// wrapper_multi
 		sb.WriteByte('{')
-		keys := objectKeys(obj, opts.SortKeys)
-		for _, k := range keys {
+		written := alignedKeys(keys, opts)
+		for i, k := range keys {
 			sb.WriteByte('\n')
 			writeIndent(sb, indent, depth+1)
-			serializeKey(k, sb)
+			sb.WriteString(written[i])
 			sb.WriteString(" = ")
 			renderPrettyInline(obj[k], opts, depth+1, sb)
 		}
+		if opts.TrailingComma {
+			sb.WriteByte(',')
+		}
 		sb.WriteByte('\n')
 		writeIndent(sb, indent, depth)
 		sb.WriteByte('}')
@@ -1326,17 +2540,22 @@ func renderPrettyInline(v Value, opts SerializeOptions, depth int, sb *strings.B
 			sb.WriteString("[]")
 			return
 		}
-		inline := inlineValue(v, opts)
-		if len(inline) <= opts.MaxInlineWidth {
+		if inline, ok := fitInline(opts.MaxInlineWidth, opts, func(budget int, b *strings.Builder) bool {
+			return writeInline(v, opts, budget, b)
+		}); ok {
 			sb.WriteString(inline)
 			return
 		}
-		joined := joinedArrayChildren(arr, opts)
-		if len(joined) > 0 && len(joined) <= opts.MaxInlineWidth {
+		if joined, ok := fitInline(opts.MaxInlineWidth, opts, func(budget int, b *strings.Builder) bool {
+			return writeInlineElements(arr, opts, budget, b)
+		}); ok && len(joined) > 0 {
 			sb.WriteByte('[')
 			sb.WriteByte('\n')
 			writeIndent(sb, indent, depth+1)
 			sb.WriteString(joined)
+			if opts.TrailingComma {
+				sb.WriteByte(',')
+			}
 			sb.WriteByte('\n')
 			writeIndent(sb, indent, depth)
 			sb.WriteByte(']')
@@ -1349,6 +2568,9 @@ func renderPrettyInline(v Value, opts SerializeOptions, depth int, sb *strings.B
 			writeIndent(sb, indent, depth+1)
 			renderPrettyInline(el, opts, depth+1, sb)
 		}
+		if opts.TrailingComma {
+			sb.WriteByte(',')
+		}
 		sb.WriteByte('\n')
 		writeIndent(sb, indent, depth)
 		sb.WriteByte(']')
@@ -1356,137 +2578,400 @@ func renderPrettyInline(v Value, opts SerializeOptions, depth int, sb *strings.B
 	}
 }
 
+// alignedKeys returns keys as serializeKey writes them, padded with spaces
+// to the widest of them when opts.AlignEquals is set.
+func alignedKeys(keys []string, opts SerializeOptions) []string {
+	written := make([]string, len(keys))
+	width := 0
+	for i, k := range keys {
+		var sb strings.Builder
+		serializeKey(k, opts, &sb)
+		written[i] = sb.String()
+		if n := utf8.RuneCountInString(stripColor(written[i])); n > width {
+			width = n
+		}
+	}
+	if opts.AlignEquals {
+		for i, w := range written {
+			written[i] = w + strings.Repeat(" ", width-utf8.RuneCountInString(stripColor(w)))
+		}
+	}
+	return written
+}
+
+// stripColor removes the escape codes SerializeOptions.Color adds, so that
+// widths can be measured. The serializer escapes ESC in strings, so any
+// ESC in its output starts one of these codes.
+func stripColor(s string) string {
+	if strings.IndexByte(s, 0x1b) < 0 {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == 0x1b {
+			for i < len(s) && s[i] != 'm' {
+				i++
+			}
+			continue
+		}
+		sb.WriteByte(s[i])
+	}
+	return sb.String()
+}
+
+// colors returns the palette of SerializeOptions.Color.
+func (o SerializeOptions) colors() *ColorScheme {
+	if o.ColorScheme != nil {
+		return o.ColorScheme
+	}
+	return &DefaultColorScheme
+}
+
+// scalar returns the color of the scalar v, written as text.
+func (s *ColorScheme) scalar(v Value, text string) string {
+	switch v.(type) {
+	case string, []byte:
+		return s.String
+	case bool:
+		return s.Bool
+	}
+	if text == "null" {
+		return s.Null
+	}
+	return s.Number
+}
+
+// writeColored writes text wrapped in color, unless color is empty.
+func writeColored(sb *strings.Builder, color, text string) {
+	if color == "" {
+		sb.WriteString(text)
+		return
+	}
+	sb.WriteString(color)
+	sb.WriteString(text)
+	sb.WriteString(colorReset)
+}
+
 func writeIndent(sb *strings.Builder, indent string, n int) {
 	for i := 0; i < n; i++ {
 		sb.WriteString(indent)
 	}
 }
 
-// inlineValue renders a value as a single-line string with outer brackets
-// for containers and `{ k = v, ... }` / `[ a, b, ... ]` spacing.
-func inlineValue(v Value, opts SerializeOptions) string {
+// fitInline runs write on a fresh builder and returns its text when that is
+// at most limit columns wide. write gets a byte budget so that it can give
+// up as soon as the text cannot fit, rather than render a large subtree in
+// full only to measure it. Escape codes make Color output longer in bytes
+// than in columns, so there it gets no budget (-1).
+func fitInline(limit int, opts SerializeOptions, write func(budget int, sb *strings.Builder) bool) (string, bool) {
+	if limit <= 0 {
+		return "", false // nothing non-empty fits
+	}
+	budget := limit
+	if opts.Color {
+		budget = -1
+	}
+	var sb strings.Builder
+	if !write(budget, &sb) {
+		return "", false
+	}
+	s := sb.String()
+	return s, len(stripColor(s)) <= limit
+}
+
+// writeInline writes v as a single line, with outer brackets for containers
+// and `{ k = v, ... }` / `[ a, b, ... ]` spacing. It reports false, leaving
+// the text unfinished, once sb is longer than budget bytes; a negative
+// budget never stops it.
+func writeInline(v Value, opts SerializeOptions, budget int, sb *strings.Builder) bool {
 	switch val := v.(type) {
 	case Object:
 		if len(val) == 0 {
-			return "{}"
+			sb.WriteString("{}")
+			break
 		}
-		var sb strings.Builder
 		sb.WriteString("{ ")
-		keys := objectKeys(val, opts.SortKeys)
-		for i, k := range keys {
-			if i > 0 {
-				sb.WriteString(", ")
-			}
-			serializeKey(k, &sb)
-			sb.WriteString(" = ")
-			sb.WriteString(inlineValue(val[k], opts))
+		if !writeInlineMembers(val, objectKeys(val, opts), opts, budget, sb) {
+			return false
 		}
 		sb.WriteString(" }")
-		return sb.String()
 	case Array:
 		if len(val) == 0 {
-			return "[]"
+			sb.WriteString("[]")
+			break
 		}
-		var sb strings.Builder
 		sb.WriteString("[ ")
-		for i, el := range val {
-			if i > 0 {
-				sb.WriteString(", ")
-			}
-			sb.WriteString(inlineValue(el, opts))
+		if !writeInlineElements(val, opts, budget, sb) {
+			return false
 		}
 		sb.WriteString(" ]")
-		return sb.String()
-	case string:
-		var sb strings.Builder
-		serializeString(val, &sb)
-		return sb.String()
-	case int64:
-		return strconv.FormatInt(val, 10)
-	case uint64:
-		return strconv.FormatUint(val, 10)
-	case int:
-		return strconv.Itoa(val)
-	case float64:
-		var sb strings.Builder
-		serializeFloat(val, &sb)
-		return sb.String()
-	case bool:
-		if val {
-			return "true"
-		}
-		return "false"
-	case nil:
-		return "null"
+	default:
+		serializeScalar(v, opts, sb)
 	}
-	return ""
+	return budget < 0 || sb.Len() <= budget
 }
 
-func joinedObjectChildren(obj Object, opts SerializeOptions) string {
-	var sb strings.Builder
-	keys := objectKeys(obj, opts.SortKeys)
+// writeInlineMembers writes the entries of obj, in the order of keys, as
+// `k = v, ...`, the inside of its inline form, under writeInline's budget.
+func writeInlineMembers(obj Object, keys []string, opts SerializeOptions, budget int, sb *strings.Builder) bool {
 	for i, k := range keys {
 		if i > 0 {
 			sb.WriteString(", ")
 		}
-		serializeKey(k, &sb)
+		serializeKey(k, opts, sb)
 		sb.WriteString(" = ")
-		sb.WriteString(inlineValue(obj[k], opts))
+		if budget >= 0 && sb.Len() > budget {
+			return false
+		}
+		if !writeInline(obj[k], opts, budget, sb) {
+			return false
+		}
 	}
-	return sb.String()
+	return true
 }
 
-func joinedArrayChildren(arr Array, opts SerializeOptions) string {
-	var sb strings.Builder
+// writeInlineElements writes the elements of arr as `a, b, ...`, the inside
+// of its inline form, under writeInline's budget.
+func writeInlineElements(arr Array, opts SerializeOptions, budget int, sb *strings.Builder) bool {
 	for i, el := range arr {
 		if i > 0 {
 			sb.WriteString(", ")
 		}
-		sb.WriteString(inlineValue(el, opts))
+		if budget >= 0 && sb.Len() > budget {
+			return false
+		}
+		if !writeInline(el, opts, budget, sb) {
+			return false
+		}
 	}
-	return sb.String()
+	return true
 }
 
-func objectKeys(obj Object, sortKeys bool) []string {
+// sortArrays returns v with every set-like array sorted, per
+// SerializeOptions.SortArrays. Containers are copied, never sorted in place.
+func sortArrays(v Value) Value {
+	switch val := v.(type) {
+	case Object:
+		out := make(Object, len(val))
+		for k, el := range val {
+			out[k] = sortArrays(el)
+		}
+		return out
+	case Array:
+		out := make(Array, len(val))
+		for i, el := range val {
+			out[i] = sortArrays(el)
+		}
+		if less := scalarLess(out); less != nil {
+			sort.SliceStable(out, func(i, j int) bool { return less(out[i], out[j]) })
+		}
+		return out
+	}
+	return v
+}
+
+// scalarLess returns the ordering for arr when all its elements are strings,
+// all numbers or all bools, and nil otherwise.
+func scalarLess(arr Array) func(a, b Value) bool {
+	var kind string
+	for _, el := range arr {
+		k := describeValue(el)
+		if kind != "" && k != kind {
+			return nil
+		}
+		if isNaN(el) {
+			return nil // NaN has no place in an order
+		}
+		kind = k
+	}
+	switch kind {
+	case "string":
+		return func(a, b Value) bool { return a.(string) < b.(string) }
+	case "bool":
+		return func(a, b Value) bool { return !a.(bool) && b.(bool) }
+	case "number":
+		return func(a, b Value) bool { return numberAsBigFloat(a).Cmp(numberAsBigFloat(b)) < 0 }
+	}
+	return nil
+}
+
+// numberAsBigFloat converts any numeric Value exactly — every Go integer
+// and float kind, Number and the big types — so they compare correctly
+// against each other. v must not be NaN.
+func numberAsBigFloat(v Value) *big.Float {
+	f := new(big.Float)
+	switch n := v.(type) {
+	case *big.Int:
+		f.SetInt(n)
+	case *big.Float:
+		f.Set(n)
+	case Number:
+		f.SetPrec(uint(len(n))*4 + 64)
+		f.Parse(string(n), 0)
+	default:
+		switch rv := reflect.ValueOf(v); rv.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f.SetInt64(rv.Int())
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			f.SetUint64(rv.Uint())
+		case reflect.Float32, reflect.Float64:
+			f.SetFloat64(rv.Float())
+		}
+	}
+	return f
+}
+
+func objectKeys(obj Object, opts SerializeOptions) []string {
 	keys := make([]string, 0, len(obj))
 	for k := range obj {
 		keys = append(keys, k)
 	}
-	if sortKeys {
-		sort.Strings(keys)
+	less := func(a, b string) bool { return a < b }
+	if opts.NaturalSort {
+		less = naturalLess
+	}
+	if len(opts.KeyOrder) > 0 {
+		return orderKeys(keys, opts.KeyOrder, less)
 	}
+	// Always sorted: map order is random, and output must not be.
+	sort.Slice(keys, func(i, j int) bool { return less(keys[i], keys[j]) })
 	return keys
 }
 
-func serializeKey(key string, sb *strings.Builder) {
-	if needsQuoting(key) {
-		serializeString(key, sb)
+// naturalLess orders a and b with runs of ASCII digits compared by numeric
+// value: "key_2" < "key_10". Runs of equal value but different length, like
+// "07" and "7", put the shorter first; everything else compares bytewise.
+func naturalLess(a, b string) bool {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if !isDigit(a[i]) || !isDigit(b[j]) {
+			if a[i] != b[j] {
+				return a[i] < b[j]
+			}
+			i++
+			j++
+			continue
+		}
+		si, sj := i, j
+		for i < len(a) && isDigit(a[i]) {
+			i++
+		}
+		for j < len(b) && isDigit(b[j]) {
+			j++
+		}
+		x := strings.TrimLeft(a[si:i], "0")
+		y := strings.TrimLeft(b[sj:j], "0")
+		if len(x) != len(y) {
+			return len(x) < len(y)
+		}
+		if x != y {
+			return x < y
+		}
+		if i-si != j-sj {
+			return i-si < j-sj
+		}
+	}
+	return len(a)-i < len(b)-j
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// orderKeys returns keys with those named in order first (in that order),
+// followed by the rest sorted by less.
+func orderKeys(keys, order []string, less func(a, b string) bool) []string {
+	rank := make(map[string]int, len(order))
+	for i, k := range order {
+		if _, dup := rank[k]; !dup {
+			rank[k] = i
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ri, iRanked := rank[keys[i]]
+		rj, jRanked := rank[keys[j]]
+		switch {
+		case iRanked && jRanked:
+			return ri < rj
+		case iRanked != jRanked:
+			return iRanked
+		}
+		return less(keys[i], keys[j])
+	})
+	return keys
+}
+
+func serializeKey(key string, opts SerializeOptions, sb *strings.Builder) {
+	if opts.Color {
+		opts.Color = false
+		var plain strings.Builder
+		serializeKey(key, opts, &plain)
+		writeColored(sb, opts.colors().Key, plain.String())
+		return
+	}
+	if opts.QuoteAllKeys || needsQuotingWith(key, opts.BareKeyExtraChars) || (opts.EscapeHTML && strings.ContainsAny(key, "<>&")) {
+		if opts.minify {
+			// Keys cannot be raw strings, so only the quote can vary.
+			sb.WriteString(shortest(
+				quoted(key, '"', opts.EscapeHTML),
+				quoted(key, '\'', opts.EscapeHTML),
+			))
+			return
+		}
+		serializeString(key, opts.EscapeHTML, sb)
 		return
 	}
 	sb.WriteString(key)
 }
 
-func needsQuoting(s string) bool {
+// NeedsQuoting reports whether s must be quoted to be used as an object key,
+// that is, whether it is not a valid bare key (SPEC §3.3): it is empty or
+// contains whitespace, '=', ',', a bracket, '/', '#' or a quote. Serialize quotes
+// exactly these keys.
+func NeedsQuoting(s string) bool {
+	return needsQuotingWith(s, "")
+}
+
+// needsQuotingWith is NeedsQuoting for a parser whose bare keys may also
+// contain the delimiters in extra; see ParseOptions.BareKeyExtraChars.
+func needsQuotingWith(s, extra string) bool {
 	if s == "" {
 		return true
 	}
 	for i := 0; i < len(s); i++ {
-		if isKeyDelimiter(s[i]) {
+		if !isKeyDelimiter(s[i]) {
+			continue
+		}
+		var next byte
+		if i+1 < len(s) {
+			next = s[i+1]
+		}
+		if !extendsBareKey(s[i], next, extra) {
 			return true
 		}
 	}
 	return false
 }
 
-func serializeString(s string, sb *strings.Builder) {
-	sb.WriteByte('"')
+// serializeString writes s as a double-quoted string. With escapeHTML, '<',
+// '>' and '&' are written as \u003c, \u003e and \u0026.
+func serializeString(s string, escapeHTML bool, sb *strings.Builder) {
+	serializeQuoted(s, '"', escapeHTML, sb)
+}
+
+// serializeQuoted writes s between quote characters (double or single),
+// escaping that quote but not the other one.
+func serializeQuoted(s string, quote byte, escapeHTML bool, sb *strings.Builder) {
+	// Size for the common case of few escapes, so a long string costs one
+	// reallocation rather than one per doubling.
+	sb.Grow(len(s) + 2)
+	sb.WriteByte(quote)
 	for i := 0; i < len(s); i++ {
 		c := s[i]
 		switch c {
 		case '\\':
 			sb.WriteString("\\\\")
-		case '"':
-			sb.WriteString("\\\"")
+		case quote:
+			sb.WriteByte('\\')
+			sb.WriteByte(quote)
 		case '\n':
 			sb.WriteString("\\n")
 		case '\r':
@@ -1498,7 +2983,8 @@ func serializeString(s string, sb *strings.Builder) {
 		case 0x0c:
 			sb.WriteString("\\f")
 		default:
-			if c < 0x20 {
+			// DEL (0x7f) is a control character the parser rejects raw.
+			if c < 0x20 || c == 0x7f || (escapeHTML && (c == '<' || c == '>' || c == '&')) {
 				const hex = "0123456789abcdef"
 				sb.WriteString("\\u00")
 				sb.WriteByte(hex[c>>4])
@@ -1508,13 +2994,147 @@ func serializeString(s string, sb *strings.Builder) {
 			}
 		}
 	}
-	sb.WriteByte('"')
+	sb.WriteByte(quote)
+}
+
+// serializeScalar writes any non-container value and reports whether v was
+// one it knows. Every Go integer kind is written in base 10 without a decimal
+// point; float32 uses the shortest form that round-trips at 32 bits, so
+// float32(0.1) prints as 0.1 rather than 0.10000000149011612.
+func serializeScalar(v Value, opts SerializeOptions, sb *strings.Builder) bool {
+	if opts.Color {
+		opts.Color = false
+		var plain strings.Builder
+		if !serializeScalar(v, opts, &plain) {
+			return false
+		}
+		writeColored(sb, opts.colors().scalar(v, plain.String()), plain.String())
+		return true
+	}
+	switch val := v.(type) {
+	case string:
+		if opts.minify {
+			sb.WriteString(minifyString(val, opts.EscapeHTML))
+		} else {
+			serializeString(val, opts.EscapeHTML, sb)
+		}
+	case int64:
+		sb.WriteString(strconv.FormatInt(val, 10))
+	case uint64:
+		sb.WriteString(strconv.FormatUint(val, 10))
+	case int:
+		sb.WriteString(strconv.Itoa(val))
+	case int8:
+		sb.WriteString(strconv.FormatInt(int64(val), 10))
+	case int16:
+		sb.WriteString(strconv.FormatInt(int64(val), 10))
+	case int32:
+		sb.WriteString(strconv.FormatInt(int64(val), 10))
+	case uint:
+		sb.WriteString(strconv.FormatUint(uint64(val), 10))
+	case uint8:
+		sb.WriteString(strconv.FormatUint(uint64(val), 10))
+	case uint16:
+		sb.WriteString(strconv.FormatUint(uint64(val), 10))
+	case uint32:
+		sb.WriteString(strconv.FormatUint(uint64(val), 10))
+	case uintptr:
+		sb.WriteString(strconv.FormatUint(uint64(val), 10))
+	case float64:
+		if opts.minify {
+			sb.WriteString(minifyFloat(val, 64))
+		} else {
+			serializeFloat(val, 64, opts, sb)
+		}
+	case float32:
+		if opts.minify {
+			sb.WriteString(minifyFloat(float64(val), 32))
+		} else {
+			serializeFloat(float64(val), 32, opts, sb)
+		}
+	case Number:
+		sb.WriteString(string(val))
+	case *big.Int:
+		sb.WriteString(val.String())
+	case *big.Float:
+		sb.WriteString(val.Text('g', -1))
+	case []byte:
+		if val == nil {
+			sb.WriteString("null")
+			break
+		}
+		if !opts.Base64 {
+			sb.WriteByte('[')
+			for i, c := range val {
+				if i > 0 {
+					sb.WriteByte(',')
+				}
+				sb.WriteString(strconv.Itoa(int(c)))
+			}
+			sb.WriteByte(']')
+			break
+		}
+		sb.Grow(base64.StdEncoding.EncodedLen(len(val)) + 5)
+		sb.WriteString(`b64"`)
+		sb.WriteString(base64.StdEncoding.EncodeToString(val))
+		sb.WriteByte('"')
+	case bool:
+		if val {
+			sb.WriteString("true")
+		} else {
+			sb.WriteString("false")
+		}
+	case nil:
+		sb.WriteString("null")
+	default:
+		switch rv := reflect.ValueOf(v); rv.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+			if rv.IsNil() {
+				sb.WriteString("null")
+				return true
+			}
+		}
+		return false
+	}
+	return true
 }
 
-func serializeFloat(f float64, sb *strings.Builder) {
+// serializeFloat writes f in its shortest round-trip form for bitSize (32 or
+// 64), dropping the fraction for integral values, or in the form
+// SerializeOptions.ExponentThreshold picks.
+func serializeFloat(f float64, bitSize int, opts SerializeOptions, sb *strings.Builder) {
+	if t := opts.ExponentThreshold; t > 0 && !math.IsInf(f, 0) && !math.IsNaN(f) {
+		if abs := math.Abs(f); abs >= t || (abs != 0 && abs < 1/t) {
+			sb.WriteString(exponentForm(f, bitSize))
+			return
+		}
+		if f != math.Trunc(f) {
+			sb.WriteString(strconv.FormatFloat(f, 'f', -1, bitSize))
+			return
+		}
+	}
 	if f == float64(int64(f)) && f >= -9.2e18 && f <= 9.2e18 {
 		sb.WriteString(strconv.FormatInt(int64(f), 10))
 		return
 	}
-	sb.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	sb.WriteString(strconv.FormatFloat(f, 'g', -1, bitSize))
+}
+
+// exponentForm writes f as mantissa and exponent with the exponent's '+' and
+// leading zeros dropped: 1e7 rather than 1e+07.
+func exponentForm(f float64, bitSize int) string {
+	exp := strconv.FormatFloat(f, 'e', -1, bitSize)
+	mant, e, ok := strings.Cut(exp, "e")
+	if !ok {
+		return exp // NaN or Inf
+	}
+	sign := ""
+	if e[0] == '-' {
+		sign = "-"
+	}
+	e = strings.TrimLeft(e[1:], "0")
+	if e == "" {
+		e = "0"
+	}
+	return mant + "e" + sign + e
 }
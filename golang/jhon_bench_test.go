@@ -2,6 +2,8 @@ package jhon
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -70,6 +72,48 @@ func BenchmarkParseJSONMedium(b *testing.B) {
 	}
 }
 
+// commentedJHON is a large, comment-heavy config: each of its 500 sections
+// carries doc, block and trailing comments, as a hand-maintained file does.
+var commentedJHON = func() string {
+	var sb strings.Builder
+	for i := 0; i < 500; i++ {
+		fmt.Fprintf(&sb, `
+/// Section %d settings. Every key below is documented at length, as a file
+/// meant to be edited by hand usually is, so comments outweigh the values.
+section_%d = {
+  // The host to connect to; a name or an address.
+  host = "localhost" // overridden in production
+  /*
+   * Port and timeout, in milliseconds. Keep the timeout above the
+   * slowest expected response, or requests are cut short.
+   */
+  port = 8080
+  timeout = 30_000 // 30 seconds
+  tags = ["a", "b"] // free-form
+}
+`, i, i)
+	}
+	return sb.String()
+}()
+
+func BenchmarkParseJHONLargeCommented(b *testing.B) {
+	b.ReportAllocs()
+	b.SetBytes(int64(len(commentedJHON)))
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(commentedJHON); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseCachedJHONMedium(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseCached(mediumJHON); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 // =============================================================================
 // Serialize benchmarks
 // =============================================================================
@@ -107,3 +151,72 @@ func BenchmarkSerializeJSONMedium(b *testing.B) {
 		_, _ = json.Marshal(value)
 	}
 }
+
+// largeStringValue embeds a 1 MiB string, as a config carrying a certificate
+// bundle or an inline script might.
+var largeStringValue = Object{
+	"name":   "bundle",
+	"script": strings.Repeat("line of embedded text with \"quotes\"\n", 1<<15),
+}
+
+func BenchmarkSerializeJHONLargeString(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		_ = Serialize(largeStringValue)
+	}
+}
+
+// deepValue nests objects and arrays eight levels deep, so pretty printing
+// decides inline-or-not for many containers on the way down.
+var deepValue = func() Value {
+	var v Value = Object{"leaf": "value", "n": int64(1)}
+	for i := 0; i < 8; i++ {
+		v = Object{"a": v, "b": Array{v, int64(i)}, "name": "level"}
+	}
+	return v
+}()
+
+func BenchmarkSerializePrettyJHONMedium(b *testing.B) {
+	value, _ := Parse(mediumJHON)
+	opts := SerializeOptions{Indent: "  "}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = SerializeWithOptions(value, opts)
+	}
+}
+
+func BenchmarkSerializePrettyJHONDeep(b *testing.B) {
+	for _, width := range []int{0, 80} {
+		opts := SerializeOptions{Indent: "  ", MaxInlineWidth: width}
+		b.Run(fmt.Sprintf("width=%d", width), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = SerializeWithOptions(deepValue, opts)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Minify benchmarks
+// =============================================================================
+
+func BenchmarkMinifyBytesMedium(b *testing.B) {
+	input := []byte(mediumJHON)
+	for i := 0; i < b.N; i++ {
+		if _, err := MinifyBytes(input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMinifyParseMedium(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		v, err := Parse(mediumJHON)
+		if err != nil {
+			b.Fatal(err)
+		}
+		_ = Minify(v)
+	}
+}
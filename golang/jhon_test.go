@@ -1,8 +1,14 @@
 package jhon
 
 import (
+	"fmt"
+	"math"
+	"math/big"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 // ============================================================================
@@ -39,6 +45,36 @@ func TestCommentsOnlyInput(t *testing.T) {
 	}
 }
 
+func TestEmptyDocumentForms(t *testing.T) {
+	// SPEC §2.3: every form of empty input is the Empty form, null — never
+	// {} and never an error — whichever entry point reads it, so an
+	// optional config file that holds only comments behaves like a missing
+	// one.
+	for _, input := range []string{
+		"\n\n",
+		"\r\n",
+		"// just a comment",
+		"// just a comment\n",
+		"/**/",
+		"/* a */ // b\r\n  ",
+		"\t/* multi\nline */\n\n// end",
+	} {
+		if v, err := Parse(input); err != nil || v != nil {
+			t.Errorf("Parse(%q) = %#v, %v; want nil, nil", input, v, err)
+		}
+		if doc, err := ParseWithComments(input, ParseOptions{}); err != nil || doc.Value != nil {
+			t.Errorf("ParseWithComments(%q) = %v; want nil value", input, err)
+		}
+		if out, err := MinifyBytes([]byte(input)); err != nil || len(out) != 0 {
+			t.Errorf("MinifyBytes(%q) = %q, %v; want empty", input, out, err)
+		}
+		cfg := struct{ Port int }{Port: 8080}
+		if err := Unmarshal(input, &cfg); err != nil || cfg.Port != 8080 {
+			t.Errorf("Unmarshal(%q) = %v and changed the target to %+v", input, err, cfg)
+		}
+	}
+}
+
 func TestTopLevelObjectWithoutBraces(t *testing.T) {
 	v, err := Parse(`name="x",port=80`)
 	if err != nil {
@@ -62,6 +98,30 @@ func TestTopLevelObjectWithBracesIsSingleElementArray(t *testing.T) {
 	}
 }
 
+func TestTopLevelBracesAreParsedNotPatternMatched(t *testing.T) {
+	// Whether a document is one braced object is decided by parsing it, not
+	// by looking at its first and last bytes.
+	cases := map[string]Value{
+		`{a="}"}`:          Array{Object{"a": "}"}},
+		`{a="{"}`:          Array{Object{"a": "{"}},
+		`{a=1} , {b=2}`:    Array{Object{"a": int64(1)}, Object{"b": int64(2)}},
+		"{a=1}\n{b='}'}":   Array{Object{"a": int64(1)}, Object{"b": "}"}},
+		`{a=1} // {b=2}`:   Array{Object{"a": int64(1)}},
+		`{a={b="}"}} /**/`: Array{Object{"a": Object{"b": "}"}}},
+	}
+	for input, want := range cases {
+		v, err := Parse(input)
+		if err != nil || !reflect.DeepEqual(v, want) {
+			t.Errorf("%q: got %#v, %v", input, v, err)
+		}
+	}
+	for _, input := range []string{`{a=1} x`, `{a=1}}`, `{a="}"`} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
+
 func TestTopLevelExplicitArrayIsSingleElementArray(t *testing.T) {
 	// Per SPEC §2: top-level `[...]` is one element of the implicit array.
 	v, err := Parse("[1, 2, 3]")
@@ -221,6 +281,26 @@ func TestTopLevelArrayFollowedByPairsIsError(t *testing.T) {
 	}
 }
 
+// A brace- or bracket-wrapped top-level value is an element of the implicit
+// array (SPEC §2.2), so whatever follows it is parsed as more elements and
+// trailing text can never be silently dropped.
+func TestTrailingContentAfterTopLevelContainer(t *testing.T) {
+	for _, input := range []string{"{a=1} junk", "[1,2] junk", "{a=1}\njunk", "{a=1} {b=2}"} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+	_, err := Parse("{a=1} junk")
+	pe, ok := err.(*ParseError)
+	if !ok || pe.Line != 1 || pe.Column != 7 {
+		t.Fatalf("got %v", err)
+	}
+	v, err := Parse("{a=1} // trailing comment\n")
+	if err != nil || !reflect.DeepEqual(v, Array{Object{"a": int64(1)}}) {
+		t.Fatalf("got %#v, %v", v, err)
+	}
+}
+
 // ============================================================================
 // §3.2 comments
 // ============================================================================
@@ -261,6 +341,59 @@ func TestUnterminatedBlockCommentIsError(t *testing.T) {
 	}
 }
 
+func TestBlockCommentOnlyInput(t *testing.T) {
+	v, err := Parse("/* nothing\n   here */")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != nil {
+		t.Fatalf("expected nil, got %#v", v)
+	}
+}
+
+func TestLineCommentsOnlyInput(t *testing.T) {
+	v, err := Parse("// one\n// two\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v != nil {
+		t.Fatalf("expected nil, got %#v", v)
+	}
+}
+
+func TestUnterminatedBlockCommentReportsOpening(t *testing.T) {
+	for _, input := range []string{"/* never closed", "a=1\n  /* never closed", "[1, /* never closed"} {
+		_, err := Parse(input)
+		pe, ok := err.(*ParseError)
+		if !ok {
+			t.Fatalf("%q: expected *ParseError, got %v", input, err)
+		}
+		if pe.Message != "unterminated block comment" {
+			t.Fatalf("%q: got message %q", input, pe.Message)
+		}
+	}
+	_, err := Parse("a=1\n  /* never closed")
+	pe := err.(*ParseError)
+	if pe.Line != 2 || pe.Column != 3 {
+		t.Fatalf("got %d:%d, want 2:3", pe.Line, pe.Column)
+	}
+}
+
+// Comments are recognized by the lexer only between tokens, so a raw string
+// — which may hold unescaped quotes and '#' — keeps any `//` or `/*` inside
+// it.
+func TestCommentMarkersInsideRawStrings(t *testing.T) {
+	input := "note=r#\"see http://x \"quoted\" /* not a comment */\"# // real comment\nnext=r\"a//b\"\n"
+	v, err := Parse(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Object{"note": `see http://x "quoted" /* not a comment */`, "next": "a//b"}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v, want %#v", v, want)
+	}
+}
+
 // ============================================================================
 // §3.3 bare keys
 // ============================================================================
@@ -345,6 +478,92 @@ func TestQuotedKeyWithSpaces(t *testing.T) {
 	}
 }
 
+func TestPathologicalBareKeys(t *testing.T) {
+	cases := []struct {
+		input     string
+		msg       string
+		line, col int
+	}{
+		{"=value", "missing key before '='", 1, 1},
+		{"x=1\n=2", "missing key before '='", 2, 1},
+		{"a b=1", `unquoted key cannot contain spaces; quote it as "a b"`, 1, 3},
+		{"x=1\nlog level = 2", `unquoted key cannot contain spaces; quote it as "log level"`, 2, 5},
+		{"s={a b c=1}", `unquoted key cannot contain spaces; quote it as "a b c"`, 1, 6},
+		{`"a"extra=1`, `unexpected 'e' after quoted key "a"; expected '='`, 1, 4},
+		{"x=1\n'a'b=1", `unexpected 'b' after quoted key "a"; expected '='`, 2, 4},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.input)
+		pe, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%q: expected *ParseError, got %v", c.input, err)
+			continue
+		}
+		if pe.Message != c.msg || pe.Line != c.line || pe.Column != c.col {
+			t.Errorf("%q: got %d:%d %q, want %d:%d %q", c.input, pe.Line, pe.Column, pe.Message, c.line, c.col, c.msg)
+		}
+	}
+}
+
+func TestKeyEdgeCasesThatParse(t *testing.T) {
+	cases := map[string]Value{
+		"a =1":       Object{"a": int64(1)},
+		`"a" = 1`:    Object{"a": int64(1)},
+		`"a b" = 1`:  Object{"a b": int64(1)},
+		`"x=y", "z"`: Array{"x=y", "z"},
+		"1, {a=1}":   Array{int64(1), Object{"a": int64(1)}},
+		"a\n= 1":     Object{"a": int64(1)},
+		// An '=' inside a raw string, where '\\' escapes nothing, does
+		// not make the document an object.
+		`r#"x"=y"#`:        Array{`x"=y`},
+		`r"C:\", "x=y"`:    Array{`C:\`, "x=y"},
+		"r#\"a\nb=c\"#, 2": Array{"a\nb=c", int64(2)},
+	}
+	for input, want := range cases {
+		v, err := Parse(input)
+		if err != nil || !reflect.DeepEqual(v, want) {
+			t.Errorf("%q: got %#v, %v; want %#v", input, v, err, want)
+		}
+		if _, err := MinifyBytes([]byte(input)); err != nil {
+			t.Errorf("%q: MinifyBytes: %v", input, err)
+		}
+	}
+	if _, err := Parse("a=b=c"); err == nil {
+		t.Error(`"a=b=c": expected an error`)
+	}
+}
+
+func TestNeedsQuoting(t *testing.T) {
+	cases := map[string]bool{
+		"name":      false,
+		"snake_key": false,
+		"kebab-key": false,
+		"a.b":       false,
+		"123":       false,
+		"日本語":       false,
+		"":          true,
+		"two words": true,
+		"a=b":       true,
+		"a,b":       true,
+		"a{b":       true,
+		"a]":        true,
+		"a/b":       true,
+		"a#b":       true,
+		`"q"`:       true,
+		"it's":      true,
+		"tab\there": true,
+	}
+	for key, want := range cases {
+		if got := NeedsQuoting(key); got != want {
+			t.Errorf("NeedsQuoting(%q) = %v, want %v", key, got, want)
+		}
+		quoted := strings.HasPrefix(Serialize(Object{key: int64(1)}), `"`)
+		if quoted != want {
+			t.Errorf("%q: Serialize quoted it: %v", key, quoted)
+		}
+	}
+}
+
 // ============================================================================
 // §3.4 strings
 // ============================================================================
@@ -421,6 +640,40 @@ func TestRawStringWithHashes(t *testing.T) {
 	}
 }
 
+func TestRawStringEndingInBackslash(t *testing.T) {
+	// Raw strings have no escapes, so a trailing backslash is just a
+	// backslash, with or without hashes.
+	for _, input := range []string{`dir=r"C:\"`, `dir=r#"C:\"#`, `dir=r"C:\", n=1`, "dir=r\"C:\\\" // root"} {
+		v, err := Parse(input)
+		if err != nil {
+			t.Fatalf("%s: %v", input, err)
+		}
+		if got := v.(Object)["dir"]; got != `C:\` {
+			t.Fatalf("%s: got %#v", input, got)
+		}
+	}
+	// Text straight after the quote means the writer expected \" to
+	// escape it; the error says so and names the opening position.
+	cases := []struct {
+		input, msg string
+		col        int
+	}{
+		{`dir=r"C:\"more"`, `raw string r"C:\" ends at the quote after its backslash; raw strings have no escapes, so write r#"..."# to include a quote`, 5},
+		{`dir=r#"a\"#b"#`, `raw string r#"a\"# ends at the quote after its backslash; raw strings have no escapes, so write r##"..."## to include a quote`, 5},
+		{`dir=[r"x\"y", 1]`, `raw string r"x\" ends at the quote after its backslash; raw strings have no escapes, so write r#"..."# to include a quote`, 6},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.input)
+		pe, ok := err.(*ParseError)
+		if !ok {
+			t.Fatalf("%s: expected *ParseError, got %v", c.input, err)
+		}
+		if pe.Message != c.msg || pe.Column != c.col {
+			t.Errorf("%s: got %q at column %d\nwant %q at column %d", c.input, pe.Message, pe.Column, c.msg, c.col)
+		}
+	}
+}
+
 func TestUnrecognizedEscapeIsError(t *testing.T) {
 	if _, err := Parse(`key="value\q"`); err == nil {
 		t.Fatal("expected error")
@@ -433,6 +686,102 @@ func TestUnterminatedStringIsError(t *testing.T) {
 	}
 }
 
+func TestStringLineContinuation(t *testing.T) {
+	v, err := Parse("msg=\"Hello, \\\nworld\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, Object{"msg": "Hello, world"}) {
+		t.Fatalf("got %#v", v)
+	}
+}
+
+func TestStringLineContinuationCRLF(t *testing.T) {
+	v, err := Parse("msg='Hello, \\\r\nworld'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, Object{"msg": "Hello, world"}) {
+		t.Fatalf("got %#v", v)
+	}
+}
+
+func TestStringBackslashBareCRIsError(t *testing.T) {
+	if _, err := Parse("msg=\"a\\\rb\""); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestStringEscapeHexByte(t *testing.T) {
+	v, err := Parse(`a="\x41", b="caf\xe9", c="\x00\x7F"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Object{"a": "A", "b": "café", "c": "\x00\x7f"}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v, want %#v", v, want)
+	}
+	if !utf8.ValidString(v.(Object)["b"].(string)) {
+		t.Fatalf("\\xe9 produced invalid UTF-8: %q", v.(Object)["b"])
+	}
+}
+
+func TestStringEscapeHexByteErrors(t *testing.T) {
+	_, err := Parse(`a="\x4g"`)
+	pe, ok := err.(*ParseError)
+	if !ok || pe.Message != `invalid hex digit in \x escape` || pe.Column != 7 {
+		t.Fatalf("got %v", err)
+	}
+	if _, err := Parse(`a="\x4`); err == nil {
+		t.Fatal("expected an error for an incomplete escape")
+	}
+}
+
+func TestStringEscapeSurrogatePair(t *testing.T) {
+	v, err := Parse(`a="\ud83d\ude00!", b="\uD834\uDD1E"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Object{"a": "😀!", "b": "𝄞"}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v, want %#v", v, want)
+	}
+	for input, msg := range map[string]string{
+		`a="\ude00"`:       "unpaired low surrogate U+DE00",
+		`a="\ud83d"`:       `high surrogate U+D83D must be followed by a \u low surrogate`,
+		`a="\ud83dx"`:      `high surrogate U+D83D must be followed by a \u low surrogate`,
+		`a="\ud83d\n"`:     `high surrogate U+D83D must be followed by a \u low surrogate`,
+		`a="\ud83d\u0041"`: `high surrogate U+D83D must be followed by a \u low surrogate, not U+0041`,
+		`a="\ud83d\ud83d"`: `high surrogate U+D83D must be followed by a \u low surrogate, not U+D83D`,
+	} {
+		_, err := Parse(input)
+		pe, ok := err.(*ParseError)
+		if !ok || !strings.Contains(pe.Message, msg) {
+			t.Errorf("%s: got %v, want %q", input, err, msg)
+		}
+	}
+}
+
+func TestStringEndingInBackslash(t *testing.T) {
+	cases := []struct {
+		input     string
+		line, col int
+	}{
+		{`a="abc\`, 1, 7},
+		{"a=1\nb='x\\", 2, 5},
+		{`"key\`, 1, 5},
+		{`["a", "b\`, 1, 9},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.input)
+		pe, ok := err.(*ParseError)
+		if !ok || pe.Kind != ParseErrorEOF || pe.Line != c.line || pe.Column != c.col ||
+			pe.Message != `unterminated string: input ends after '\'` {
+			t.Errorf("%q: got %v", c.input, err)
+		}
+	}
+}
+
 // ============================================================================
 // §3.5 numbers
 // ============================================================================
@@ -596,6 +945,121 @@ func TestAdjacentUnderscoresAreError(t *testing.T) {
 	}
 }
 
+func TestMisplacedUnderscoreErrorPosition(t *testing.T) {
+	// The error points at the offending underscore; `_1` is not a number
+	// at all, so it is reported as an unexpected character.
+	cases := []struct {
+		input string
+		col   int
+		msg   string
+	}{
+		{`n=1_`, 4, "cannot end with underscore"},
+		{`n=1__0`, 5, "invalid underscore placement"},
+		{`n=_1`, 3, "unexpected character"},
+		{`n=1_.0`, 4, "cannot end with underscore"},
+		{`n=1._5`, 5, "invalid underscore placement"},
+		{`n=1e5_`, 6, "cannot end with underscore"},
+		{`n=0x_ff`, 5, "invalid underscore placement"},
+		{`n=0xff_`, 7, "cannot end with underscore"},
+		{`n=[1_, 2]`, 5, "cannot end with underscore"},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.input)
+		pe, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%s: expected *ParseError, got %v", c.input, err)
+			continue
+		}
+		if pe.Line != 1 || pe.Column != c.col || !strings.Contains(pe.Message, c.msg) {
+			t.Errorf("%s: got %d:%d %q, want 1:%d %q", c.input, pe.Line, pe.Column, pe.Message, c.col, c.msg)
+		}
+	}
+}
+
+func TestSignWithoutDigitsIsError(t *testing.T) {
+	// The error points at the sign.
+	cases := []struct {
+		input string
+		col   int
+		kind  ParseErrorKind
+		msg   string
+	}{
+		{`x=-`, 3, ParseErrorEOF, "expected digits after '-'"},
+		{`x=-,`, 3, ParseErrorSyntax, "expected digits after '-'"},
+		{`x={y=-}`, 6, ParseErrorSyntax, "expected digits after '-'"},
+		{`x=[1, -]`, 7, ParseErrorSyntax, "expected digits after '-'"},
+		{`x=-.5`, 3, ParseErrorSyntax, "expected digits after '-'"},
+		{`x=- 5`, 3, ParseErrorSyntax, "a sign cannot be separated from its number"},
+		{"x=-	5", 3, ParseErrorSyntax, "a sign cannot be separated from its number"},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.input)
+		pe, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%q: expected *ParseError, got %v", c.input, err)
+			continue
+		}
+		if pe.Column != c.col || pe.Kind != c.kind || !strings.Contains(pe.Message, c.msg) {
+			t.Errorf("%q: got col %d kind %v %q, want col %d kind %v %q", c.input, pe.Column, pe.Kind, pe.Message, c.col, c.kind, c.msg)
+		}
+	}
+	if v := MustParse(`x=-5, y=-0x10`); !reflect.DeepEqual(v, Object{"x": int64(-5), "y": int64(-16)}) {
+		t.Fatalf("got %#v", v)
+	}
+}
+
+func TestCommaIsNotADigitSeparatorInObject(t *testing.T) {
+	_, err := Parse(`n=1,000`)
+	pe, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected *ParseError, got %v", err)
+	}
+	if !strings.Contains(pe.Message, "1_000") {
+		t.Fatalf("expected a digit-separator hint, got %q", pe.Message)
+	}
+}
+
+func TestCommaIsNotADigitSeparatorInArray(t *testing.T) {
+	// Inside an array the comma simply separates two numbers: `1,000` is
+	// the elements 1 and 0 (leading zeros are accepted).
+	v, err := Parse(`n=[1,000]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Object{"n": Array{int64(1), int64(0)}}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v want %#v", v, want)
+	}
+}
+
+func TestUnderscoreIsTheDigitSeparator(t *testing.T) {
+	v, err := Parse(`n=1_000`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v, Object{"n": int64(1000)}) {
+		t.Fatalf("got %#v", v)
+	}
+}
+
+func TestIntegerAndDecimalLiteralsKeepTheirKind(t *testing.T) {
+	v := MustParse(`a=1, b=1.0, c=1e3, d=0x10, e=-0, f=18446744073709551615`).(Object)
+	want := Object{
+		"a": int64(1),
+		"b": float64(1),
+		"c": float64(1000),
+		"d": int64(16),
+		"e": int64(0),
+		"f": uint64(18446744073709551615),
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v", v)
+	}
+	if v.GetFloatOr("a", 0) != 1 || v.GetIntOr("b", 0) != 1 {
+		t.Fatal("accessors should accept either kind")
+	}
+}
+
 // ============================================================================
 // §5 objects
 // ============================================================================
@@ -691,6 +1155,42 @@ func TestSameLineTabOnlySeparatorIsError(t *testing.T) {
 	}
 }
 
+func TestEqualsSplitAcrossLines(t *testing.T) {
+	// Newlines separate pairs, never a key from its '=' or a '=' from its
+	// value: whitespace around '=' is insignificant, newlines included.
+	cases := []struct {
+		input string
+		want  Value
+	}{
+		{"name\n=\n\"x\"", Object{"name": "x"}},
+		{"name\n= \"x\"\nport=80", Object{"name": "x", "port": int64(80)}},
+		{"name =\n\n\"x\"", Object{"name": "x"}},
+		{"\"quoted key\"\n=1", Object{"quoted key": int64(1)}},
+		{"a=1\nb\n=2", Object{"a": int64(1), "b": int64(2)}},
+		{"server={host\n=\n\"x\"}", Object{"server": Object{"host": "x"}}},
+		{"a // key\n= 1", Object{"a": int64(1)}},
+		{"a\n/* c */\n=\n// d\n[1]", Object{"a": Array{int64(1)}}},
+	}
+	for _, c := range cases {
+		v, err := Parse(c.input)
+		if err != nil {
+			t.Errorf("%q: %v", c.input, err)
+			continue
+		}
+		if !reflect.DeepEqual(v, c.want) {
+			t.Errorf("%q: got %#v want %#v", c.input, v, c.want)
+		}
+	}
+}
+
+func TestKeyWithoutEqualsBeforeNewlineIsError(t *testing.T) {
+	for _, input := range []string{"a=1\nb\n", "a=1\nb\nc=2", "{a=1\nb\n}"} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("%q: expected error", input)
+		}
+	}
+}
+
 // ============================================================================
 // §6 arrays
 // ============================================================================
@@ -740,6 +1240,49 @@ func TestUnbalancedBracesAreError(t *testing.T) {
 	}
 }
 
+func TestMismatchedCloseOfObject(t *testing.T) {
+	_, err := Parse(`server={host="x"]`)
+	pe, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected *ParseError, got %v", err)
+	}
+	if pe.Message != "expected '}' to close object opened at 1:8 but found ']'" {
+		t.Fatalf("got %q", pe.Message)
+	}
+	if pe.Line != 1 || pe.Column != 17 {
+		t.Fatalf("got %d:%d, want 1:17", pe.Line, pe.Column)
+	}
+}
+
+func TestMismatchedCloseOfArray(t *testing.T) {
+	_, err := Parse("items=[\n  1,\n  2\n}")
+	pe, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected *ParseError, got %v", err)
+	}
+	if pe.Message != "expected ']' to close array opened at 1:7 but found '}'" {
+		t.Fatalf("got %q", pe.Message)
+	}
+	if pe.Line != 4 || pe.Column != 1 {
+		t.Fatalf("got %d:%d, want 4:1", pe.Line, pe.Column)
+	}
+}
+
+func TestMismatchedCloseOfEmptyContainers(t *testing.T) {
+	for _, input := range []string{`a={]`, `a=[}`} {
+		if _, err := Parse(input); err == nil || !strings.Contains(err.Error(), "to close") {
+			t.Fatalf("%q: got %v", input, err)
+		}
+	}
+}
+
+func TestUnterminatedObjectAfterValue(t *testing.T) {
+	_, err := Parse(`a={b=1`)
+	if err == nil || !strings.Contains(err.Error(), "unterminated nested object") {
+		t.Fatalf("got %v", err)
+	}
+}
+
 // ============================================================================
 // §7 serialization
 // ============================================================================
@@ -919,6 +1462,365 @@ func TestHexOctalBinarySerializeAsDecimal(t *testing.T) {
 	}
 }
 
+func TestPrettySerializeIndentWidth(t *testing.T) {
+	got := SerializeWithOptions(
+		Object{"server": Object{"port": int64(80)}},
+		SerializeOptions{IndentWidth: 4},
+	)
+	want := "server = {\n    port = 80\n}"
+	if got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestPrettySerializeUseTabs(t *testing.T) {
+	got := SerializeWithOptions(
+		Object{"server": Object{"port": int64(80)}},
+		SerializeOptions{UseTabs: true, IndentWidth: 4},
+	)
+	want := "server = {\n\tport = 80\n}"
+	if got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestPrettySerializeExplicitIndentWins(t *testing.T) {
+	got := SerializeWithOptions(
+		Object{"server": Object{"port": int64(80)}},
+		SerializeOptions{Indent: " ", UseTabs: true},
+	)
+	want := "server = {\n port = 80\n}"
+	if got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestPrettySerializeTrailingComma(t *testing.T) {
+	value := Object{
+		"server": Object{"host": "localhost", "port": int64(80)},
+		"tags":   Array{"a", "b"},
+	}
+	got := SerializeWithOptions(value, SerializeOptions{SortKeys: true, Indent: "  ", TrailingComma: true})
+	want := "server = {\n  host = \"localhost\"\n  port = 80,\n}\ntags = [\n  \"a\"\n  \"b\",\n]"
+	if got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	roundTrip, err := Parse(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(roundTrip, value) {
+		t.Fatalf("got %#v want %#v", roundTrip, value)
+	}
+}
+
+func TestPrettySerializeTrailingCommaJoinedWrapper(t *testing.T) {
+	value := Object{"tags": Array{"alpha", "beta", "gamma"}}
+	got := SerializeWithOptions(value, SerializeOptions{Indent: "  ", MaxInlineWidth: 24, TrailingComma: true})
+	want := "tags = [\n  \"alpha\", \"beta\", \"gamma\",\n]"
+	if got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	roundTrip, err := Parse(got)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(roundTrip, value) {
+		t.Fatalf("got %#v want %#v", roundTrip, value)
+	}
+}
+
+func TestCompactSerializeIgnoresTrailingComma(t *testing.T) {
+	got := SerializeWithOptions(Object{"a": Array{int64(1)}}, SerializeOptions{TrailingComma: true})
+	if got != "a=[1]" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestRoundTripKeywordAndNumericLookingStrings(t *testing.T) {
+	// Strings whose content looks like another scalar must come back as
+	// strings: values are always quoted, and keywords in key position are
+	// keys per SPEC §3.3.
+	original := Object{
+		"t":    "true",
+		"f":    "false",
+		"n":    "null",
+		"i":    "123",
+		"x":    "0xff",
+		"e":    "1e5",
+		"true": "key is a keyword",
+		"42":   "key is numeric",
+	}
+	for _, opts := range []SerializeOptions{{}, {Indent: "  "}} {
+		text := SerializeWithOptions(original, opts)
+		roundTrip, err := Parse(text)
+		if err != nil {
+			t.Fatalf("%q: %v", text, err)
+		}
+		if !reflect.DeepEqual(roundTrip, original) {
+			t.Fatalf("got %#v want %#v", roundTrip, original)
+		}
+	}
+	if got := Serialize(Object{"x": "true"}); got != `x="true"` {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestSerializeKeyOrder(t *testing.T) {
+	value := Object{
+		"value":       int64(1),
+		"zeta":        true,
+		"name":        "x",
+		"alpha":       false,
+		"description": "d",
+		"nested":      Object{"value": int64(2), "name": "y", "b": int64(0), "a": int64(0)},
+	}
+	opts := SerializeOptions{KeyOrder: []string{"name", "description", "value"}}
+	got := SerializeWithOptions(value, opts)
+	want := `name="x",description="d",value=1,alpha=false,nested={name="y",value=2,a=0,b=0},zeta=true`
+	if got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	opts.Indent = "  "
+	got = SerializeWithOptions(value, opts)
+	want = "name = \"x\"\ndescription = \"d\"\nvalue = 1\nalpha = false\nnested = {\n  name = \"y\"\n  value = 2\n  a = 0\n  b = 0\n}\nzeta = true"
+	if got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
+
+func TestSerializePlainMapsAndSlices(t *testing.T) {
+	v := map[string]interface{}{
+		"name": "app",
+		"ports": []interface{}{
+			int64(80),
+			map[string]interface{}{"tls": true},
+		},
+		"nested": Object{"inner": map[string]interface{}{"b": int64(2), "a": int64(1)}},
+	}
+	opts := SerializeOptions{SortKeys: true}
+	if got := SerializeWithOptions(v, opts); got != `name="app",nested={inner={a=1,b=2}},ports=[80,{tls=true}]` {
+		t.Fatalf("compact: got %q", got)
+	}
+	pretty := SerializeWithOptions(v, SerializeOptions{Indent: "  ", SortKeys: true})
+	back, err := Parse(pretty)
+	if err != nil {
+		t.Fatalf("re-parse %q: %v", pretty, err)
+	}
+	if back.(Object)["nested"].(Object)["inner"].(Object)["a"] != int64(1) {
+		t.Fatalf("pretty round-trip lost data: %q", pretty)
+	}
+}
+
+func TestSerializeTopLevelSlice(t *testing.T) {
+	got := Serialize([]interface{}{"a", int64(1), []interface{}{}})
+	if got != `"a",1,[]` {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestSerializeGoNumericTypes(t *testing.T) {
+	cases := []struct {
+		v    Value
+		want string
+	}{
+		{int(-7), "-7"},
+		{int8(-8), "-8"},
+		{int32(32), "32"},
+		{int64(-9223372036854775808), "-9223372036854775808"},
+		{uint(7), "7"},
+		{uint16(65535), "65535"},
+		{uint64(18446744073709551615), "18446744073709551615"},
+		{float32(0.1), "0.1"},
+		{float32(2.5), "2.5"},
+		{float32(3), "3"},
+	}
+	for _, c := range cases {
+		if got := Serialize(Object{"n": c.v}); got != "n="+c.want {
+			t.Errorf("%T(%v): got %q, want %q", c.v, c.v, got, "n="+c.want)
+		}
+		if got := SerializePretty(Array{Array{c.v}}, "  "); !strings.Contains(got, c.want) {
+			t.Errorf("%T(%v) pretty: got %q", c.v, c.v, got)
+		}
+	}
+}
+
+func TestSerializeSortArrays(t *testing.T) {
+	tags := Array{"web", "api", "Zeta", "db"}
+	v := Object{
+		"tags":    tags,
+		"ports":   Array{uint64(18446744073709551615), int64(443), 80.5, int64(-1), Number("0x10")},
+		"flags":   Array{true, false, true},
+		"mixed":   Array{"b", int64(1), "a"},
+		"servers": Array{Object{"n": "b"}, Object{"n": "a"}},
+		"nested":  Array{Array{"y", "x"}},
+	}
+	got := SerializeWithOptions(v, SerializeOptions{SortKeys: true, SortArrays: true})
+	want := `flags=[false,true,true],mixed=["b",1,"a"],nested=[["x","y"]],ports=[-1,0x10,80.5,443,18446744073709551615],servers=[{n="b"},{n="a"}],tags=["Zeta","api","db","web"]`
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+	if tags[0] != "web" {
+		t.Fatalf("caller's array was modified: %v", tags)
+	}
+	if got := SerializeWithOptions(Array{int64(2), int64(1)}, SerializeOptions{Indent: "  ", SortArrays: true}); got != "1\n2" {
+		t.Fatalf("pretty top-level: got %q", got)
+	}
+}
+
+func TestPrettyArraysNestedInArrays(t *testing.T) {
+	cases := []struct {
+		v    Value
+		want string
+	}{
+		{
+			Object{"m": Array{Array{int64(1), int64(2)}, Array{int64(3), int64(4)}}},
+			"m = [\n  [\n    1\n    2\n  ]\n  [\n    3\n    4\n  ]\n]",
+		},
+		{
+			Object{"m": Array{int64(1), Array{int64(2), Array{int64(3)}}}},
+			"m = [\n  1\n  [\n    2\n    [\n      3\n    ]\n  ]\n]",
+		},
+		{
+			Array{Array{int64(1), int64(2)}, Array{int64(3), int64(4)}},
+			"[\n  1\n  2\n]\n[\n  3\n  4\n]",
+		},
+	}
+	for _, c := range cases {
+		got := SerializePretty(c.v, "  ")
+		if got != c.want {
+			t.Errorf("got:\n%s\nwant:\n%s", got, c.want)
+		}
+		back, err := Parse(got)
+		if err != nil {
+			t.Fatalf("re-parse %q: %v", got, err)
+		}
+		if !reflect.DeepEqual(back, c.v) {
+			t.Errorf("round-trip: got %#v, want %#v", back, c.v)
+		}
+	}
+}
+
+func TestSerializeTypedNil(t *testing.T) {
+	type server struct{ Host string }
+	var srv *server
+	var n *int64
+	var m map[string]int
+	v := Object{
+		"srv":   srv,
+		"n":     n,
+		"m":     m,
+		"items": Array{srv, nil, int64(1)},
+	}
+	got := SerializeWithOptions(v, SerializeOptions{SortKeys: true})
+	if want := `items=[null,null,1],m=null,n=null,srv=null`; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	pretty := SerializeWithOptions(v, SerializeOptions{SortKeys: true, Indent: "  ", MaxInlineWidth: 80})
+	if want := "items = [ null, null, 1 ]\nm = null\nn = null\nsrv = null"; pretty != want {
+		t.Fatalf("pretty: got %q, want %q", pretty, want)
+	}
+	var obj Object
+	var arr Array
+	if got := SerializeWithOptions(Object{"o": obj, "a": arr}, SerializeOptions{SortKeys: true}); got != "a=[],o={}" {
+		t.Fatalf("nil Object/Array: got %q", got)
+	}
+}
+
+func TestSerializeEscapeHTML(t *testing.T) {
+	v := Object{"html": "</script><b>a & b</b>", "a<b": int64(1)}
+	opts := SerializeOptions{SortKeys: true, EscapeHTML: true}
+	got := SerializeWithOptions(v, opts)
+	want := `"a\u003cb"=1,html="\u003c/script\u003e\u003cb\u003ea \u0026 b\u003c/b\u003e"`
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+	back, err := Parse(got)
+	if err != nil || !reflect.DeepEqual(back, v) {
+		t.Fatalf("round-trip: got %#v, %v", back, err)
+	}
+	if got := SerializeWithOptions(v, SerializeOptions{SortKeys: true}); got != `a<b=1,html="</script><b>a & b</b>"` {
+		t.Fatalf("default should not escape: got %s", got)
+	}
+}
+
+func TestSerializeIsDeterministic(t *testing.T) {
+	// Map iteration order changes from one range loop to the next, so
+	// serializing the same value repeatedly exercises it.
+	keys := "qwertyuiopasdfghjklzxcvbnm"
+	obj := Object{}
+	plain := map[string]interface{}{}
+	for i, k := range keys {
+		inner := Object{}
+		plainInner := map[string]interface{}{}
+		for _, k2 := range keys[:i%7+2] {
+			inner[string(k2)] = int64(i)
+			plainInner[string(k2)] = []interface{}{string(k), map[string]interface{}{"x": 1, "y": 2}}
+		}
+		obj[string(k)] = Object{"inner": inner, "list": Array{inner, Array{inner}}}
+		plain[string(k)] = plainInner
+	}
+	for _, opts := range []SerializeOptions{
+		{},
+		{Indent: "  "},
+		{Indent: "  ", MaxInlineWidth: 40, AlignEquals: true},
+		{NaturalSort: true},
+		{KeyOrder: []string{"m", "a"}},
+	} {
+		for _, v := range []Value{obj, plain, Array{obj, plain}} {
+			first := SerializeWithOptions(v, opts)
+			for i := 0; i < 20; i++ {
+				if got := SerializeWithOptions(v, opts); got != first {
+					t.Fatalf("options %+v: output changed between runs:\n%s\n%s", opts, first, got)
+				}
+			}
+		}
+	}
+	first := Minify(obj)
+	for i := 0; i < 20; i++ {
+		if Minify(obj) != first {
+			t.Fatal("Minify output changed between runs")
+		}
+	}
+	if got := Serialize(Object{"b": int64(1), "a": Object{"d": true, "c": false}}); got != "a={c=false,d=true},b=1" {
+		t.Fatalf("keys should be sorted by default: %s", got)
+	}
+}
+
+func TestSerializeColor(t *testing.T) {
+	v := Object{"name": "x", "n": int64(1), "ok": true, "none": nil, "list": Array{1.5, "y"}}
+	if got := Serialize(v); strings.Contains(got, "\x1b") {
+		t.Fatalf("escape codes without Color: %q", got)
+	}
+	const (
+		key, str, num, boolean, null, reset = "\x1b[1;34m", "\x1b[32m", "\x1b[36m", "\x1b[33m", "\x1b[90m", "\x1b[0m"
+	)
+	got := SerializeWithOptions(v, SerializeOptions{Color: true})
+	want := key + "list" + reset + "=[" + num + "1.5" + reset + "," + str + `"y"` + reset + "]," +
+		key + "n" + reset + "=" + num + "1" + reset + "," +
+		key + "name" + reset + "=" + str + `"x"` + reset + "," +
+		key + "none" + reset + "=" + null + "null" + reset + "," +
+		key + "ok" + reset + "=" + boolean + "true" + reset
+	if got != want {
+		t.Fatalf("got  %q\nwant %q", got, want)
+	}
+
+	// Codes do not count towards widths: without them the layout is the
+	// plain one.
+	opts := SerializeOptions{Indent: "  ", MaxInlineWidth: 24, AlignEquals: true}
+	nested := Object{"server": Object{"host": "localhost", "port": int64(8080)}, "tags": Array{"a", "b"}}
+	plain := SerializeWithOptions(nested, opts)
+	opts.Color = true
+	if colored := SerializeWithOptions(nested, opts); stripColor(colored) != plain {
+		t.Fatalf("colored layout differs:\n%s\nplain:\n%s", stripColor(colored), plain)
+	}
+
+	scheme := &ColorScheme{Key: "<k>"}
+	if got := SerializeWithOptions(Object{"a": int64(1)}, SerializeOptions{Color: true, ColorScheme: scheme}); got != "<k>a"+reset+"=1" {
+		t.Fatalf("custom scheme: got %q", got)
+	}
+}
+
 // ============================================================================
 // Error positioning
 // ============================================================================
@@ -937,6 +1839,30 @@ func TestSyntaxErrorReportsLineAndColumn(t *testing.T) {
 	}
 }
 
+func TestErrorPositionAfterComments(t *testing.T) {
+	// Comments are skipped in one step; positions after them must still
+	// count every line and column they cover.
+	cases := []struct {
+		input     string
+		line, col int
+	}{
+		{"// one\n// two\nb=+5", 3, 3},
+		{"/* a\nbc */ b=+5", 2, 9},
+		{"a=1 /* x */ /* y\n\n z */  b=+5", 3, 10},
+		{"a=[1, // é\n  +2]", 2, 3},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.input)
+		pe, ok := err.(*ParseError)
+		if !ok {
+			t.Fatalf("%q: expected *ParseError, got %v", c.input, err)
+		}
+		if pe.Line != c.line || pe.Column != c.col {
+			t.Errorf("%q: got line %d col %d, want %d:%d", c.input, pe.Line, pe.Column, c.line, c.col)
+		}
+	}
+}
+
 func TestDuplicateKeyErrorReportsKey(t *testing.T) {
 	_, err := Parse(`a=1, a=2`)
 	if err == nil {
@@ -953,3 +1879,977 @@ func TestDuplicateKeyErrorReportsKey(t *testing.T) {
 		t.Fatalf("got key %q", pe.Key)
 	}
 }
+
+func TestMissingValueAfterEquals(t *testing.T) {
+	// The error points at the '=' whose value is missing.
+	cases := []struct {
+		input     string
+		line, col int
+		key, path string
+		kind      ParseErrorKind
+	}{
+		{"a=,b=2", 1, 2, "a", "a", ParseErrorSyntax},
+		{"a=\n", 1, 2, "a", "a", ParseErrorEOF},
+		{"a = // gone\n", 1, 3, "a", "a", ParseErrorEOF},
+		{"name=\nport=80", 1, 5, "name", "name", ParseErrorSyntax},
+		{"s = { host = }", 1, 12, "host", "s.host", ParseErrorSyntax},
+		{"s = [{ x =, y = 1 }]", 1, 10, "x", "s[0].x", ParseErrorSyntax},
+	}
+	for _, c := range cases {
+		_, err := Parse(c.input)
+		pe, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%q: expected *ParseError, got %v", c.input, err)
+			continue
+		}
+		want := fmt.Sprintf("expected value after '=' for key %q", c.key)
+		if pe.Message != want || pe.Line != c.line || pe.Column != c.col || pe.Path != c.path || pe.Kind != c.kind {
+			t.Errorf("%q: got %d:%d %q in %q (kind %v), want %d:%d %q in %q", c.input,
+				pe.Line, pe.Column, pe.Message, pe.Path, pe.Kind, c.line, c.col, want, c.path)
+		}
+	}
+	// A value on the next line is still the key's value (SPEC §5.1).
+	if _, err := Parse("a=\n[1]\nb=\n2"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnterminatedErrorNamesOpeningPosition(t *testing.T) {
+	// The error is at end of input, but names where the construct opened.
+	cases := map[string]string{
+		"a = [1, 2,\n  3":             "unterminated array opened at 1:5",
+		"a = 1\nb = { c = 1\n  d = 2": "unterminated nested object opened at 2:5",
+		"a = [{x = 1}, {y = 2":        "unterminated nested object opened at 1:15",
+		"a = \"ok\"\nb = 'open":       "unterminated string opened at 2:5",
+		"a = r#\"raw\"":               `unterminated raw string opened at 1:5 (expected closing "\"#")`,
+		"\"key":                       "unterminated string opened at 1:1",
+	}
+	for input, msg := range cases {
+		_, err := Parse(input)
+		pe, ok := err.(*ParseError)
+		if !ok || pe.Message != msg || pe.Kind != ParseErrorEOF || pe.Position != len(input) {
+			t.Errorf("%q: got %v, want %q at end of input", input, err, msg)
+		}
+	}
+	for name, err := range map[string]error{
+		"ParseJSON5":  func() error { _, err := ParseJSON5("{a: [1,\n"); return err }(),
+		"ParseStrict": func() error { _, err := ParseStrict("{\"a\": [1,\n"); return err }(),
+		"MinifyBytes": func() error { _, err := MinifyBytes([]byte("a = [1,\n")); return err }(),
+	} {
+		if err == nil || !strings.Contains(err.Error(), "unterminated array opened at 1:") {
+			t.Errorf("%s: got %v", name, err)
+		}
+	}
+}
+
+// ============================================================================
+// Parse options
+// ============================================================================
+
+func TestUseNumberKeepsLiteral(t *testing.T) {
+	v, err := ParseWithOptions(`big=123456789012345678901234567890, f=1_000.50, h=-0xff`, ParseOptions{UseNumber: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Object{
+		"big": Number("123456789012345678901234567890"),
+		"f":   Number("1000.50"),
+		"h":   Number("-0xff"),
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v want %#v", v, want)
+	}
+}
+
+func TestNumberConversions(t *testing.T) {
+	if i, err := Number("-0xff").Int64(); err != nil || i != -255 {
+		t.Fatalf("got %d, %v", i, err)
+	}
+	if i, err := Number("007").Int64(); err != nil || i != 7 {
+		t.Fatalf("got %d, %v", i, err)
+	}
+	if f, err := Number("1.5e3").Float64(); err != nil || f != 1500 {
+		t.Fatalf("got %v, %v", f, err)
+	}
+	if f, err := Number("0b101").Float64(); err != nil || f != 5 {
+		t.Fatalf("got %v, %v", f, err)
+	}
+	if _, err := Number("1.5").Int64(); err == nil {
+		t.Fatal("expected error")
+	}
+	if s := Number("42").String(); s != "42" {
+		t.Fatalf("got %q", s)
+	}
+}
+
+func TestUseNumberSerializesVerbatim(t *testing.T) {
+	input := `n=123456789012345678901234567890`
+	v, err := ParseWithOptions(input, ParseOptions{UseNumber: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Serialize(v); got != input {
+		t.Fatalf("got %q want %q", got, input)
+	}
+	if got := SerializePretty(v, "  "); got != "n = 123456789012345678901234567890" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestBigNumbersInteger(t *testing.T) {
+	v, err := ParseWithOptions(`id=123456789012345678901234567890, neg=-0xffffffffffffffffffff`, ParseOptions{BigNumbers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := v.(Object)
+	id, ok := obj["id"].(*big.Int)
+	if !ok || id.String() != "123456789012345678901234567890" {
+		t.Fatalf("got %#v", obj["id"])
+	}
+	neg, ok := obj["neg"].(*big.Int)
+	if !ok || neg.String() != "-1208925819614629174706175" {
+		t.Fatalf("got %#v", obj["neg"])
+	}
+	if got := SerializeWithOptions(v, SerializeOptions{SortKeys: true}); got != "id=123456789012345678901234567890,neg=-1208925819614629174706175" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestBigNumbersLeavesSmallValuesAlone(t *testing.T) {
+	v, err := ParseWithOptions(`a=42, b=18446744073709551615, c=1.5`, ParseOptions{BigNumbers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Object{"a": int64(42), "b": uint64(18446744073709551615), "c": 1.5}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v want %#v", v, want)
+	}
+}
+
+func TestBigNumbersHugeDecimal(t *testing.T) {
+	v, err := ParseWithOptions(`x=1.5e400`, ParseOptions{BigNumbers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bf, ok := v.(Object)["x"].(*big.Float)
+	if !ok {
+		t.Fatalf("got %#v", v)
+	}
+	if got := Serialize(v); got != "x=1.5e+400" {
+		t.Fatalf("got %q", got)
+	}
+	roundTrip, err := ParseWithOptions(Serialize(v), ParseOptions{BigNumbers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if roundTrip.(Object)["x"].(*big.Float).Text('g', -1) != bf.Text('g', -1) {
+		t.Fatalf("round trip changed value: %v", roundTrip)
+	}
+}
+
+func TestWithoutBigNumbersLargeIntegerRoundsToFloat(t *testing.T) {
+	v, err := Parse(`id=123456789012345678901234567890`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := v.(Object)["id"].(float64); !ok {
+		t.Fatalf("got %#v", v)
+	}
+}
+
+func TestAllowPartialClosesOpenContainers(t *testing.T) {
+	v, warnings, err := ParseWithWarnings("server={\n  host=\"x\"\n  ports=[80, 443,", ParseOptions{AllowPartial: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Object{"server": Object{"host": "x", "ports": Array{int64(80), int64(443)}}}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v, want %#v", v, want)
+	}
+	if len(warnings) != 2 {
+		t.Fatalf("expected 2 warnings, got %v", warnings)
+	}
+	if got := warnings[0].String(); got != "3:9: unterminated array closed at end of input" {
+		t.Errorf("warning 0: got %q", got)
+	}
+	if got := warnings[1].String(); got != "1:8: unterminated object closed at end of input" {
+		t.Errorf("warning 1: got %q", got)
+	}
+}
+
+func TestAllowPartialKeepsOtherErrors(t *testing.T) {
+	opts := ParseOptions{AllowPartial: true}
+	for _, input := range []string{`a=["x`, `a={b=`, "a=[1 /* open", `a={b=1]`} {
+		if _, _, err := ParseWithWarnings(input, opts); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+}
+
+func TestPartialInputIsStrictByDefault(t *testing.T) {
+	v, warnings, err := ParseWithWarnings(`a=[1,2`, ParseOptions{})
+	if err == nil || v != nil || warnings != nil {
+		t.Fatalf("got %v, %v, %v", v, warnings, err)
+	}
+	if _, warnings, _ := ParseWithWarnings(`a=[1,2]`, ParseOptions{AllowPartial: true}); len(warnings) != 0 {
+		t.Fatalf("complete input should not warn: %v", warnings)
+	}
+}
+
+func TestScalarResolver(t *testing.T) {
+	var seen []string
+	resolve := func(lit string) (Value, bool) {
+		seen = append(seen, lit)
+		if strings.HasSuffix(lit, "MB") {
+			n, err := strconv.ParseInt(strings.TrimSuffix(lit, "MB"), 10, 64)
+			return n << 20, err == nil
+		}
+		switch lit {
+		case "red", "2024-01-02", "#ff8800", "1/2":
+			return "<" + lit + ">", true
+		}
+		return nil, false
+	}
+	input := "size=10MB\ncolor=red, day=2024-01-02 // when\nhex=#ff8800\nratio=1/2\nn=42\nok=true\ntags=[red, 3]"
+	v, err := ParseWithOptions(input, ParseOptions{ScalarResolver: resolve})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Object{
+		"size":  int64(10 << 20),
+		"color": "<red>",
+		"day":   "<2024-01-02>",
+		"hex":   "<#ff8800>",
+		"ratio": "<1/2>",
+		"n":     int64(42),
+		"ok":    true,
+		"tags":  Array{"<red>", int64(3)},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v, want %#v", v, want)
+	}
+	for _, lit := range seen {
+		if lit == "42" || lit == "true" || lit == "3" {
+			t.Errorf("resolver was called for built-in literal %q", lit)
+		}
+	}
+}
+
+func TestScalarResolverDeclinesKeepsError(t *testing.T) {
+	decline := func(string) (Value, bool) { return nil, false }
+	for _, input := range []string{"a=10MB", "a=blue", "a=nulls"} {
+		_, want := Parse(input)
+		_, got := ParseWithOptions(input, ParseOptions{ScalarResolver: decline})
+		if want == nil || got == nil || got.Error() != want.Error() {
+			t.Errorf("%q: got %v, want %v", input, got, want)
+		}
+	}
+}
+
+func TestLeadingZerosLenientByDefault(t *testing.T) {
+	v, err := Parse("a=007")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if v.(Object)["a"] != int64(7) {
+		t.Fatalf("got %#v", v)
+	}
+}
+
+func TestStrictNumbersRejectsLeadingZeros(t *testing.T) {
+	opts := ParseOptions{StrictNumbers: true}
+	_, err := ParseWithOptions("a=1\nb=-007", opts)
+	pe, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("expected *ParseError, got %v", err)
+	}
+	if pe.Message != "leading zeros are not allowed in numbers" || pe.Line != 2 || pe.Column != 4 {
+		t.Fatalf("got %d:%d %q", pe.Line, pe.Column, pe.Message)
+	}
+	for _, input := range []string{"a=00", "a=01.5", "a=0_1"} {
+		if _, err := ParseWithOptions(input, opts); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+	for _, input := range []string{"a=0", "a=-0", "a=0.5", "a=0e3", "a=10", "a=0x0f", "a=[0, 0]"} {
+		if _, err := ParseWithOptions(input, opts); err != nil {
+			t.Errorf("%q: unexpected error: %v", input, err)
+		}
+	}
+}
+
+func TestStringConcat(t *testing.T) {
+	opts := ParseOptions{AllowStringConcat: true}
+	input := "msg = \"Hello \" + 'big ' +\n  r\"wide\" // tail\n  + \" world\"\nnext = \"a\"+\"b\"\nlist = [\"x\" + \"y\"\n  \"z\"]"
+	v, err := ParseWithOptions(input, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Object{"msg": "Hello big wide world", "next": "ab", "list": Array{"xy", "z"}}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v, want %#v", v, want)
+	}
+}
+
+func TestStringConcatErrors(t *testing.T) {
+	opts := ParseOptions{AllowStringConcat: true}
+	for _, input := range []string{`a="x" + 1`, `a="x" + true`, `a="x" +`, `a=1 + "x"`, `a="x" + ["y"]`} {
+		if _, err := ParseWithOptions(input, opts); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+	_, err := ParseWithOptions(`a="x" + 1`, opts)
+	if err == nil || !strings.Contains(err.Error(), "only strings can be concatenated") {
+		t.Errorf("got %v", err)
+	}
+	if _, err := Parse(`a="x" + "y"`); err == nil {
+		t.Error("concatenation should be off by default")
+	}
+}
+
+func TestKeepNumberLiteralsRoundTrip(t *testing.T) {
+	input := "a=1_000,b=1.50,c=-0x00FF,d=1E+3,e=007"
+	v, err := ParseWithOptions(input, ParseOptions{KeepNumberLiterals: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	obj := v.(Object)
+	if obj["a"] != Number("1_000") || obj["b"] != Number("1.50") {
+		t.Fatalf("got %#v", obj)
+	}
+	if got := SerializeWithOptions(v, SerializeOptions{SortKeys: true}); got != input {
+		t.Fatalf("got %q, want %q", got, input)
+	}
+	checks := []struct {
+		key  string
+		want int64
+	}{{"a", 1000}, {"c", -255}, {"e", 7}}
+	for _, c := range checks {
+		if i, err := obj[c.key].(Number).Int64(); err != nil || i != c.want {
+			t.Errorf("%s: Int64() = %d, %v; want %d", c.key, i, err, c.want)
+		}
+	}
+	if f, err := obj["b"].(Number).Float64(); err != nil || f != 1.5 {
+		t.Errorf("Float64() = %v, %v", f, err)
+	}
+}
+
+func TestDottedKeysAsNesting(t *testing.T) {
+	opts := ParseOptions{DottedKeysAsNesting: true}
+	input := "server.host = \"x\"\nserver.port = 8080\nserver.tls.on = true\n\"log.level\" = \"debug\"\nname = \"app\"\nnested = { a.b = 1 }"
+	v, err := ParseWithOptions(input, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Object{
+		"server":    Object{"host": "x", "port": int64(8080), "tls": Object{"on": true}},
+		"log.level": "debug",
+		"name":      "app",
+		"nested":    Object{"a.b": int64(1)},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v, want %#v", v, want)
+	}
+	if v, _ := Parse("a.b = 1"); !reflect.DeepEqual(v, Object{"a.b": int64(1)}) {
+		t.Fatalf("dotted keys should be literal by default, got %#v", v)
+	}
+}
+
+func TestDottedKeysAsNestingConflicts(t *testing.T) {
+	opts := ParseOptions{DottedKeysAsNesting: true}
+	cases := map[string]string{
+		"a = 1\na.b = 2":     `dotted key "a.b" needs "a" to be an object, but it is already set to a number`,
+		"a.b = 1\na.b.c = 2": `dotted key "a.b.c" needs "a.b" to be an object, but it is already set to a number`,
+		"a.b = 1\na.b = 2":   `duplicate key "a.b"`,
+		"a.b = 1\na = 2":     `duplicate key "a"`,
+		"a..b = 1":           `empty segment in dotted key "a..b"`,
+	}
+	for input, msg := range cases {
+		_, err := ParseWithOptions(input, opts)
+		pe, ok := err.(*ParseError)
+		if !ok || pe.Message != msg {
+			t.Errorf("%q: got %v, want %q", input, err, msg)
+		}
+	}
+	_, err := ParseWithOptions("a = 1\na.b = 2", opts)
+	if pe := err.(*ParseError); pe.Line != 2 || pe.Column != 1 {
+		t.Errorf("conflict reported at %d:%d, want 2:1", pe.Line, pe.Column)
+	}
+}
+
+func TestDottedArrayIndexes(t *testing.T) {
+	opts := ParseOptions{DottedKeysAsNesting: true, DottedArrayIndexes: true}
+	v, err := ParseWithOptions(`items.2 = "c"
+items.0 = "a"
+servers.1.host = "b"
+servers.0.host = "a"
+servers.0.port = 80
+grid.1.1 = true
+codes.01 = "key"
+0.x = 1
+servers.1.tags.0 = "t"
+items.1 = null
+list = [1]
+list.2 = 3`, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Object{
+		"items": Array{"a", nil, "c"},
+		"servers": Array{
+			Object{"host": "a", "port": int64(80)},
+			Object{"host": "b", "tags": Array{"t"}},
+		},
+		"grid":  Array{nil, Array{nil, true}},
+		"codes": Object{"01": "key"},
+		"0":     Object{"x": int64(1)},
+		"list":  Array{int64(1), nil, int64(3)},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v\nwant %#v", v, want)
+	}
+	if v, _ := ParseWithOptions("items.0 = 1", ParseOptions{DottedKeysAsNesting: true}); !reflect.DeepEqual(v, Object{"items": Object{"0": int64(1)}}) {
+		t.Fatalf("digit segments should be keys by default, got %#v", v)
+	}
+
+	_, positions, err := ParseWithPositions("a.1 = 2", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := positions["a[1]"]; !ok {
+		t.Fatalf("positions = %v, want an entry for a[1]", positions)
+	}
+}
+
+func TestDottedArrayIndexesConflicts(t *testing.T) {
+	opts := ParseOptions{DottedKeysAsNesting: true, DottedArrayIndexes: true}
+	cases := map[string]string{
+		"a.0 = 1\na.0 = 2":     `duplicate key "a.0"`,
+		"a.b = 1\na.0 = 2":     `dotted key "a.0" indexes "a" as an array, but it is already set to an object`,
+		"a.0 = 1\na.b = 2":     `dotted key "a.b" needs "a" to be an object, but it is already set to an array`,
+		"a = [1]\na.0 = 2":     `duplicate key "a.0"`,
+		"a.0 = 1\na.99999 = 2": `dotted key "a.99999" indexes too far past the end of "a"`,
+		"a.0 = 1\na.0.b = 2":   `dotted key "a.0.b" needs "a.0" to be an object, but it is already set to a number`,
+	}
+	for input, msg := range cases {
+		_, err := ParseWithOptions(input, opts)
+		pe, ok := err.(*ParseError)
+		if !ok || pe.Message != msg {
+			t.Errorf("%q: got %v, want %q", input, err, msg)
+		}
+	}
+}
+
+func TestAllowSemicolons(t *testing.T) {
+	opts := ParseOptions{AllowSemicolons: true}
+	cases := map[string]Value{
+		"a=1; b=2":           Object{"a": int64(1), "b": int64(2)},
+		"a=1;\nb=[1; 2;];":   Object{"a": int64(1), "b": Array{int64(1), int64(2)}},
+		"s={x=true; y=null}": Object{"s": Object{"x": true, "y": nil}},
+		"1; \"two\"; 3":      Array{int64(1), "two", int64(3)},
+		"a=\"x;y\"; b='z'":   Object{"a": "x;y", "b": "z"},
+	}
+	for input, want := range cases {
+		v, err := ParseWithOptions(input, opts)
+		if err != nil || !reflect.DeepEqual(v, want) {
+			t.Errorf("%q: got %#v, %v; want %#v", input, v, err, want)
+		}
+	}
+	if _, err := Parse("a=1; b=2"); err == nil {
+		t.Error("semicolons should be rejected by default")
+	}
+	if _, err := ParseWithOptions("a=1;; b=2", opts); err == nil {
+		t.Error("a doubled separator should still be an error")
+	}
+}
+
+func TestBase64RoundTrip(t *testing.T) {
+	opts := ParseOptions{AllowBase64: true}
+	data := []byte{0x00, 'H', 'i', 0xff, 0x10}
+	text := SerializeWithOptions(Object{"key": data, "empty": []byte{}}, SerializeOptions{Base64: true})
+	if !strings.Contains(text, `key=b64"AEhp/xA="`) || !strings.Contains(text, `empty=b64""`) {
+		t.Fatalf("got %q", text)
+	}
+	v, err := ParseWithOptions(text, opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(v, Object{"key": data, "empty": []byte{}}) {
+		t.Fatalf("got %#v", v)
+	}
+	v, _ = ParseWithOptions(`key=b64"SGVsbG8="`, opts)
+	if got := v.(Object)["key"]; string(got.([]byte)) != "Hello" {
+		t.Fatalf("got %q", got)
+	}
+	var nilBytes []byte
+	if got := Serialize(Object{"k": nilBytes}); got != "k=null" {
+		t.Fatalf("nil []byte: got %q", got)
+	}
+}
+
+func TestBytesDefaultRoundTrip(t *testing.T) {
+	data := []byte{0x00, 'H', 'i', 0xff}
+	in := Object{"key": data, "empty": []byte{}}
+	for _, opts := range []SerializeOptions{{}, {Indent: "  "}} {
+		text := SerializeWithOptions(in, opts)
+		v, err := Parse(text)
+		if err != nil {
+			t.Fatalf("%q: %v", text, err)
+		}
+		want := Object{"key": Array{int64(0), int64(72), int64(105), int64(255)}, "empty": Array{}}
+		if !reflect.DeepEqual(v, want) {
+			t.Fatalf("%q: got %#v", text, v)
+		}
+		var out struct {
+			Key   []byte `jhon:"key"`
+			Empty []byte `jhon:"empty"`
+		}
+		if err := Unmarshal(text, &out); err != nil || string(out.Key) != string(data) || out.Empty == nil {
+			t.Fatalf("%q: got %v, %#v", text, err, out)
+		}
+	}
+	if got := Serialize(Object{"k": []byte("hi")}); got != "k=[104,105]" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestBase64Errors(t *testing.T) {
+	opts := ParseOptions{AllowBase64: true}
+	_, err := ParseWithOptions("k=1\nbad=b64\"not*base64\"", opts)
+	pe, ok := err.(*ParseError)
+	if !ok || pe.Line != 2 || pe.Column != 5 || !strings.Contains(pe.Message, "invalid base64") {
+		t.Fatalf("got %v", err)
+	}
+	if _, err := Parse(`k=b64"SGk="`); err == nil {
+		t.Fatal("b64 strings should be rejected by default")
+	}
+}
+
+func TestAllowDuplicateKeysLastWins(t *testing.T) {
+	v, err := ParseWithOptions("a=1\nb={x=1, x=2}\na=3", ParseOptions{AllowDuplicateKeys: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := (Object{"a": int64(3), "b": Object{"x": int64(2)}}); !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v", v)
+	}
+}
+
+func TestMergeDuplicateObjects(t *testing.T) {
+	input := `db = { host = "x", pool = { min = 1 } }
+db = { port = 5432, pool = { max = 8 } }
+svc = { db = { a = 1 }, db = { b = 2 } }`
+	v, err := ParseWithOptions(input, ParseOptions{MergeDuplicateObjects: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Object{
+		"db":  Object{"host": "x", "port": int64(5432), "pool": Object{"min": int64(1), "max": int64(8)}},
+		"svc": Object{"db": Object{"a": int64(1), "b": int64(2)}},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v", v)
+	}
+
+	// Non-object repeats fall back to AllowDuplicateKeys.
+	_, err = ParseWithOptions("db={host=\"x\"}\ndb=1", ParseOptions{MergeDuplicateObjects: true})
+	if pe, ok := err.(*ParseError); !ok || pe.Kind != ParseErrorDuplicateKey {
+		t.Fatalf("expected duplicate key error, got %v", err)
+	}
+	v, err = ParseWithOptions("db={host=\"x\"}\ndb=1", ParseOptions{MergeDuplicateObjects: true, AllowDuplicateKeys: true})
+	if err != nil || !reflect.DeepEqual(v, Object{"db": int64(1)}) {
+		t.Fatalf("got %#v, %v", v, err)
+	}
+
+	// Dotted keys merge into an object written earlier.
+	v, err = ParseWithOptions("a.b={x=1}\na.b={y=2}", ParseOptions{MergeDuplicateObjects: true, DottedKeysAsNesting: true})
+	if err != nil || !reflect.DeepEqual(v, Object{"a": Object{"b": Object{"x": int64(1), "y": int64(2)}}}) {
+		t.Fatalf("got %#v, %v", v, err)
+	}
+}
+
+func TestParseErrorIncludesPath(t *testing.T) {
+	cases := map[string]string{
+		`server={middleware=[{name=}]}`: "server.middleware[0].name",
+		"a=1\nlist=[1, 2, {x=@}]":       "list[2].x",
+		`x={"a.b"={c=[1 2]}}`:           `x["a.b"].c`,
+		"a=1\nsvc={port=1\nport=2}":     "svc",
+		"top=%":                         "top",
+	}
+	for input, path := range cases {
+		_, err := Parse(input)
+		pe, ok := err.(*ParseError)
+		if !ok || pe.Path != path || !strings.Contains(pe.Error(), " in "+path+":") {
+			t.Errorf("%q: got %v (path %q), want path %q", input, err, pe.Path, path)
+		}
+	}
+	_, err := Parse("a=1,a=2")
+	if pe := err.(*ParseError); pe.Path != "" || strings.Contains(pe.Error(), " in ") {
+		t.Errorf("top-level error should carry no path: %v", err)
+	}
+}
+
+func TestExponentThreshold(t *testing.T) {
+	opts := SerializeOptions{ExponentThreshold: 1e6}
+	cases := []struct {
+		in   Value
+		want string
+	}{
+		{1.5e9, "1.5e9"},
+		{1e6, "1e6"},
+		{-2.5e6, "-2.5e6"},
+		{999999.5, "999999.5"},
+		{1234567.5, "1.2345675e6"},
+		{0.5, "0.5"},
+		{1e-6, "0.000001"},
+		{9.99e-7, "9.99e-7"},
+		{float32(2e7), "2e7"},
+		{int64(5e9), "5000000000"},
+	}
+	for _, c := range cases {
+		got := SerializeWithOptions(Object{"x": c.in}, opts)
+		if got != "x="+c.want {
+			t.Errorf("%v: got %q, want %q", c.in, got, "x="+c.want)
+			continue
+		}
+		back := MustParse(got).(Object)["x"]
+		if f, ok := c.in.(float32); ok {
+			c.in = float64(f)
+		}
+		if !reflect.DeepEqual(back, c.in) {
+			t.Errorf("%v: read back as %#v", c.in, back)
+		}
+	}
+	if got := Serialize(Object{"x": 1234567.5}); got != "x=1.2345675e+06" {
+		t.Errorf("default: got %q", got)
+	}
+}
+
+func TestNaturalSortKeys(t *testing.T) {
+	obj := Object{"key_10": int64(10), "key_2": int64(2), "key_1": int64(1), "key": nil, "v1.10": true, "v1.9": true, "x07": 0, "x7": 0}
+	got := SerializeWithOptions(obj, SerializeOptions{NaturalSort: true})
+	want := "key=null,key_1=1,key_2=2,key_10=10,v1.9=true,v1.10=true,x7=0,x07=0"
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+	if got := SerializeWithOptions(obj, SerializeOptions{SortKeys: true}); !strings.HasPrefix(got, "key=null,key_1=1,key_10=10,key_2=2") {
+		t.Fatalf("SortKeys should stay lexical: %s", got)
+	}
+	got = SerializeWithOptions(Object{"b10": 1, "b9": 2, "a": 3}, SerializeOptions{NaturalSort: true, KeyOrder: []string{"a"}})
+	if got != "a=3,b9=2,b10=1" {
+		t.Fatalf("with KeyOrder: got %s", got)
+	}
+}
+
+func TestQuoteAllKeys(t *testing.T) {
+	obj := Object{"name": "x", "two words": int64(1), "nested": Object{"k": Array{Object{"a": true}}}}
+	opts := SerializeOptions{QuoteAllKeys: true, SortKeys: true}
+	got := SerializeWithOptions(obj, opts)
+	want := `"name"="x","nested"={"k"=[{"a"=true}]},"two words"=1`
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+	opts.Indent = "  "
+	if got := SerializeWithOptions(obj, opts); strings.Contains(got, "\nname") || !strings.Contains(got, `"k" = [`) {
+		t.Fatalf("pretty: got %s", got)
+	}
+	if v := MustParse(got); !reflect.DeepEqual(v, obj) {
+		t.Fatalf("round trip: got %#v", v)
+	}
+	if got := Serialize(obj); !strings.Contains(got, "name=") {
+		t.Fatalf("default should keep bare keys: %s", got)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	deep := strings.Repeat("[", 200) + strings.Repeat("]", 200)
+	if _, err := Parse(deep); err != nil {
+		t.Fatalf("depth 200 should parse: %v", err)
+	}
+	_, err := ParseWithOptions(deep, ParseOptions{MaxDepth: 100})
+	pe, ok := err.(*ParseError)
+	if !ok || pe.Column != 101 || !strings.Contains(pe.Message, "maximum depth of 100") {
+		t.Fatalf("got %v", err)
+	}
+	if _, err := ParseWithOptions("a={b={c=1}}", ParseOptions{MaxDepth: 2}); err != nil {
+		t.Fatalf("depth 2 should fit: %v", err)
+	}
+	if _, err := ParseWithOptions("a={b={c={}}}", ParseOptions{MaxDepth: 2}); err == nil {
+		t.Fatal("depth 3 should fail")
+	}
+	// Hostile input fails cleanly at the default limit.
+	_, err = Parse("a=" + strings.Repeat("{x=[", 1e6))
+	if err == nil || !strings.Contains(err.Error(), "maximum depth") {
+		t.Fatalf("got %v", err)
+	}
+	if _, err := ParseJSON5(strings.Repeat("[", 1e6)); err == nil || !strings.Contains(err.Error(), "maximum depth") {
+		t.Fatalf("JSON5: got %v", err)
+	}
+}
+
+func TestBigNumbersExponentLimit(t *testing.T) {
+	opts := ParseOptions{BigNumbers: true}
+	if v, err := ParseWithOptions("a=1.5e9000", opts); err != nil || v.(Object)["a"].(*big.Float).Sign() != 1 {
+		t.Fatalf("got %v, %v", v, err)
+	}
+	for _, input := range []string{"a=1e99999999", "a=-2.5e40000"} {
+		if _, err := ParseWithOptions(input, opts); err == nil || !strings.Contains(err.Error(), "out of range") {
+			t.Errorf("%q: got %v", input, err)
+		}
+	}
+}
+
+func TestInlineObjectMaxLen(t *testing.T) {
+	value := Object{
+		"point":  Object{"x": int64(1), "y": int64(2)},
+		"server": Object{"host": "db.example.com", "port": int64(5432), "user": "admin"},
+		"tags":   Array{"a", "b"},
+	}
+	got := SerializeWithOptions(value, SerializeOptions{SortKeys: true, Indent: "  ", InlineObjectMaxLen: 20})
+	want := `point = { x = 1, y = 2 }
+server = {
+  host = "db.example.com"
+  port = 5432
+  user = "admin"
+}
+tags = [
+  "a"
+  "b"
+]`
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	got = SerializeWithOptions(value, SerializeOptions{SortKeys: true, Indent: "  ", InlineObjectMaxLen: 1, MaxInlineWidth: 80})
+	if !strings.Contains(got, "tags = [ \"a\", \"b\" ]") || !strings.Contains(got, "point = {\n") {
+		t.Fatalf("arrays should follow MaxInlineWidth, objects InlineObjectMaxLen:\n%s", got)
+	}
+	if !reflect.DeepEqual(MustParse(got), value) {
+		t.Fatal("round trip")
+	}
+}
+
+func TestAlignEquals(t *testing.T) {
+	value := Object{
+		"host":    "localhost",
+		"port":    int64(8080),
+		"enabled": true,
+		"tls":     Object{"cert": "/etc/cert.pem", "verify_peer": false},
+		"point":   Object{"x": int64(1)},
+	}
+	got := SerializeWithOptions(value, SerializeOptions{SortKeys: true, Indent: "  ", MaxInlineWidth: 20, AlignEquals: true})
+	want := `enabled = true
+host    = "localhost"
+point   = { x = 1 }
+port    = 8080
+tls     = {
+  cert        = "/etc/cert.pem"
+  verify_peer = false
+}`
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if !reflect.DeepEqual(MustParse(got), value) {
+		t.Fatal("round trip")
+	}
+	if got := SerializeWithOptions(value, SerializeOptions{SortKeys: true, AlignEquals: true}); strings.Contains(got, "  ") {
+		t.Fatalf("compact output should not be padded: %s", got)
+	}
+}
+
+func TestMergeKeys(t *testing.T) {
+	input := `
+_defaults = { timeout = 30, retries = 3, tags = ["base"] }
+services = {
+  _local = { host = "localhost" }
+  api = { << = "_defaults", timeout = 60 }
+  worker = {
+    timeout = 5
+    << = ["_defaults", "_local", { retries = 9 }]
+  }
+}
+list = [{ << = "_defaults" }]
+`
+	v, err := ParseWithOptions(input, ParseOptions{AllowMergeKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defaults := Object{"timeout": int64(30), "retries": int64(3), "tags": Array{"base"}}
+	want := Object{
+		"_defaults": defaults,
+		"services": Object{
+			"_local": Object{"host": "localhost"},
+			"api":    Object{"timeout": int64(60), "retries": int64(3), "tags": Array{"base"}},
+			"worker": Object{"timeout": int64(5), "retries": int64(9), "tags": Array{"base"}, "host": "localhost"},
+		},
+		"list": Array{defaults},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v\nwant %#v", v, want)
+	}
+	services := v.(Object)["services"].(Object)
+	services["api"].(Object)["tags"].(Array)[0] = "edited"
+	if got := v.(Object)["_defaults"].(Object)["tags"].(Array)[0]; got != "base" {
+		t.Fatalf("merged values share storage with their source: %v", got)
+	}
+
+	// Without the option `<<` is an ordinary key.
+	v = MustParse(`a = { << = "b" }`)
+	if !reflect.DeepEqual(v, Object{"a": Object{"<<": "b"}}) {
+		t.Fatalf("got %#v", v)
+	}
+}
+
+func TestMergeKeyErrors(t *testing.T) {
+	cases := []struct {
+		input, msg string
+	}{
+		{"a = { << = \"later\" }\nlater = {}", `"later", which is not defined`},
+		{"x = 1\na = { << = \"x\" }", "must be an object, not a number"},
+		{"a = { << = [1] }", "must be an object"},
+		{"a = { << = \"a\" }", "not defined"},
+	}
+	for _, c := range cases {
+		_, err := ParseWithOptions(c.input, ParseOptions{AllowMergeKeys: true})
+		pe, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("%q: got %v, want a *ParseError", c.input, err)
+			continue
+		}
+		if !strings.Contains(pe.Message, c.msg) {
+			t.Errorf("%q: got %q, want %q", c.input, pe.Message, c.msg)
+		}
+	}
+}
+
+func TestBareKeyExtraChars(t *testing.T) {
+	// '.' and ':' need no option: they were never delimiters.
+	v, err := Parse("log.level = 1\nhost:port = 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Serialize(v); got != "host:port=2,log.level=1" {
+		t.Fatalf("got %q", got)
+	}
+
+	input := "path/to/thing = 1 // a comment\nc#sharp = 2\n/* block */ /root = 3\nsplit/*x*/ = 4"
+	if _, err := Parse(input); err == nil {
+		t.Fatal("expected '/' in a bare key to be an error by default")
+	}
+	opts := ParseOptions{BareKeyExtraChars: "/#"}
+	v, err = ParseWithOptions(input, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Object{"path/to/thing": int64(1), "c#sharp": int64(2), "/root": int64(3), "split": int64(4)}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v want %#v", v, want)
+	}
+	if _, err := ParseWithOptions("a b = 1", ParseOptions{BareKeyExtraChars: " ="}); err == nil {
+		t.Fatal("delimiters other than '/' and '#' should stay delimiters")
+	}
+
+	obj := Object{"path/to/thing": int64(1), "c#sharp": int64(2), "a//b": int64(3), "a/*b": int64(4)}
+	ser := SerializeWithOptions(obj, SerializeOptions{BareKeyExtraChars: "/#"})
+	if want := `"a/*b"=4,"a//b"=3,c#sharp=2,path/to/thing=1`; ser != want {
+		t.Fatalf("got %s want %s", ser, want)
+	}
+	back, err := ParseWithOptions(ser, opts)
+	if err != nil || !reflect.DeepEqual(back, obj) {
+		t.Fatalf("round trip: %#v, %v", back, err)
+	}
+	if got := Serialize(Object{"path/to": int64(1)}); got != `"path/to"=1` {
+		t.Fatalf("got %s", got)
+	}
+}
+
+func TestAllowHexFloat(t *testing.T) {
+	opts := ParseOptions{AllowHexFloat: true}
+	cases := map[string]float64{
+		"0x1.8p3":                12.0,
+		"-0x1.8p3":               -12.0,
+		"0x1p-2":                 0.25,
+		"0x1P+4":                 16.0,
+		"0xA_0.8p0":              160.5,
+		"0x1.fffffffffffffp1023": math.MaxFloat64,
+		"0x1p-1074":              math.SmallestNonzeroFloat64,
+	}
+	for input, want := range cases {
+		v, err := ParseWithOptions("x = "+input, opts)
+		if err != nil {
+			t.Errorf("%s: %v", input, err)
+			continue
+		}
+		if got := v.(Object)["x"]; got != want {
+			t.Errorf("%s: got %#v want %v", input, got, want)
+		}
+	}
+	for _, numOpts := range []ParseOptions{{AllowHexFloat: true, UseNumber: true}, {AllowHexFloat: true, KeepNumberLiterals: true}} {
+		v, err := ParseWithOptions("x = 0x1.8p3", numOpts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, ok := v.(Object)["x"].(Number)
+		if !ok || n != "0x1.8p3" {
+			t.Fatalf("%+v: got %#v", numOpts, v)
+		}
+		if f, err := n.Float64(); err != nil || f != 12 {
+			t.Errorf("%+v: Float64 got %v, %v, want 12", numOpts, f, err)
+		}
+	}
+	if v, _ := ParseWithOptions("x = 0x18", opts); v.(Object)["x"] != int64(24) {
+		t.Errorf("hex integers should stay integers, got %#v", v)
+	}
+
+	errs := map[string]string{
+		"x = 0x1.p3": "expected hex digits after '.' in hexadecimal float",
+		"x = 0x1.8":  "hexadecimal float requires a 'p' exponent, as in 0x1.8p3",
+		"x = 0x1p":   "number requires at least one digit",
+	}
+	for input, msg := range errs {
+		_, err := ParseWithOptions(input, opts)
+		if pe, ok := err.(*ParseError); !ok || pe.Message != msg {
+			t.Errorf("%q: got %v, want %q", input, err, msg)
+		}
+	}
+	_, err := Parse("x = 0x1.8p3")
+	if pe, ok := err.(*ParseError); !ok || pe.Message != "hexadecimal floating-point numbers need ParseOptions.AllowHexFloat" {
+		t.Errorf("without the option: got %v", err)
+	}
+}
+
+func TestExtendedBooleans(t *testing.T) {
+	opts := ParseOptions{ExtendedBooleans: true}
+	v, err := ParseWithOptions("a = yes, b = no, c = on, d = off\ne = YES, f = Off, g = [On, nO]\nh = true, i = null, j = \"yes\"", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Object{
+		"a": true, "b": false, "c": true, "d": false,
+		"e": true, "f": false, "g": Array{true, false},
+		"h": true, "i": nil, "j": "yes",
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v want %#v", v, want)
+	}
+	if got := Serialize(Object{"a": v.(Object)["a"]}); got != "a=true" {
+		t.Fatalf("Serialize wrote %q", got)
+	}
+	for _, input := range []string{"a = yess", "a = nope", "a = o", "a = on-call"} {
+		if _, err := ParseWithOptions(input, opts); err == nil {
+			t.Errorf("%q: expected an error", input)
+		}
+	}
+
+	// Off by default: the words are not values, and the error says how to
+	// write the string.
+	_, err = Parse("enabled = yes")
+	msg := `unquoted yes is not a value; quote it as "yes", or set ParseOptions.ExtendedBooleans to read it as a boolean`
+	if pe, ok := err.(*ParseError); !ok || pe.Message != msg {
+		t.Fatalf("got %v", err)
+	}
+	resolver := func(s string) (Value, bool) { return "resolved " + s, true }
+	v, err = ParseWithOptions("a = off", ParseOptions{ScalarResolver: resolver})
+	if err != nil || v.(Object)["a"] != "resolved off" {
+		t.Fatalf("ScalarResolver should see the word without the option: %#v, %v", v, err)
+	}
+}
@@ -0,0 +1,371 @@
+package jhon
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// JSON5 compatibility mode
+//
+// JSON5 (https://spec.json5.org) overlaps heavily with JHON: comments,
+// trailing commas, single quotes and unquoted keys. The JSON5 parser shares
+// the JHON scanner for whitespace, comments and strings, and has its own
+// grammar for the rest, since a JSON5 document is one value, uses ':' and
+// requires commas.
+// ============================================================================
+
+// ParseJSON5 parses a JSON5 document into the same Value types Parse
+// returns. It accepts:
+//
+//   - a single value of any kind at the top level, so `{a: 1}` is an
+//     Object, not an Array holding one as in JHON;
+//   - `key: value` pairs with ECMAScript identifier or quoted keys;
+//   - // and /* */ comments and trailing commas;
+//   - single- and double-quoted strings with line continuations and the
+//     JSON5 escapes \v, \0 and identity escapes such as \q;
+//   - hexadecimal integers, a leading '+', leading or trailing decimal
+//     points (.5, 5.), and Infinity and NaN.
+//
+// Integers decode as int64, or uint64 or float64 when they do not fit, and
+// other numbers as float64. A key that repeats takes its last value, as in
+// JavaScript.
+func ParseJSON5(input string) (Value, error) {
+	p := newParser([]byte(input))
+	p.json5 = true
+	p.skipJSON5Space()
+	if p.openComment != nil {
+		return nil, p.openComment
+	}
+	v, err := p.parseJSON5Value()
+	if err != nil {
+		return nil, err
+	}
+	p.skipJSON5Space()
+	if p.openComment != nil {
+		return nil, p.openComment
+	}
+	if p.pos < len(p.input) {
+		return nil, p.syntaxErr("unexpected content after JSON5 value")
+	}
+	return v, nil
+}
+
+// skipJSON5Space is skipWsAndComments plus the other characters JSON5
+// counts as white space: \v, \f, the byte order mark and Unicode spaces.
+func (p *parser) skipJSON5Space() {
+	for {
+		p.skipWsAndComments()
+		c, ok := p.current()
+		if !ok {
+			return
+		}
+		if c == '\v' || c == '\f' {
+			p.advance()
+			continue
+		}
+		r, size := utf8.DecodeRune(p.input[p.pos:])
+		if r != '\uFEFF' && r != '\u2028' && r != '\u2029' && !unicode.Is(unicode.Zs, r) {
+			return
+		}
+		advanceN(p, size)
+	}
+}
+
+func (p *parser) parseJSON5Value() (Value, error) {
+	c, ok := p.current()
+	if !ok {
+		return nil, p.syntaxErr("expected value")
+	}
+	switch c {
+	case '{':
+		return p.parseJSON5Object()
+	case '[':
+		return p.parseJSON5Array()
+	case '"', '\'':
+		return p.parseString(c)
+	}
+	word := p.json5Word()
+	switch word {
+	case "true", "false", "null":
+		advanceN(p, len(word))
+		switch word {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		}
+		return nil, nil
+	}
+	if c == '+' || c == '-' || c == '.' || isDigit(c) || word == "Infinity" || word == "NaN" {
+		return p.parseJSON5Number()
+	}
+	if word != "" {
+		return nil, p.syntaxErr(fmt.Sprintf("unexpected %q; JSON5 strings must be quoted", word))
+	}
+	return nil, p.syntaxErr(fmt.Sprintf("unexpected character %q", c))
+}
+
+func (p *parser) parseJSON5Object() (Value, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	open := p.here()
+	p.advance() // {
+	obj := Object{}
+	for {
+		p.skipJSON5Space()
+		c, ok := p.current()
+		switch {
+		case !ok:
+			return nil, p.unterminatedErr("object", open)
+		case c == '}':
+			p.advance()
+			return obj, nil
+		case c == ']':
+			return nil, p.mismatchErr('}', "object", open)
+		}
+		key, err := p.parseJSON5Key()
+		if err != nil {
+			return nil, err
+		}
+		p.skipJSON5Space()
+		if c, ok := p.current(); !ok || c != ':' {
+			return nil, p.syntaxErr(fmt.Sprintf("expected ':' after key %q", key))
+		}
+		p.advance()
+		p.skipJSON5Space()
+		p.pushKey(key)
+		val, err := p.parseJSON5Value()
+		p.pop()
+		if err != nil {
+			return nil, err
+		}
+		obj[key] = val
+		if done, err := p.json5ItemEnd('}', "object", open); done || err != nil {
+			return obj, err
+		}
+	}
+}
+
+func (p *parser) parseJSON5Array() (Value, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	open := p.here()
+	p.advance() // [
+	arr := Array{}
+	for {
+		p.skipJSON5Space()
+		c, ok := p.current()
+		switch {
+		case !ok:
+			return nil, p.unterminatedErr("array", open)
+		case c == ']':
+			p.advance()
+			return arr, nil
+		case c == '}':
+			return nil, p.mismatchErr(']', "array", open)
+		}
+		p.pushIndex(len(arr))
+		val, err := p.parseJSON5Value()
+		p.pop()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, val)
+		if done, err := p.json5ItemEnd(']', "array", open); done || err != nil {
+			return arr, err
+		}
+	}
+}
+
+// json5ItemEnd consumes what follows an item: a comma, or the closing
+// delimiter, in which case it reports done.
+func (p *parser) json5ItemEnd(closer byte, construct string, open nodePos) (bool, error) {
+	p.skipJSON5Space()
+	c, ok := p.current()
+	switch {
+	case !ok:
+		return false, p.unterminatedErr(construct, open)
+	case c == ',':
+		p.advance()
+		return false, nil
+	case c == closer:
+		p.advance()
+		return true, nil
+	case c == '}' || c == ']':
+		return false, p.mismatchErr(closer, construct, open)
+	}
+	return false, p.syntaxErr(fmt.Sprintf("expected ',' or '%c' after %s item", closer, construct))
+}
+
+// parseJSON5Key parses a quoted key or an ECMAScript identifier.
+func (p *parser) parseJSON5Key() (string, error) {
+	c, _ := p.current()
+	if c == '"' || c == '\'' {
+		return p.parseString(c)
+	}
+	word := p.json5Word()
+	if word == "" {
+		return "", p.syntaxErr(fmt.Sprintf("unexpected character %q; expected a key", c))
+	}
+	if r, _ := utf8.DecodeRuneInString(word); !isIdentStart(r) {
+		return "", p.syntaxErr(fmt.Sprintf("key %q is not an identifier; quote it", word))
+	}
+	advanceN(p, len(word))
+	return word, nil
+}
+
+// json5Word returns the run of identifier characters at the current
+// position without consuming it.
+func (p *parser) json5Word() string {
+	end := p.pos
+	for end < len(p.input) {
+		r, size := utf8.DecodeRune(p.input[end:])
+		if !isIdentStart(r) && !unicode.IsDigit(r) && !unicode.Is(unicode.Mn, r) &&
+			!unicode.Is(unicode.Mc, r) && !unicode.Is(unicode.Pc, r) && r != '\u200C' && r != '\u200D' {
+			break
+		}
+		end += size
+	}
+	return string(p.input[p.pos:end])
+}
+
+func isIdentStart(r rune) bool {
+	return r == '$' || r == '_' || unicode.IsLetter(r) || unicode.Is(unicode.Nl, r)
+}
+
+// parseJSON5Number parses a JSON5 numeric literal.
+func (p *parser) parseJSON5Number() (Value, error) {
+	start := p.here()
+	neg := false
+	if c, _ := p.current(); c == '+' || c == '-' {
+		neg = c == '-'
+		p.advance()
+	}
+	for _, lit := range []string{"Infinity", "NaN"} {
+		if matchesLiteral(p.input, p.pos, lit) {
+			advanceN(p, len(lit))
+			switch {
+			case lit == "NaN":
+				return math.NaN(), nil
+			case neg:
+				return math.Inf(-1), nil
+			}
+			return math.Inf(1), nil
+		}
+	}
+	bad := func() error {
+		return p.errAt(start, fmt.Sprintf("invalid number %q", string(p.input[start.offset:p.pos])))
+	}
+	digits := func(ok func(byte) bool) string {
+		from := p.pos
+		for c, more := p.current(); more && ok(c); c, more = p.current() {
+			p.advance()
+		}
+		return string(p.input[from:p.pos])
+	}
+	if matchesLiteral(p.input, p.pos, "0x") || matchesLiteral(p.input, p.pos, "0X") {
+		advanceN(p, 2)
+		hex := digits(func(c byte) bool { _, ok := hexDigit(c); return ok })
+		u, err := strconv.ParseUint(hex, 16, 64)
+		if err != nil {
+			return nil, bad()
+		}
+		return signedInteger(u, neg), nil
+	}
+	intPart := digits(isDigit)
+	if len(intPart) > 1 && intPart[0] == '0' {
+		return nil, bad()
+	}
+	var frac, exp string
+	if c, _ := p.current(); c == '.' {
+		p.advance()
+		frac = "." + digits(isDigit)
+	}
+	if intPart == "" && len(frac) < 2 {
+		return nil, bad()
+	}
+	if c, _ := p.current(); c == 'e' || c == 'E' {
+		p.advance()
+		exp = "e"
+		if c, _ := p.current(); c == '+' || c == '-' {
+			p.advance()
+			exp += string(c)
+		}
+		d := digits(isDigit)
+		if d == "" {
+			return nil, bad()
+		}
+		exp += d
+	}
+	if c, ok := p.current(); ok && (isIdentStart(rune(c)) || isDigit(c)) {
+		p.advance()
+		return nil, bad()
+	}
+	if frac == "" && exp == "" {
+		if u, err := strconv.ParseUint(intPart, 10, 64); err == nil {
+			return signedInteger(u, neg), nil
+		}
+	}
+	text := intPart
+	if text == "" {
+		text = "0"
+	}
+	if frac != "" {
+		text += frac + "0" // "5." and ".5" need the digit Go requires
+	}
+	f, err := strconv.ParseFloat(text+exp, 64)
+	if err != nil && !isRangeErr(err) {
+		return nil, bad()
+	}
+	if neg {
+		f = -f
+	}
+	return f, nil
+}
+
+// signedInteger applies a sign to an unsigned magnitude, returning int64
+// where it fits, uint64 for large positive values and float64 otherwise.
+func signedInteger(u uint64, neg bool) Value {
+	switch {
+	case !neg && u <= math.MaxInt64:
+		return int64(u)
+	case !neg:
+		return u
+	case u <= 1<<63:
+		return -int64(u-1) - 1
+	}
+	return -float64(u)
+}
+
+func isRangeErr(err error) bool {
+	ne, ok := err.(*strconv.NumError)
+	return ok && ne.Err == strconv.ErrRange
+}
+
+// json5Escape handles the escapes JSON5 adds to JHON's: \v, \0 and an
+// identity escape for any other character that is not a digit.
+func (p *parser) json5Escape(esc byte, sb *strings.Builder) error {
+	switch {
+	case esc == 'v':
+		sb.WriteByte('\v')
+	case esc == '0':
+		if c, ok := p.current(); ok && isDigit(c) {
+			return p.syntaxErr("\\0 may not be followed by a digit")
+		}
+		sb.WriteByte(0)
+	case isDigit(esc):
+		return p.syntaxErr(fmt.Sprintf("unknown escape \\%c", esc))
+	default:
+		sb.WriteByte(esc) // the rest of a multi-byte character follows as is
+	}
+	return nil
+}
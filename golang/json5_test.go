@@ -0,0 +1,98 @@
+package jhon
+
+import (
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseJSON5Samples(t *testing.T) {
+	cases := map[string]Value{
+		"kitchen-sink.json5": Object{
+			"unquoted":            "and you can quote me on that",
+			"singleQuotes":        `I can use "double quotes" here`,
+			"lineBreaks":          `Look, Mom! No \n's!`,
+			"hexadecimal":         int64(0xdecaf),
+			"leadingDecimalPoint": 0.8675309,
+			"andTrailing":         8675309.0,
+			"positiveSign":        int64(1),
+			"trailingComma":       "in objects",
+			"andIn":               Array{"arrays"},
+			"backwardsCompatible": "with JSON",
+		},
+		"config.json5": Object{
+			"$schema": "./schema.json",
+			"server": Object{
+				"host":     "localhost",
+				"port":     int64(8080),
+				"timeouts": Object{"read": 25.0, "write": 0.1},
+			},
+			"limits":   Array{math.Inf(1), math.Inf(-1), int64(-16), math.Inf(1)},
+			"escapes":  "\v\x00qAé",
+			"_private": nil,
+			"enabled":  true,
+			"beta":     false,
+			"ünïcödé":  "keys",
+		},
+	}
+	for name, want := range cases {
+		data, err := os.ReadFile("testdata/json5/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := ParseJSON5(string(data))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s:\ngot  %#v\nwant %#v", name, got, want)
+		}
+	}
+}
+
+func TestParseJSON5TopLevel(t *testing.T) {
+	cases := map[string]Value{
+		`[1, 2,]`:         Array{int64(1), int64(2)},
+		`"just a string"`: "just a string",
+		`-.5`:             -0.5,
+		` 42 // answer`:   int64(42),
+		`{a: 1, a: 2}`:    Object{"a": int64(2)},
+		`{}`:              Object{},
+	}
+	for input, want := range cases {
+		got, err := ParseJSON5(input)
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %#v, %v", input, got, err)
+		}
+	}
+	if v, err := ParseJSON5(`NaN`); err != nil || !math.IsNaN(v.(float64)) {
+		t.Errorf("NaN: got %#v, %v", v, err)
+	}
+}
+
+func TestParseJSON5Errors(t *testing.T) {
+	cases := map[string]string{
+		``:              "expected value",
+		`{a: 1} {}`:     "unexpected content after JSON5 value",
+		"{a: 1\nb: 2}":  "expected ',' or '}'",
+		`{a = 1}`:       "expected ':'",
+		`{1a: 1}`:       "is not an identifier",
+		`[bare]`:        "must be quoted",
+		`[01]`:          `invalid number "01"`,
+		`[1.e]`:         "invalid number",
+		`[.]`:           "invalid number",
+		`[5px]`:         "invalid number",
+		`{a: [1}`:       "expected ']' to close array",
+		`"\1"`:          `unknown escape \1`,
+		`{a: 1 /* open`: "unterminated block comment",
+	}
+	for input, want := range cases {
+		_, err := ParseJSON5(input)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%q: got %v, want %q", input, err, want)
+		}
+	}
+}
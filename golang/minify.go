@@ -0,0 +1,259 @@
+package jhon
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ============================================================================
+// Minify — the shortest output for size-sensitive transport.
+// ============================================================================
+
+// Minify returns the shortest JHON text for v that parses back to the same
+// value: compact layout, keys sorted as in Serialize, and for each
+// string the shortest of the double-quoted, single-quoted and raw (`r"..."`)
+// spellings. Floats use exponent form when that is shorter, so 1e21 stays
+// `1e21` rather than growing to 22 digits. Integers are written in decimal,
+// since switching to exponent form would turn them into floats.
+//
+// Minify is the size-oriented counterpart of pretty printing; use
+// SerializeWithOptions for stable or human-oriented output.
+func Minify(v Value) string {
+	return SerializeWithOptions(v, SerializeOptions{minify: true})
+}
+
+// MinifyBytes rewrites a JHON document as Minify would write its value,
+// without building the value: comments and whitespace are dropped, newline
+// separators become commas, and each key, string and number takes its
+// shortest spelling. It is a single pass over input that only holds the
+// keys of the objects being read, for shrinking config before it is sent
+// over the wire. Keys keep their source order.
+//
+// Input that Parse rejects is rejected with the same kinds of errors, and
+// the output parses back to a value Equal to the one Parse returns for
+// input. Equal, not identical: a whole float such as 1.50e3 is written 1500
+// and reads back as int64(1500).
+func MinifyBytes(input []byte) ([]byte, error) {
+	m := &minifier{p: newParser(input)}
+	m.sb.Grow(len(input))
+	p := m.p
+	p.skipWsAndComments()
+	if p.openComment != nil {
+		return nil, p.openComment
+	}
+	var err error
+	switch {
+	case p.pos >= len(p.input):
+		return []byte{}, nil
+	case p.objectMode() || p.looksLikeProperty():
+		err = m.members(0, nodePos{})
+	default:
+		err = m.elements(0, nodePos{})
+	}
+	if err == nil && p.openComment != nil {
+		err = p.openComment
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(m.sb.String()), nil
+}
+
+// minifier writes the minified form of a document as it walks it with the
+// parser's own scanners, in the shape of parseJhonObject, parseNestedObject
+// and parseArray.
+type minifier struct {
+	p  *parser
+	sb strings.Builder
+}
+
+var minifyOptions = SerializeOptions{minify: true}
+
+// members copies the key=value pairs of an object up to closer, or to end
+// of input for a top-level object (closer 0) opened at open.
+func (m *minifier) members(closer byte, open nodePos) error {
+	p := m.p
+	seen := map[string]bool{}
+	p.skipWsAndComments()
+	for {
+		if done, err := m.atClose(closer, '}', "object", open); done || err != nil {
+			return err
+		}
+		if len(seen) > 0 {
+			m.sb.WriteByte(',')
+		}
+		key, err := p.parseKey()
+		if err != nil {
+			return err
+		}
+		p.skipWsAndComments()
+		if c, ok := p.current(); !ok || c != '=' {
+			return p.syntaxErr("expected '=' after key")
+		}
+		eq := p.here()
+		p.advance()
+		if seen[key] {
+			err := p.syntaxErr(fmt.Sprintf("duplicate key %q", key))
+			err.Kind = ParseErrorDuplicateKey
+			err.Key = key
+			return err
+		}
+		seen[key] = true
+		serializeKey(key, minifyOptions, &m.sb)
+		m.sb.WriteByte('=')
+		p.pushKey(key)
+		if err := p.missingValue(key, eq, p.skipWsAndComments()); err != nil {
+			return err
+		}
+		err = m.value()
+		p.pop()
+		if err != nil {
+			return err
+		}
+		if err := m.separator(closer); err != nil {
+			return err
+		}
+	}
+}
+
+// elements copies the values of an array up to closer, or to end of input
+// for a top-level array (closer 0) opened at open.
+func (m *minifier) elements(closer byte, open nodePos) error {
+	p := m.p
+	p.skipWsAndComments()
+	for i := 0; ; i++ {
+		if done, err := m.atClose(closer, ']', "array", open); done || err != nil {
+			return err
+		}
+		if closer == 0 {
+			if c, _ := p.current(); c == '=' {
+				return p.syntaxErr("cannot mix key=value pairs and bare values at top level")
+			}
+		}
+		if i > 0 {
+			m.sb.WriteByte(',')
+		}
+		p.pushIndex(i)
+		err := m.value()
+		p.pop()
+		if err != nil {
+			return err
+		}
+		if err := m.separator(closer); err != nil {
+			return err
+		}
+	}
+}
+
+// atClose reports whether the container ends here, consuming its closer.
+// want is the closer of this kind of container, which a top-level one
+// (closer 0) must not meet; a top-level one ends only at end of input, so a
+// NUL byte there is not mistaken for its closer.
+func (m *minifier) atClose(closer, want byte, construct string, open nodePos) (bool, error) {
+	p := m.p
+	c, ok := p.current()
+	switch {
+	case !ok && closer == 0:
+		return true, nil
+	case !ok:
+		return false, p.unterminatedErr(construct, open)
+	case closer != 0 && c == closer:
+		p.advance()
+		return true, nil
+	case closer != 0 && (c == '}' || c == ']'):
+		return false, p.mismatchErr(want, construct, open)
+	}
+	return false, nil
+}
+
+// separator consumes what follows an item, which must be a comma, a
+// newline or the end of the container.
+func (m *minifier) separator(closer byte) error {
+	p := m.p
+	sawNewline, sawComma := p.skipInterItemSeparator()
+	c, ok := p.current()
+	if !ok || sawNewline || sawComma || (closer != 0 && (c == '}' || c == ']')) {
+		return nil
+	}
+	return p.syntaxErr("items on the same line must be separated by a comma")
+}
+
+// value copies one value: containers recursively, scalars through the
+// parser's scanners and the minifying serializer.
+func (m *minifier) value() error {
+	p := m.p
+	p.skipWsAndComments()
+	c, _ := p.current()
+	if c != '{' && c != '[' {
+		v, err := p.parseValue()
+		if err != nil {
+			return err
+		}
+		serializeScalar(v, minifyOptions, &m.sb)
+		return nil
+	}
+	if err := p.enter(); err != nil {
+		return err
+	}
+	defer p.leave()
+	open := p.here()
+	p.advance()
+	m.sb.WriteByte(c)
+	var err error
+	if c == '{' {
+		err = m.members('}', open)
+		m.sb.WriteByte('}')
+	} else {
+		err = m.elements(']', open)
+		m.sb.WriteByte(']')
+	}
+	return err
+}
+
+// minifyString returns the shortest spelling of s as a string value.
+func minifyString(s string, escapeHTML bool) string {
+	best := shortest(quoted(s, '"', escapeHTML), quoted(s, '\'', escapeHTML))
+	if escapeHTML && strings.ContainsAny(s, "<>&") {
+		return best // raw strings cannot escape
+	}
+	// A raw string needs enough '#'s that `"` plus that many '#'s never
+	// occurs in s.
+	hashes := 0
+	if strings.Contains(s, `"`) {
+		hashes = 1
+		for strings.Contains(s, `"`+strings.Repeat("#", hashes)) {
+			hashes++
+		}
+	}
+	if 3+2*hashes+len(s) >= len(best) {
+		return best
+	}
+	fence := strings.Repeat("#", hashes)
+	return "r" + fence + `"` + s + `"` + fence
+}
+
+// minifyFloat returns the shorter of the usual float form and exponent form,
+// with the exponent's '+' and leading zeros dropped (1e+07 → 1e7).
+func minifyFloat(f float64, bitSize int) string {
+	var sb strings.Builder
+	serializeFloat(f, bitSize, SerializeOptions{}, &sb)
+	return shortest(sb.String(), exponentForm(f, bitSize))
+}
+
+func quoted(s string, quote byte, escapeHTML bool) string {
+	var sb strings.Builder
+	serializeQuoted(s, quote, escapeHTML, &sb)
+	return sb.String()
+}
+
+// shortest returns the shortest of its arguments, preferring earlier ones
+// on a tie.
+func shortest(candidates ...string) string {
+	best := candidates[0]
+	for _, c := range candidates[1:] {
+		if len(c) < len(best) {
+			best = c
+		}
+	}
+	return best
+}
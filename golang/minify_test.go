@@ -0,0 +1,121 @@
+package jhon
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMinifyShortestForms(t *testing.T) {
+	cases := []struct {
+		v    Value
+		want string
+	}{
+		{Object{"p": `C:\dir\file`}, `p=r"C:\dir\file"`},
+		{Object{"q": `say "hi"`}, `q='say "hi"'`},
+		{Object{"q": `it's "x" \\\\`}, `q=r#"it's "x" \\\\"#`},
+		{Object{"s": "plain"}, `s="plain"`},
+		{Object{"f": 1e21}, `f=1e21`},
+		{Object{"f": 1.5e-7}, `f=1.5e-7`},
+		{Object{"f": 0.25}, `f=0.25`},
+		{Object{"i": int64(1000000)}, `i=1000000`},
+		{Object{`a "b"`: true}, `'a "b"'=true`},
+	}
+	for _, c := range cases {
+		got := Minify(c.v)
+		if got != c.want {
+			t.Errorf("got %s, want %s", got, c.want)
+		}
+		back, err := Parse(got)
+		if err != nil || !reflect.DeepEqual(back, c.v) {
+			t.Errorf("%s: round-trip got %#v, %v", got, back, err)
+		}
+	}
+}
+
+func TestMinifyIsNoLargerThanSerialize(t *testing.T) {
+	v := MustParse(`
+paths = ["C:\\Windows\\System32", "/usr/bin"]
+quote = "He said \"no\""
+big = 1e300
+tiny = 2.5e-10
+nested = { list = [1, 2.5, "three"], flag = false, empty = {} }
+`)
+	min, ser := Minify(v), Serialize(v)
+	if len(min) >= len(ser) {
+		t.Fatalf("Minify is %d bytes, Serialize %d:\n%s\n%s", len(min), len(ser), min, ser)
+	}
+	back, err := Parse(min)
+	if err != nil || !reflect.DeepEqual(back, v) {
+		t.Fatalf("round-trip of %s: %#v, %v", min, back, err)
+	}
+}
+
+func TestMinifyBytesReadsBackToParse(t *testing.T) {
+	docs := []string{
+		mediumJHON,
+		`
+// service config
+name = "api"   // display name
+paths = [r"C:\dir", 'say "hi"', "/usr/bin",]  /* trailing comma */
+server = {
+  host
+    = "localhost"
+  port = 8_080
+  ratios = [1.50, 2e3, 0x1F, -0]
+  nested = [[], {}, [{ a = null }]]
+}
+"quoted key" = true
+`,
+		"1\n2, \"three\"\n{a = 1}\n[4]",
+		"{a=1}\n{b=2}",
+		"   // only a comment\n",
+		"",
+		"\x00=\"\"",
+	}
+	for _, doc := range docs {
+		want, err := Parse(doc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := MinifyBytes([]byte(doc))
+		if err != nil {
+			t.Errorf("%q: %v", doc, err)
+			continue
+		}
+		got, err := Parse(string(out))
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("%q minified to %s: got %#v, %v; want %#v", doc, out, got, err, want)
+		}
+		if len(out) != len(Minify(want)) {
+			t.Errorf("%q: MinifyBytes gave %d bytes (%s), Minify %d (%s)", doc, len(out), out, len(Minify(want)), Minify(want))
+		}
+	}
+}
+
+func TestMinifyBytesKeepsSourceOrder(t *testing.T) {
+	out, err := MinifyBytes([]byte("b = 1 // one\nA = \"x\"\na = { z = 2.50, y = \"it's\" }\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `b=1,A="x",a={z=2.5,y="it's"}`; string(out) != want {
+		t.Fatalf("got %s want %s", out, want)
+	}
+}
+
+func TestMinifyBytesRejectsInvalidInput(t *testing.T) {
+	for _, doc := range []string{
+		`a=1 b=2`, `a=1, a=2`, `a={x=1]`, `a=[1, 2`, `a=`, `1, b=2`,
+		`a="unterminated`, `a=1 /* open`, `a=1__0`, `{a=1} {b=2}`,
+		"\x00.=", "1\x00",
+	} {
+		_, perr := Parse(doc)
+		if perr == nil {
+			t.Fatalf("%q: Parse accepted it", doc)
+		}
+		if out, err := MinifyBytes([]byte(doc)); err == nil {
+			t.Errorf("%q: got %s, want an error like %v", doc, out, perr)
+		} else if _, ok := err.(*ParseError); !ok {
+			t.Errorf("%q: got %T, want *ParseError", doc, err)
+		}
+	}
+}
@@ -0,0 +1,383 @@
+package jhon
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ============================================================================
+// Object and Array helpers
+// ============================================================================
+
+// Flatten turns a nested Object into a single-level map whose keys join the
+// path segments with sep: {server={host="x"}} becomes {"server.host": "x"}
+// for sep ".". Array elements contribute their index (`items.0`). Empty
+// nested objects and arrays are kept as leaf values so they survive
+// Unflatten.
+func Flatten(o Object, sep string) map[string]Value {
+	out := make(map[string]Value)
+	flattenInto(out, "", o, sep)
+	return out
+}
+
+func flattenInto(out map[string]Value, prefix string, v Value, sep string) {
+	join := func(seg string) string {
+		if prefix == "" {
+			return seg
+		}
+		return prefix + sep + seg
+	}
+	switch val := v.(type) {
+	case Object:
+		if len(val) == 0 && prefix != "" {
+			out[prefix] = Object{}
+			return
+		}
+		for k, inner := range val {
+			flattenInto(out, join(k), inner, sep)
+		}
+	case Array:
+		if len(val) == 0 {
+			out[prefix] = Array{}
+			return
+		}
+		for i, inner := range val {
+			flattenInto(out, join(strconv.Itoa(i)), inner, sep)
+		}
+	default:
+		out[prefix] = v
+	}
+}
+
+// Unflatten is the inverse of Flatten. Keys are split on sep and applied in
+// sorted order; when a key needs an object where an earlier key stored a
+// scalar, the object replaces it. A level whose keys are exactly 0..n-1
+// becomes an Array.
+func Unflatten(m map[string]Value, sep string) Object {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	root := Object{}
+	for _, k := range keys {
+		segs := strings.Split(k, sep)
+		cur := root
+		for _, seg := range segs[:len(segs)-1] {
+			next, ok := cur[seg].(Object)
+			if !ok {
+				next = Object{}
+				cur[seg] = next
+			}
+			cur = next
+		}
+		last := segs[len(segs)-1]
+		if _, isObj := cur[last].(Object); isObj {
+			if _, leafIsObj := m[k].(Object); !leafIsObj {
+				continue // a deeper key already claimed this path
+			}
+		}
+		cur[last] = m[k]
+	}
+	return indexedObjectsToArrays(root).(Object)
+}
+
+// indexedObjectsToArrays converts, bottom-up, every nested Object whose keys
+// are exactly "0".."n-1" into an Array. The root is always left an Object.
+func indexedObjectsToArrays(v Value) Value {
+	obj, ok := v.(Object)
+	if !ok {
+		return v
+	}
+	for k, inner := range obj {
+		if innerObj, ok := inner.(Object); ok && len(innerObj) > 0 {
+			converted := indexedObjectsToArrays(innerObj)
+			if arr, ok := asIndexedArray(converted.(Object)); ok {
+				obj[k] = arr
+			} else {
+				obj[k] = converted
+			}
+		}
+	}
+	return obj
+}
+
+func asIndexedArray(obj Object) (Array, bool) {
+	arr := make(Array, len(obj))
+	for k, v := range obj {
+		i, err := strconv.Atoi(k)
+		if err != nil || i < 0 || i >= len(obj) || strconv.Itoa(i) != k {
+			return nil, false
+		}
+		arr[i] = v
+	}
+	return arr, true
+}
+
+// ToInterface converts v for libraries that do not know the Object and Array
+// types: every Object becomes a map[string]interface{} and every Array a
+// []interface{}, recursively, as encoding/json would produce. Scalars are
+// returned as they are. v is not modified.
+func ToInterface(v Value) interface{} {
+	switch val := v.(type) {
+	case Object:
+		m := make(map[string]interface{}, len(val))
+		for k, el := range val {
+			m[k] = ToInterface(el)
+		}
+		return m
+	case Array:
+		s := make([]interface{}, len(val))
+		for i, el := range val {
+			s[i] = ToInterface(el)
+		}
+		return s
+	}
+	return v
+}
+
+// FromInterface is the inverse of ToInterface: it turns the
+// map[string]interface{} and []interface{} containers found in v, at any
+// depth, into Object and Array, so the result can be used like a parsed
+// tree. Containers are copied where something beneath them changed; v is
+// not modified.
+func FromInterface(v interface{}) Value {
+	out, _ := normalizeValue(v)
+	return out
+}
+
+// Merge returns base with override layered on top: keys only in one side are
+// copied, and where both sides hold an Object for a key the two are merged
+// recursively. Any other value in override — including null and arrays —
+// replaces the base value. Neither argument is modified, though unmerged
+// values are shared with the result.
+func Merge(base, override Object) Object {
+	out := make(Object, len(base)+len(override))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range override {
+		if src, ok := v.(Object); ok {
+			if dst, ok := out[k].(Object); ok {
+				out[k] = Merge(dst, src)
+				continue
+			}
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// Equal reports whether a and b hold the same JHON value. Objects are equal
+// when they have the same keys with equal values, arrays when their elements
+// are equal in order. Numbers compare by value whatever their Go type, so
+// int64(1), float32(1), uint16(1) and Number("1") are all equal; NaN
+// equals nothing.
+// Other values, []byte included, compare as reflect.DeepEqual does.
+func Equal(a, b Value) bool {
+	switch x := a.(type) {
+	case Object:
+		y, ok := b.(Object)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for k, v := range x {
+			w, ok := y[k]
+			if !ok || !Equal(v, w) {
+				return false
+			}
+		}
+		return true
+	case Array:
+		y, ok := b.(Array)
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !Equal(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	if describeValue(a) == "number" && describeValue(b) == "number" {
+		if isNaN(a) || isNaN(b) {
+			return false
+		}
+		return numberAsBigFloat(a).Cmp(numberAsBigFloat(b)) == 0
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func isNaN(v Value) bool {
+	rv := reflect.ValueOf(v)
+	k := rv.Kind()
+	return (k == reflect.Float32 || k == reflect.Float64) && math.IsNaN(rv.Float())
+}
+
+// Contains reports whether a has an element Equal to v.
+func (a Array) Contains(v Value) bool {
+	for _, el := range a {
+		if Equal(el, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// Map returns a new Array holding fn applied to each element of a.
+func (a Array) Map(fn func(Value) Value) Array {
+	out := make(Array, len(a))
+	for i, el := range a {
+		out[i] = fn(el)
+	}
+	return out
+}
+
+// Filter returns a new Array holding the elements of a for which fn
+// reports true, in order.
+func (a Array) Filter(fn func(Value) bool) Array {
+	out := Array{}
+	for _, el := range a {
+		if fn(el) {
+			out = append(out, el)
+		}
+	}
+	return out
+}
+
+// Has reports whether key is present in o, even with a null value. o[key]
+// alone cannot tell `key = null` from a missing key, since both read as nil.
+func (o Object) Has(key string) bool {
+	_, ok := o[key]
+	return ok
+}
+
+// GetCI looks key up ignoring case, as strings.EqualFold compares, so
+// "Port" finds a key stored as "port". An exact match wins; otherwise, when
+// several keys differ from key only in case, the bytewise smallest is used
+// so the result does not depend on map order. It scans o without
+// allocating.
+func (o Object) GetCI(key string) (Value, bool) {
+	if v, ok := o[key]; ok {
+		return v, true
+	}
+	var found string
+	matched := false
+	for k := range o {
+		if strings.EqualFold(k, key) && (!matched || k < found) {
+			found, matched = k, true
+		}
+	}
+	if !matched {
+		return nil, false
+	}
+	return o[found], true
+}
+
+// GetByIntKey returns the value stored under the decimal form of key, for
+// objects keyed by numbers such as HTTP status codes:
+//
+//	messages = { 200 = "OK", 404 = "Not Found" }
+//
+// Keys are always strings — `404` is the key "404", not an index — and
+// only the plain decimal spelling matches, so a key written "0404" or
+// "4_04" is not found for 404.
+func (o Object) GetByIntKey(key int64) (Value, bool) {
+	v, ok := o[strconv.FormatInt(key, 10)]
+	return v, ok
+}
+
+// GetOr returns o[key], or def when the key is absent or null:
+//
+//	port := obj.GetOr("port", int64(8080))
+func (o Object) GetOr(key string, def Value) Value {
+	if v, ok := o[key]; ok && v != nil {
+		return v
+	}
+	return def
+}
+
+// GetStringOr returns o[key] if it is a string, and def otherwise.
+func (o Object) GetStringOr(key, def string) string {
+	if s, ok := o[key].(string); ok {
+		return s
+	}
+	return def
+}
+
+// GetIntOr returns o[key] as an int64 if it is an integral number that fits,
+// and def otherwise.
+func (o Object) GetIntOr(key string, def int64) int64 {
+	if i, ok := toInt64(o[key]); ok {
+		return i
+	}
+	return def
+}
+
+// GetFloatOr returns o[key] as a float64 if it is a number, and def
+// otherwise.
+func (o Object) GetFloatOr(key string, def float64) float64 {
+	if f, ok := toFloat64(o[key]); ok {
+		return f
+	}
+	return def
+}
+
+// GetBoolOr returns o[key] if it is a bool, and def otherwise.
+func (o Object) GetBoolOr(key string, def bool) bool {
+	if b, ok := o[key].(bool); ok {
+		return b
+	}
+	return def
+}
+
+// GetStringLen returns the length of the string o[key] in characters
+// (Unicode code points), not bytes, for length limits on config strings:
+// "héllo" is 5 where len gives 6. It returns a *ValidationError when the
+// key is absent or its value is not a string.
+//
+// Code points are not always what a reader sees as one character: a
+// letter followed by a combining accent, a flag or an emoji sequence with
+// joiners counts as several. Counting such grapheme clusters needs the
+// Unicode segmentation rules, which this package does not implement.
+func (o Object) GetStringLen(key string) (int, error) {
+	v, ok := o[key]
+	if !ok {
+		return 0, &ValidationError{Path: key, Problem: "key is missing"}
+	}
+	s, ok := v.(string)
+	if !ok {
+		return 0, &ValidationError{Path: key, Problem: "expected string, got " + describeValue(v)}
+	}
+	return utf8.RuneCountInString(s), nil
+}
+
+// GetSubdocument parses the string o[key] as a JHON document of its own,
+// for configs that embed a sub-config as an opaque string:
+//
+//	plugin = { name = "cache", config = "size=128, ttl=60" }
+//
+// It returns a *ValidationError when the key is absent or its value is not
+// a string. A parse failure is returned wrapped with the key; errors.As
+// still finds the *ParseError, whose position is within the string value.
+func (o Object) GetSubdocument(key string) (Value, error) {
+	v, ok := o[key]
+	if !ok {
+		return nil, &ValidationError{Path: key, Problem: "key is missing"}
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, &ValidationError{Path: key, Problem: "expected string, got " + describeValue(v)}
+	}
+	doc, err := Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("jhon: %s: embedded document: %w", key, err)
+	}
+	return doc, nil
+}
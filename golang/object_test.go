@@ -0,0 +1,313 @@
+package jhon
+
+import (
+	"errors"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFlattenNested(t *testing.T) {
+	obj := Object{
+		"server": Object{"host": "x", "port": int64(80)},
+		"items":  Array{"a", Object{"b": true}},
+		"empty":  Object{},
+		"none":   Array{},
+		"debug":  false,
+	}
+	got := Flatten(obj, ".")
+	want := map[string]Value{
+		"server.host": "x",
+		"server.port": int64(80),
+		"items.0":     "a",
+		"items.1.b":   true,
+		"empty":       Object{},
+		"none":        Array{},
+		"debug":       false,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v want %#v", got, want)
+	}
+}
+
+func TestFlattenCustomSeparator(t *testing.T) {
+	got := Flatten(Object{"db": Object{"host": "x"}}, "__")
+	if !reflect.DeepEqual(got, map[string]Value{"db__host": "x"}) {
+		t.Fatalf("got %#v", got)
+	}
+}
+
+func TestUnflattenInvertsFlatten(t *testing.T) {
+	obj := Object{
+		"server": Object{"host": "x", "ports": Array{int64(80), int64(443)}},
+		"items":  Array{Object{"name": "a"}, Object{"name": "b"}},
+		"empty":  Object{},
+		"debug":  false,
+	}
+	got := Unflatten(Flatten(obj, "."), ".")
+	if !reflect.DeepEqual(got, obj) {
+		t.Fatalf("got %#v want %#v", got, obj)
+	}
+}
+
+func TestUnflattenSparseIndicesStayObject(t *testing.T) {
+	got := Unflatten(map[string]Value{"codes.200": "ok", "codes.404": "missing"}, ".")
+	want := Object{"codes": Object{"200": "ok", "404": "missing"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v want %#v", got, want)
+	}
+}
+
+func TestUnflattenPrefixReplacesScalar(t *testing.T) {
+	got := Unflatten(map[string]Value{"a": int64(1), "a.b": int64(2)}, ".")
+	want := Object{"a": Object{"b": int64(2)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v want %#v", got, want)
+	}
+}
+
+func TestObjectGetOr(t *testing.T) {
+	obj := MustParse(`name="app", port=8080, ratio=0.5, debug=true, missing=null, big=18446744073709551615`).(Object)
+	if got := obj.GetOr("port", int64(1)); got != int64(8080) {
+		t.Errorf("GetOr port: got %v", got)
+	}
+	if got := obj.GetOr("missing", "d"); got != "d" {
+		t.Errorf("GetOr null: got %v", got)
+	}
+	if got := obj.GetOr("absent", 3.0); got != 3.0 {
+		t.Errorf("GetOr absent: got %v", got)
+	}
+	if got := obj.GetStringOr("name", "x"); got != "app" {
+		t.Errorf("GetStringOr: got %q", got)
+	}
+	if got := obj.GetStringOr("port", "x"); got != "x" {
+		t.Errorf("GetStringOr wrong type: got %q", got)
+	}
+	if got := obj.GetIntOr("port", 1); got != 8080 {
+		t.Errorf("GetIntOr: got %d", got)
+	}
+	if got := obj.GetIntOr("ratio", 1); got != 1 {
+		t.Errorf("GetIntOr fractional: got %d", got)
+	}
+	if got := obj.GetIntOr("big", 1); got != 1 {
+		t.Errorf("GetIntOr overflow: got %d", got)
+	}
+	if got := obj.GetFloatOr("ratio", 1); got != 0.5 {
+		t.Errorf("GetFloatOr: got %v", got)
+	}
+	if got := obj.GetFloatOr("port", 1); got != 8080 {
+		t.Errorf("GetFloatOr int: got %v", got)
+	}
+	if got := obj.GetBoolOr("debug", false); !got {
+		t.Errorf("GetBoolOr: got %v", got)
+	}
+	if got := obj.GetBoolOr("absent", true); !got {
+		t.Errorf("GetBoolOr default: got %v", got)
+	}
+}
+
+func TestObjectHas(t *testing.T) {
+	obj := MustParse(`present = 1, empty = null`).(Object)
+	if !obj.Has("present") || !obj.Has("empty") {
+		t.Fatalf("expected both keys present: %#v", obj)
+	}
+	if obj.Has("missing") {
+		t.Fatal("missing key reported present")
+	}
+	if obj["empty"] != nil || obj["missing"] != nil {
+		t.Fatal("both should read as nil without Has")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := Object{"host": "x", "tls": Object{"on": false, "ca": "a.pem"}, "tags": Array{"a"}}
+	override := Object{"tls": Object{"on": true}, "tags": Array{"b"}, "port": int64(80)}
+	got := Merge(base, override)
+	want := Object{
+		"host": "x",
+		"port": int64(80),
+		"tls":  Object{"on": true, "ca": "a.pem"},
+		"tags": Array{"b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v", got)
+	}
+	if base["tls"].(Object)["on"] != false || len(override) != 3 {
+		t.Fatal("Merge modified its arguments")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	doc := MustParse(`a = { n = 1, xs = [1.5, "s", null, true] }`)
+	equal := []struct{ a, b Value }{
+		{doc, MustParse(`a = { xs = [1.5, "s", null, true], n = 1.0 }`)},
+		{int64(1), float64(1)},
+		{Number("0x10"), uint64(16)},
+		{float32(1), float64(1)},
+		{int32(-7), int8(-7)},
+		{uint16(65535), Number("65535")},
+		{float32(0.5), big.NewFloat(0.5)},
+		{[]byte("hi"), []byte("hi")},
+		{nil, nil},
+	}
+	for _, c := range equal {
+		if !Equal(c.a, c.b) || !Equal(c.b, c.a) {
+			t.Errorf("expected %#v == %#v", c.a, c.b)
+		}
+	}
+	unequal := []struct{ a, b Value }{
+		{doc, MustParse(`a = { n = 1, xs = [1.5, "s", null] }`)},
+		{Object{"a": nil}, Object{"b": nil}},
+		{Array{int64(1), int64(2)}, Array{int64(2), int64(1)}},
+		{int64(1), "1"},
+		{nil, Object{}},
+		{Array{}, Object{}},
+		{float64(nanValue()), float64(nanValue())},
+		{float32(nanValue()), float32(nanValue())},
+		{float32(0.1), float64(0.1)},
+		{uint8(1), "1"},
+	}
+	for _, c := range unequal {
+		if Equal(c.a, c.b) || Equal(c.b, c.a) {
+			t.Errorf("expected %#v != %#v", c.a, c.b)
+		}
+	}
+}
+
+func nanValue() float64 {
+	zero := 0.0
+	return zero / zero
+}
+
+func TestArrayHelpers(t *testing.T) {
+	features := MustParse(`features = ["auth", "gzip", 3]`).(Object)["features"].(Array)
+	if !features.Contains("gzip") || !features.Contains(3.0) || features.Contains("tls") {
+		t.Fatal("Contains")
+	}
+	upper := features.Map(func(v Value) Value {
+		if s, ok := v.(string); ok {
+			return strings.ToUpper(s)
+		}
+		return v
+	})
+	if !reflect.DeepEqual(upper, Array{"AUTH", "GZIP", int64(3)}) || features[0] != "auth" {
+		t.Fatalf("Map: got %#v", upper)
+	}
+	strs := features.Filter(func(v Value) bool { _, ok := v.(string); return ok })
+	if !reflect.DeepEqual(strs, Array{"auth", "gzip"}) {
+		t.Fatalf("Filter: got %#v", strs)
+	}
+	if none := features.Filter(func(Value) bool { return false }); none == nil || len(none) != 0 {
+		t.Fatalf("Filter with no matches: got %#v", none)
+	}
+}
+
+func TestToAndFromInterface(t *testing.T) {
+	doc := MustParse(`name = "x", tags = ["a", { k = 1 }], nested = { on = true, none = null }`)
+	plain := ToInterface(doc)
+	want := map[string]interface{}{
+		"name":   "x",
+		"tags":   []interface{}{"a", map[string]interface{}{"k": int64(1)}},
+		"nested": map[string]interface{}{"on": true, "none": nil},
+	}
+	if !reflect.DeepEqual(plain, want) {
+		t.Fatalf("ToInterface: got %#v", plain)
+	}
+	if back := FromInterface(plain); !reflect.DeepEqual(back, doc) {
+		t.Fatalf("FromInterface: got %#v", back)
+	}
+	if got := ToInterface("s"); got != "s" {
+		t.Fatalf("scalar: got %#v", got)
+	}
+	mixed := Object{"m": map[string]interface{}{"a": []interface{}{int64(1)}}}
+	if got := FromInterface(mixed); !reflect.DeepEqual(got, Object{"m": Object{"a": Array{int64(1)}}}) {
+		t.Fatalf("mixed: got %#v", got)
+	}
+	if _, ok := mixed["m"].(map[string]interface{}); !ok {
+		t.Fatal("FromInterface modified its argument")
+	}
+}
+
+func TestObjectGetCI(t *testing.T) {
+	obj := Object{"port": int64(80), "Content-Type": "json", "HOST": "a", "Host": "b", "host": "c", "nil": nil}
+	cases := []struct {
+		key  string
+		want Value
+		ok   bool
+	}{
+		{"Port", int64(80), true},
+		{"content-type", "json", true},
+		{"host", "c", true}, // exact match wins
+		{"hOST", "a", true}, // else the smallest of HOST, Host, host
+		{"NIL", nil, true},  // present with null
+		{"missing", nil, false},
+	}
+	for _, c := range cases {
+		if got, ok := obj.GetCI(c.key); got != c.want || ok != c.ok {
+			t.Errorf("GetCI(%q) = %v, %v; want %v, %v", c.key, got, ok, c.want, c.ok)
+		}
+	}
+	if n := testing.AllocsPerRun(100, func() { obj.GetCI("PORT") }); n != 0 {
+		t.Errorf("GetCI allocated %v times", n)
+	}
+}
+
+func TestObjectGetStringLen(t *testing.T) {
+	obj := MustParse(`name = "héllo 世界", port = 8080`).(Object)
+	n, err := obj.GetStringLen("name")
+	if err != nil || n != 8 {
+		t.Fatalf("got %d, %v; want 8 (len is %d)", n, err, len(obj["name"].(string)))
+	}
+	if _, err := obj.GetStringLen("port"); err == nil || !strings.Contains(err.Error(), "expected string, got number") {
+		t.Errorf("wrong type: %v", err)
+	}
+	if _, err := obj.GetStringLen("missing"); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("missing key: %v", err)
+	}
+}
+
+func TestObjectGetSubdocument(t *testing.T) {
+	obj := MustParse(`inner = "a=1,b=2", bad = "a=", port = 8080`).(Object)
+	v, err := obj.GetSubdocument("inner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Object{"a": int64(1), "b": int64(2)}); !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v want %#v", v, want)
+	}
+	_, err = obj.GetSubdocument("bad")
+	var pe *ParseError
+	if !errors.As(err, &pe) || !strings.Contains(err.Error(), "bad: embedded document") {
+		t.Errorf("unparsable string: %v", err)
+	}
+	if _, err := obj.GetSubdocument("port"); err == nil || !strings.Contains(err.Error(), "expected string") {
+		t.Errorf("wrong type: %v", err)
+	}
+	if _, err := obj.GetSubdocument("missing"); err == nil {
+		t.Error("missing key: expected error")
+	}
+}
+
+func TestObjectGetByIntKey(t *testing.T) {
+	input := "messages = { 200 = \"OK\", 404 = \"Not Found\", 500 = \"Server Error\", -1 = \"unknown\" }"
+	messages := MustParse(input).(Object)["messages"].(Object)
+	for code, want := range map[int64]string{200: "OK", 404: "Not Found", 500: "Server Error", -1: "unknown"} {
+		if got, ok := messages.GetByIntKey(code); !ok || got != want {
+			t.Errorf("GetByIntKey(%d) = %v, %v", code, got, ok)
+		}
+	}
+	if _, ok := messages.GetByIntKey(201); ok {
+		t.Error("GetByIntKey(201) found a value")
+	}
+	if _, ok := messages["404"]; !ok {
+		t.Fatalf("numeric keys should be strings: %#v", messages)
+	}
+	out := SerializeWithOptions(MustParse(input), SerializeOptions{SortKeys: true})
+	if want := `messages={-1="unknown",200="OK",404="Not Found",500="Server Error"}`; out != want {
+		t.Fatalf("got %s want %s", out, want)
+	}
+	if !reflect.DeepEqual(MustParse(out), MustParse(input)) {
+		t.Fatal("round trip")
+	}
+}
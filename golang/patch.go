@@ -0,0 +1,165 @@
+package jhon
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ============================================================================
+// Patch — rewrite only the values that changed
+// ============================================================================
+
+// Patch returns source with the values at the changed paths replaced by
+// their values in v, and every other byte left as it was, so a config
+// editor can load a file, modify a few settings and save it without losing
+// the comments and layout of the rest:
+//
+//	v, _ := jhon.Parse(text)
+//	v.(jhon.Object)["server"].(jhon.Object)["port"] = int64(9090)
+//	text, err = jhon.Patch(text, v, []string{"server.port"}, jhon.SerializeOptions{Indent: "  "})
+//
+// v is the edited tree parsed from source, and paths are written as in
+// error messages and Positions (`server.ports[1]`). Only the value text is
+// replaced — never its key, separator or trailing comment. A path that was
+// added to or removed from v rewrites the closest enclosing container that
+// exists in both the source and v, which loses the comments inside that
+// container; a change at the top level rewrites the whole document.
+//
+// New values are written with opts: compact when it selects no indent,
+// otherwise pretty, with continuation lines indented to match the line
+// the value starts on. It is an error for a path to name no value in
+// either the source or v.
+func Patch(source string, v Value, changed []string, opts SerializeOptions) (string, error) {
+	opts = resolveIndent(opts)
+	p := newParser([]byte(source))
+	p.valuePos = map[string]nodePos{}
+	p.valueEnd = map[string]nodePos{}
+	old, err := p.parseDocument()
+	if err != nil {
+		return "", err
+	}
+	v, _ = normalizeValue(v)
+	before, after := newValueIndex(old), newValueIndex(v)
+
+	targets := map[string]bool{}
+	for _, path := range changed {
+		target, ok := path, false
+		for {
+			_, inOld := before.values[target]
+			_, inNew := after.values[target]
+			if inOld && inNew {
+				ok = true
+				break
+			}
+			parent, known := before.parents[target]
+			if !known {
+				parent, known = after.parents[target]
+			}
+			if !known {
+				break
+			}
+			target = parent
+		}
+		if !ok {
+			return "", fmt.Errorf("jhon: Patch: no value at %s", path)
+		}
+		targets[target] = true
+	}
+	if targets[""] {
+		out := SerializeWithOptions(v, opts)
+		if strings.HasSuffix(source, "\n") {
+			out += "\n"
+		}
+		return out, nil
+	}
+
+	// A target inside another target is rewritten along with it.
+	var edits []string
+	for path := range targets {
+		covered := false
+		for anc, ok := after.parents[path]; ok && anc != ""; anc, ok = after.parents[anc] {
+			if targets[anc] {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			edits = append(edits, path)
+		}
+	}
+	sort.Slice(edits, func(i, j int) bool {
+		return p.valuePos[edits[i]].offset > p.valuePos[edits[j]].offset
+	})
+	out := source
+	for _, path := range edits {
+		start, end := p.valuePos[path].offset, p.valueEnd[path].offset
+		out = out[:start] + patchText(after.values[path], opts, lineIndent(source, start)) + out[end:]
+	}
+	return out, nil
+}
+
+// patchText renders v for Patch. In pretty mode every line after the first
+// is prefixed with lead, the indentation of the line v starts on.
+func patchText(v Value, opts SerializeOptions, lead string) string {
+	if opts.SortArrays {
+		v = sortArrays(v)
+	}
+	var sb strings.Builder
+	if opts.Indent != "" {
+		renderPrettyInline(v, opts, 0, &sb)
+		return strings.ReplaceAll(sb.String(), "\n", "\n"+lead)
+	}
+	if obj, ok := v.(Object); ok {
+		sb.WriteByte('{')
+		serializeObjectCompact(obj, opts, &sb)
+		sb.WriteByte('}')
+		return sb.String()
+	}
+	serializeCompact(v, opts, &sb)
+	return sb.String()
+}
+
+// lineIndent returns the spaces and tabs that begin the line holding the
+// byte at offset.
+func lineIndent(source string, offset int) string {
+	start := strings.LastIndexByte(source[:offset], '\n') + 1
+	end := start
+	for end < offset && (source[end] == ' ' || source[end] == '\t') {
+		end++
+	}
+	return source[start:end]
+}
+
+// valueIndex maps the path of every value in a tree, as formatPath writes
+// it, to the value and to the path of its parent.
+type valueIndex struct {
+	values  map[string]Value
+	parents map[string]string
+}
+
+func newValueIndex(v Value) valueIndex {
+	idx := valueIndex{values: map[string]Value{}, parents: map[string]string{}}
+	idx.add(v, nil)
+	return idx
+}
+
+func (idx valueIndex) add(v Value, path []pathSeg) {
+	self := formatPath(path)
+	idx.values[self] = v
+	child := func(seg pathSeg, el Value) {
+		path := append(path, seg)
+		idx.parents[formatPath(path)] = self
+		idx.add(el, path)
+	}
+	switch val := v.(type) {
+	case Object:
+		for k, el := range val {
+			child(pathSeg{key: k, index: -1}, el)
+		}
+	case Array:
+		for i, el := range val {
+			child(pathSeg{index: i}, el)
+		}
+	}
+}
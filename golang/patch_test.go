@@ -0,0 +1,105 @@
+package jhon
+
+import (
+	"strings"
+	"testing"
+)
+
+const patchSource = `// service config
+name = "api"   // display name
+server = {
+  host = "localhost"
+  port = 8080 // dev port
+
+  // TLS is off locally
+  tls = { enabled = false }
+}
+features = [
+  "a"
+  "b"
+]
+`
+
+func TestPatchRewritesOnlyChangedValues(t *testing.T) {
+	v := MustParse(patchSource)
+	server := v.(Object)["server"].(Object)
+	server["port"] = int64(9090)
+	server["tls"].(Object)["enabled"] = true
+	v.(Object)["features"].(Array)[1] = "c d"
+
+	got, err := Patch(patchSource, v, []string{"server.port", "server.tls.enabled", "features[1]"}, SerializeOptions{Indent: "  "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.NewReplacer(
+		"port = 8080", "port = 9090",
+		"enabled = false", "enabled = true",
+		`"b"`, `"c d"`,
+	).Replace(patchSource)
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestPatchKeepsUntouchedLines(t *testing.T) {
+	v := MustParse(patchSource)
+	v.(Object)["server"].(Object)["tls"] = Object{"enabled": true, "cert": "/etc/cert.pem"}
+	got, err := Patch(patchSource, v, []string{"server.tls"}, SerializeOptions{Indent: "  ", SortKeys: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotLines, srcLines := strings.Split(got, "\n"), strings.Split(patchSource, "\n")
+	for i := 0; i < 7; i++ {
+		if gotLines[i] != srcLines[i] {
+			t.Errorf("line %d changed: %q -> %q", i+1, srcLines[i], gotLines[i])
+		}
+	}
+	if want := "  tls = {\n    cert = \"/etc/cert.pem\"\n    enabled = true\n  }\n}\nfeatures"; !strings.Contains(got, want) {
+		t.Fatalf("tls not re-indented:\n%s", got)
+	}
+	for i := 1; i <= 5; i++ {
+		if gotLines[len(gotLines)-i] != srcLines[len(srcLines)-i] {
+			t.Errorf("line %d from the end changed: %q", i, gotLines[len(gotLines)-i])
+		}
+	}
+	if !Equal(MustParse(got), v) {
+		t.Fatal("patched text does not parse back to v")
+	}
+}
+
+func TestPatchAddedAndRemovedKeysRewriteTheContainer(t *testing.T) {
+	v := MustParse(patchSource)
+	server := v.(Object)["server"].(Object)
+	server["timeout"] = 2.5
+	delete(server, "host")
+	got, err := Patch(patchSource, v, []string{"server.timeout", "server.host"}, SerializeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "// service config\nname = \"api\"   // display name\nserver = {") ||
+		!strings.HasSuffix(got, "}\nfeatures = [\n  \"a\"\n  \"b\"\n]\n") {
+		t.Fatalf("text outside server changed:\n%s", got)
+	}
+	if !Equal(MustParse(got), v) {
+		t.Fatalf("patched text does not parse back to v:\n%s", got)
+	}
+}
+
+func TestPatchTopLevelChangeRewritesDocument(t *testing.T) {
+	v := MustParse(patchSource)
+	v.(Object)["added"] = true
+	got, err := Patch(patchSource, v, []string{"added"}, SerializeOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "//") || !Equal(MustParse(got), v) {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestPatchUnknownPath(t *testing.T) {
+	v := MustParse(patchSource)
+	if _, err := Patch(patchSource, v, []string{"server.nope"}, SerializeOptions{}); err == nil || !strings.Contains(err.Error(), "server.nope") {
+		t.Fatalf("got %v", err)
+	}
+}
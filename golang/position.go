@@ -0,0 +1,117 @@
+package jhon
+
+import "fmt"
+
+// ============================================================================
+// Source positions of parsed values
+// ============================================================================
+
+// Position locates a value in the source text.
+type Position struct {
+	Offset int // 0-based byte offset
+	Line   int // 1-based
+	Column int // 1-based, in bytes
+}
+
+// Positions maps value paths, as rendered in error messages
+// (`server.ports[1]`, with "" for the document root), to where each value
+// starts in the source. For strings that is the opening quote; for objects
+// and arrays, the opening brace or bracket.
+type Positions map[string]Position
+
+// ParseWithPositions is ParseWithOptions that also records the source
+// position of every value, so tooling such as a linter can point at the
+// line a suspicious setting came from:
+//
+//	v, pos, err := jhon.ParseWithPositions(text, jhon.ParseOptions{})
+//	p := pos["server.timeout"] // p.Line, p.Column
+//
+// Tracking costs an allocation per value, which is why it is opt-in.
+func ParseWithPositions(input string, opts ParseOptions) (Value, Positions, error) {
+	p := newParser([]byte(input))
+	p.opts = opts
+	p.valuePos = map[string]nodePos{}
+	v, err := p.parseDocument()
+	if err != nil {
+		return nil, nil, err
+	}
+	positions := make(Positions, len(p.valuePos))
+	for path, np := range p.valuePos {
+		positions[path] = np.position()
+	}
+	return v, positions, nil
+}
+
+// NodeAt returns the innermost key or value of input that contains the
+// byte at offset, for editor features such as hover: the path of the value,
+// as Positions renders it, and the value itself. A member's node runs from
+// the start of its key to the end of its value, so an offset inside the key
+// `port` of `port = 8080` yields "port" and int64(8080). An offset between
+// nodes yields the innermost container around it, or the root with path "".
+// It is an error for input not to parse or for offset to lie outside
+// [0, len(input)).
+func NodeAt(input string, offset int) (path string, value Value, err error) {
+	if offset < 0 || offset >= len(input) {
+		return "", nil, fmt.Errorf("jhon: NodeAt: offset %d outside input of length %d", offset, len(input))
+	}
+	p := newParser([]byte(input))
+	p.keyPos = map[string]nodePos{}
+	p.valuePos = map[string]nodePos{}
+	p.valueEnd = map[string]nodePos{}
+	v, err := p.parseDocument()
+	if err != nil {
+		return "", nil, err
+	}
+	best, bestStart, bestEnd := "", -1, 0
+	for path, end := range p.valueEnd {
+		start, ok := p.keyPos[path]
+		if !ok {
+			start = p.valuePos[path]
+		}
+		if path == "" || offset < start.offset || offset >= end.offset {
+			continue
+		}
+		if start.offset > bestStart || (start.offset == bestStart && end.offset < bestEnd) {
+			best, bestStart, bestEnd = path, start.offset, end.offset
+		}
+	}
+	return best, newValueIndex(v).values[best], nil
+}
+
+func (np nodePos) position() Position {
+	return Position{Offset: np.offset, Line: np.line, Column: np.col}
+}
+
+// Span is the source text of one top-level value: input[Start.Offset:
+// End.Offset]. It runs from the first byte of the value to just past its
+// last — the closing quote, brace or bracket, or the last digit — and so
+// never includes the surrounding whitespace, separators or comments.
+type Span struct {
+	Key   string // the value's key in an object-mode document
+	Index int    // the value's index in an array-mode document; -1 for keys
+	Start Position
+	End   Position
+}
+
+// ScanSpans validates input and returns the span of every top-level value
+// in source order. It is the primitive for surgical edits: replacing one
+// span and keeping the rest of the text leaves comments, layout and every
+// other value byte-identical.
+//
+//	spans, err := jhon.ScanSpans(text)
+//	for _, s := range spans {
+//		if s.Key == "version" {
+//			text = text[:s.Start.Offset] + `"1.2.4"` + text[s.End.Offset:]
+//		}
+//	}
+//
+// A document with a syntax error yields no spans; an empty one yields none.
+func ScanSpans(input string) ([]Span, error) {
+	p := newParser([]byte(input))
+	spans := []Span{}
+	p.spans = &spans
+	if _, err := p.parseDocument(); err != nil {
+		return nil, err
+	}
+	return spans, nil
+}
@@ -0,0 +1,153 @@
+package jhon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseWithPositions(t *testing.T) {
+	input := "// config\nname = \"app\"\nserver = {\n  timeout = 30\n  ports = [80, 443]\n}\n"
+	_, pos, err := ParseWithPositions(input, ParseOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cases := map[string]Position{
+		"":                {Offset: 10, Line: 2, Column: 1},
+		"name":            {Offset: 17, Line: 2, Column: 8},
+		"server":          {Offset: 32, Line: 3, Column: 10},
+		"server.timeout":  {Offset: 46, Line: 4, Column: 13},
+		"server.ports":    {Offset: 59, Line: 5, Column: 11},
+		"server.ports[1]": {Offset: 64, Line: 5, Column: 16},
+	}
+	for path, want := range cases {
+		if got, ok := pos[path]; !ok || got != want {
+			t.Errorf("%q: got %+v (present=%v), want %+v", path, got, ok, want)
+		}
+		if got := pos[path]; input[got.Offset] == ' ' || input[got.Offset] == '\n' {
+			t.Errorf("%q: offset %d points at whitespace", path, got.Offset)
+		}
+	}
+}
+
+func TestParseWithPositionsArrayMode(t *testing.T) {
+	_, pos, err := ParseWithPositions("1\n{a = true}", ParseOptions{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := pos["[1].a"]; got.Line != 2 || got.Column != 6 {
+		t.Fatalf("got %+v", got)
+	}
+	if _, _, err := ParseWithPositions("a=[", ParseOptions{}); err == nil {
+		t.Fatal("expected an error")
+	}
+	if v, pos, err := ParseWithPositions("", ParseOptions{}); v != nil || len(pos) != 0 || err != nil {
+		t.Fatalf("empty document: got %v %v %v", v, pos, err)
+	}
+}
+
+func TestScanSpans(t *testing.T) {
+	input := "// header\nname = \"app\" // keep me\nversion = \"1.2.3\", port=80\nserver = {\n  host = \"x\"\n}\n"
+	spans, err := ScanSpans(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]string{
+		"name":    `"app"`,
+		"version": `"1.2.3"`,
+		"port":    "80",
+		"server":  "{\n  host = \"x\"\n}",
+	}
+	if len(spans) != len(want) {
+		t.Fatalf("got %d spans: %+v", len(spans), spans)
+	}
+	for i, key := range []string{"name", "version", "port", "server"} {
+		s := spans[i]
+		if s.Key != key || s.Index != -1 {
+			t.Fatalf("span %d: got key %q index %d", i, s.Key, s.Index)
+		}
+		if got := input[s.Start.Offset:s.End.Offset]; got != want[key] {
+			t.Errorf("%s: got %q, want %q", key, got, want[key])
+		}
+	}
+	if s := spans[1]; s.Start.Line != 3 || s.Start.Column != 11 || s.End.Line != 3 || s.End.Column != 18 {
+		t.Errorf("version span: got %+v", s)
+	}
+
+	v := spans[1]
+	edited := input[:v.Start.Offset] + `"1.2.4"` + input[v.End.Offset:]
+	if edited != strings.Replace(input, "1.2.3", "1.2.4", 1) {
+		t.Fatalf("surgical edit changed more than the value:\n%s", edited)
+	}
+}
+
+func TestScanSpansArrayMode(t *testing.T) {
+	input := "1, [2, 3]\n'four'"
+	spans, err := ScanSpans(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var got []string
+	for i, s := range spans {
+		if s.Index != i || s.Key != "" {
+			t.Fatalf("span %d: %+v", i, s)
+		}
+		got = append(got, input[s.Start.Offset:s.End.Offset])
+	}
+	if strings.Join(got, "|") != "1|[2, 3]|'four'" {
+		t.Fatalf("got %q", got)
+	}
+	if _, err := ScanSpans("a = [1"); err == nil {
+		t.Fatal("expected an error")
+	}
+}
+
+func TestNodeAt(t *testing.T) {
+	input := "name = \"app\" // the name\nserver = {\n  port = 8080\n  hosts = [\"a\", {alias = \"b\"}]\n}\n"
+	cases := []struct {
+		at   string // the cursor is at the first occurrence of at
+		path string
+		want Value
+	}{
+		{"name", "name", "app"},
+		{"ame", "name", "app"},
+		{`"app"`, "name", "app"},
+		{"pp\"", "name", "app"},
+		{"// the", "", nil},
+		{"port", "server.port", int64(8080)},
+		{"080", "server.port", int64(8080)},
+		{"server", "server", nil},
+		{"[", "server.hosts", nil},
+		{`"a"`, "server.hosts[0]", "a"},
+		{"alias", "server.hosts[1].alias", "b"},
+		{"{alias", "server.hosts[1]", nil},
+		{"\n}", "server", nil},
+	}
+	v, err := Parse(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		path, got, err := NodeAt(input, strings.Index(input, c.at))
+		if err != nil {
+			t.Fatalf("%q: %v", c.at, err)
+		}
+		want := c.want
+		if want == nil {
+			want = v
+			if c.path != "" {
+				want, _ = v.(Object).Path(strings.NewReplacer("[", ".", "]", "").Replace(c.path))
+			}
+		}
+		if path != c.path || !Equal(got, want) {
+			t.Errorf("at %q: got %q %#v, want %q %#v", c.at, path, got, c.path, want)
+		}
+	}
+	for _, offset := range []int{-1, len(input)} {
+		if _, _, err := NodeAt(input, offset); err == nil {
+			t.Errorf("offset %d: expected an error", offset)
+		}
+	}
+	if _, _, err := NodeAt("a = [", 1); err == nil {
+		t.Error("expected a parse error")
+	}
+}
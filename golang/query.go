@@ -0,0 +1,175 @@
+package jhon
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// Path lookup and wildcard queries
+//
+// A path is a dot-separated list of segments: `server.ports.0` or, in the
+// form error messages use, `server.ports[0]`. On an Object a segment is a
+// key; on an Array it must be a decimal index. A key holding '.', '[' or
+// ']' is written quoted in brackets, `hosts["db.local"]`, again as error
+// messages write it.
+// ============================================================================
+
+// Path returns the value at path and whether it exists. Segments are taken
+// literally; see QueryAll for wildcards. The empty path returns o itself.
+func (o Object) Path(path string) (Value, bool) {
+	segs, ok := splitQueryPath(path)
+	if !ok {
+		return nil, false
+	}
+	var v Value = o
+	for _, seg := range segs {
+		next, ok := childAt(v, seg.key)
+		if !ok {
+			return nil, false
+		}
+		v = next
+	}
+	return v, true
+}
+
+// GetPathOr returns the value at path, or def when a segment is missing or
+// the value is null — GetOr for nested optional settings:
+//
+//	timeout := cfg.GetPathOr("server.http.timeout", int64(30))
+func (o Object) GetPathOr(path string, def Value) Value {
+	if v, ok := o.Path(path); ok && v != nil {
+		return v
+	}
+	return def
+}
+
+// QueryAll returns every value matching pattern, a path in which a `*`
+// segment matches any key of an Object or any index of an Array (and
+// nothing on a scalar); a quoted `["*"]` matches only the key "*":
+//
+//	names := cfg.QueryAll("server.middleware.*.name")
+//
+// Results are in document order for arrays and in sorted key order for
+// objects, so the same input always yields the same slice. Branches that
+// do not match are skipped rather than reported.
+func (o Object) QueryAll(pattern string) []Value {
+	segs, ok := splitQueryPath(pattern)
+	if !ok {
+		return nil
+	}
+	var out []Value
+	queryInto(&out, o, segs)
+	return out
+}
+
+func queryInto(out *[]Value, v Value, segs []querySeg) {
+	if len(segs) == 0 {
+		*out = append(*out, v)
+		return
+	}
+	if !segs[0].wild {
+		if next, ok := childAt(v, segs[0].key); ok {
+			queryInto(out, next, segs[1:])
+		}
+		return
+	}
+	switch val := v.(type) {
+	case Object:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			queryInto(out, val[k], segs[1:])
+		}
+	case Array:
+		for _, el := range val {
+			queryInto(out, el, segs[1:])
+		}
+	}
+}
+
+// childAt steps one segment into v.
+func childAt(v Value, seg string) (Value, bool) {
+	switch val := v.(type) {
+	case Object:
+		child, ok := val[seg]
+		return child, ok
+	case Array:
+		if !isDecimalRun(seg) {
+			return nil, false
+		}
+		i, err := strconv.Atoi(seg)
+		if err != nil || i >= len(val) {
+			return nil, false
+		}
+		return val[i], true
+	}
+	return nil, false
+}
+
+// querySeg is one segment of a path: a key or index, or a `*` wildcard,
+// which only an unquoted segment can be.
+type querySeg struct {
+	key  string
+	wild bool
+}
+
+// splitQueryPath splits a path into segments, turning `a[0]` into `a`, `0`
+// and `a["b.c"]` into `a`, `b.c`. It reports false for a bracket that is
+// not closed or a quoted key that does not unquote.
+func splitQueryPath(path string) ([]querySeg, bool) {
+	if path == "" {
+		return nil, true
+	}
+	var segs []querySeg
+	i := 0
+	if path[0] == '.' {
+		i++
+	}
+	for {
+		var key string
+		switch {
+		case strings.HasPrefix(path[i:], `["`):
+			j := i + 2
+			for j < len(path) && path[j] != '"' {
+				if path[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j+1 >= len(path) || path[j+1] != ']' {
+				return nil, false
+			}
+			k, err := strconv.Unquote(path[i+1 : j+1])
+			if err != nil {
+				return nil, false
+			}
+			segs = append(segs, querySeg{key: k})
+			i = j + 2
+		case i < len(path) && path[i] == '[':
+			j := strings.IndexByte(path[i:], ']')
+			if j < 0 {
+				return nil, false
+			}
+			key, i = path[i+1:i+j], i+j+1
+			segs = append(segs, querySeg{key: key, wild: key == "*"})
+		default:
+			j := strings.IndexAny(path[i:], ".[")
+			if j < 0 {
+				j = len(path) - i
+			}
+			key, i = path[i:i+j], i+j
+			segs = append(segs, querySeg{key: key, wild: key == "*"})
+		}
+		if i == len(path) {
+			return segs, true
+		}
+		if path[i] == '.' {
+			i++
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package jhon
+
+import (
+	"reflect"
+	"testing"
+)
+
+var queryDoc = MustParse(`
+server = {
+  middleware = [
+    { name = "auth", enabled = true }
+    { name = "gzip", enabled = false }
+    { kind = "nameless" }
+  ]
+}
+flags = { beta = true, alpha = false }
+`).(Object)
+
+func TestObjectPath(t *testing.T) {
+	cases := map[string]Value{
+		"server.middleware.1.name":  "gzip",
+		"server.middleware[0].name": "auth",
+		"flags.alpha":               false,
+	}
+	for path, want := range cases {
+		if got, ok := queryDoc.Path(path); !ok || got != want {
+			t.Errorf("%q: got %v, %v", path, got, ok)
+		}
+	}
+	for _, path := range []string{"server.nope", "server.middleware.3", "server.middleware.-1", "flags.alpha.x", "server.*"} {
+		if got, ok := queryDoc.Path(path); ok {
+			t.Errorf("%q: expected no match, got %v", path, got)
+		}
+	}
+	if got, ok := queryDoc.Path(""); !ok || !reflect.DeepEqual(got, queryDoc) {
+		t.Errorf("empty path: got %v, %v", got, ok)
+	}
+}
+
+func TestObjectPathQuotedKeys(t *testing.T) {
+	doc := MustParse(`hosts = { "db.local" = { ports = [5432] }, "a[0]" = 1, "*" = "star", "" = "empty" }`).(Object)
+	cases := map[string]Value{
+		`hosts["db.local"].ports[0]`:   int64(5432),
+		`hosts["db.local"]["ports"].0`: int64(5432),
+		`hosts["a[0]"]`:                int64(1),
+		`hosts[""]`:                    "empty",
+		`hosts["\x2a"]`:                "star",
+		formatPath([]pathSeg{{key: "hosts", index: -1}, {key: "db.local", index: -1}, {key: "ports", index: -1}, {index: 0}}): int64(5432),
+	}
+	for path, want := range cases {
+		if got, ok := doc.Path(path); !ok || got != want {
+			t.Errorf("%q: got %v, %v", path, got, ok)
+		}
+	}
+	for _, path := range []string{`hosts["db.local`, `hosts["db.local"`, `hosts["\q"]`, `hosts[0`} {
+		if got, ok := doc.Path(path); ok {
+			t.Errorf("%q: expected no match, got %v", path, got)
+		}
+	}
+	if got := doc.QueryAll(`hosts["*"]`); !reflect.DeepEqual(got, []Value{"star"}) {
+		t.Errorf(`["*"] should match only the key "*": got %#v`, got)
+	}
+}
+
+func TestObjectGetPathOr(t *testing.T) {
+	doc := MustParse(`server = { tls = { cert = "a.pem", key = null }, ports = [80] }`).(Object)
+	cases := map[string]Value{
+		"server.tls.cert":     "a.pem",
+		"server.ports[0]":     int64(80),
+		"server.tls.key":      "default",
+		"server.http.timeout": "default",
+		"server.ports.1":      "default",
+		"server.tls.cert.x":   "default",
+	}
+	for path, want := range cases {
+		if got := doc.GetPathOr(path, "default"); got != want {
+			t.Errorf("%q: got %#v, want %#v", path, got, want)
+		}
+	}
+}
+
+func TestObjectQueryAll(t *testing.T) {
+	cases := map[string][]Value{
+		"server.middleware.*.name":    {"auth", "gzip"},
+		"server.middleware.*.enabled": {true, false},
+		"flags.*":                     {false, true}, // sorted keys: alpha, beta
+		"*.middleware.*.kind":         {"nameless"},
+		"flags.*.deeper":              nil,
+		"missing.*":                   nil,
+	}
+	for pattern, want := range cases {
+		if got := queryDoc.QueryAll(pattern); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %#v, want %#v", pattern, got, want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package jhon
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ============================================================================
+// Schema — lightweight validation of required keys and value types
+// ============================================================================
+
+// Schema describes what a config document must contain. Paths use the
+// dotted form of Object.Path (`server.host`, `servers.0.port`).
+//
+// Types maps a path to the kind its value must have: "string", "number",
+// "integer" (a number with no fractional part), "bool", "object", "array"
+// or "null". A typed path that is absent is not an error unless it is
+// also listed in Required.
+type Schema struct {
+	Required []string
+	Types    map[string]string
+}
+
+// ValidationError reports one way a document fails a Schema.
+type ValidationError struct {
+	Path    string
+	Problem string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("jhon: %s: %s", e.Path, e.Problem)
+}
+
+// Validate checks o against s and returns every problem found, or nil. The
+// order is deterministic: missing required keys in the order listed, then
+// type mismatches by path.
+func (s Schema) Validate(o Object) []error {
+	var errs []error
+	for _, path := range s.Required {
+		if _, ok := o.Path(path); !ok {
+			errs = append(errs, &ValidationError{Path: path, Problem: "required key is missing"})
+		}
+	}
+	paths := make([]string, 0, len(s.Types))
+	for path := range s.Types {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		want := s.Types[path]
+		v, ok := o.Path(path)
+		if !ok {
+			continue
+		}
+		match, known := valueHasType(v, want)
+		switch {
+		case !known:
+			errs = append(errs, &ValidationError{Path: path, Problem: fmt.Sprintf("schema names unknown type %q", want)})
+		case !match:
+			errs = append(errs, &ValidationError{Path: path, Problem: fmt.Sprintf("expected %s, got %s", want, describeValue(v))})
+		}
+	}
+	return errs
+}
+
+// valueHasType reports whether v is of the schema type name, and whether
+// the name is one Schema knows.
+func valueHasType(v Value, name string) (match, known bool) {
+	switch name {
+	case "string", "number", "bool", "object", "array", "null":
+		return describeValue(v) == name, true
+	case "integer":
+		if describeValue(v) != "number" {
+			return false, true
+		}
+		if f, ok := v.(float64); ok {
+			return f == float64(int64(f)), true
+		}
+		return numberAsBigFloat(v).IsInt(), true
+	}
+	return false, false
+}
@@ -0,0 +1,54 @@
+package jhon
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSchemaValidate(t *testing.T) {
+	cfg := MustParse(`
+server = { host = 8080, port = 80.5 }
+servers = [{ port = 1 }]
+debug = "yes"
+`).(Object)
+	s := Schema{
+		Required: []string{"server.host", "name", "servers.0.port", "servers.1.port"},
+		Types: map[string]string{
+			"server.host":    "string",
+			"server.port":    "integer",
+			"servers.0.port": "integer",
+			"servers":        "array",
+			"debug":          "bool",
+			"name":           "string",
+			"extra":          "color",
+		},
+	}
+	var got []string
+	for _, err := range s.Validate(cfg) {
+		got = append(got, err.Error())
+	}
+	want := []string{
+		"jhon: name: required key is missing",
+		"jhon: servers.1.port: required key is missing",
+		"jhon: debug: expected bool, got string",
+		"jhon: server.host: expected string, got number",
+		"jhon: server.port: expected integer, got number",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestSchemaValidateOK(t *testing.T) {
+	cfg := MustParse(`host = "x", port = 8080, ratio = 1.0, tags = [], opt = null`).(Object)
+	s := Schema{
+		Required: []string{"host", "port"},
+		Types:    map[string]string{"host": "string", "port": "integer", "ratio": "integer", "tags": "array", "opt": "null"},
+	}
+	if errs := s.Validate(cfg); errs != nil {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if errs := (Schema{Types: map[string]string{"x": "color"}}).Validate(Object{"x": int64(1)}); len(errs) != 1 || !strings.Contains(errs[0].Error(), `unknown type "color"`) {
+		t.Fatalf("got %v", errs)
+	}
+}
@@ -0,0 +1,149 @@
+package jhon
+
+import (
+	"math/big"
+	"reflect"
+	"strings"
+)
+
+// ============================================================================
+// Skeleton — a ready-to-edit config file for a Go struct
+// ============================================================================
+
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+)
+
+// Skeleton returns a JHON template for v, typically a config struct: every
+// field, with the value v holds, in declaration order, pretty-printed as
+// Format writes it. A `doc` tag becomes a `///` doc comment above its key,
+// so ParseWithDocs reads it back:
+//
+//	type Config struct {
+//		Port int `jhon:"port" doc:"Port the server listens on."`
+//	}
+//
+//	jhon.Skeleton(Config{Port: 8080})
+//	// /// Port the server listens on.
+//	// port = 8080
+//
+// Pass a zero value for a template of zero values, or one filled with
+// defaults. Nil struct pointers are expanded to their zero struct so that
+// nested fields are listed too, and nil maps and slices are written as
+// {} and []. Fields of a type Marshal cannot encode are left out.
+func Skeleton(v interface{}) string {
+	return SkeletonWithOptions(v, SerializeOptions{})
+}
+
+// SkeletonWithOptions is Skeleton with serialize options, as Format takes
+// them; CommentStyle changes the marker of the doc comments.
+func SkeletonWithOptions(v interface{}, opts SerializeOptions) string {
+	s := &skeleton{doc: &Document{Comments: map[string]Comments{}, order: map[string]int{}}}
+	val, ok := s.value(reflect.ValueOf(v))
+	if ok {
+		s.doc.Value = val
+	}
+	return s.doc.Format(opts)
+}
+
+type skeleton struct {
+	doc  *Document
+	enc  encoder
+	path []pathSeg
+	// next is the order given to the next struct field, so fields are
+	// written in declaration order.
+	next int
+}
+
+// value builds the template value of rv, reporting false for a value
+// Marshal cannot encode.
+func (s *skeleton) value(rv reflect.Value) (Value, bool) {
+	if !rv.IsValid() {
+		return nil, true
+	}
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if t := rv.Type(); t == reflect.PtrTo(bigIntType) || t == reflect.PtrTo(bigFloatType) {
+			break
+		}
+		if !rv.IsNil() {
+			rv = rv.Elem()
+		} else if rv.Kind() == reflect.Ptr && expandable(rv.Type().Elem()) {
+			rv = reflect.Zero(rv.Type().Elem())
+		} else {
+			return nil, true
+		}
+	}
+	switch {
+	case expandable(rv.Type()):
+		return s.structValue(rv), true
+	case rv.Kind() == reflect.Map && rv.Type().Key().Kind() == reflect.String:
+		obj := make(Object, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			k := iter.Key().String()
+			s.path = append(s.path, pathSeg{key: k, index: -1})
+			if val, ok := s.value(iter.Value()); ok {
+				obj[k] = val
+			}
+			s.path = s.path[:len(s.path)-1]
+		}
+		return obj, true
+	case (rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() != reflect.Uint8) || rv.Kind() == reflect.Array:
+		arr := make(Array, 0, rv.Len())
+		for i := 0; i < rv.Len(); i++ {
+			s.path = append(s.path, pathSeg{index: i})
+			val, ok := s.value(rv.Index(i))
+			s.path = s.path[:len(s.path)-1]
+			if !ok {
+				return nil, false
+			}
+			arr = append(arr, val)
+		}
+		return arr, true
+	}
+	val, err := s.enc.toValue(rv)
+	return val, err == nil
+}
+
+func (s *skeleton) structValue(rv reflect.Value) Object {
+	t := rv.Type()
+	obj := Object{}
+	for _, f := range structFields(t) {
+		sf := t.FieldByIndex(f.index)
+		fv, ok := fieldByIndex(rv, f.index)
+		if !ok {
+			fv = reflect.Zero(sf.Type) // behind a nil embedded pointer
+		}
+		s.path = append(s.path, pathSeg{key: f.name, index: -1})
+		var val Value
+		switch {
+		case f.hasOption("char") && isCharKind(fv.Kind()):
+			val, ok = string(rune(charCode(fv))), true
+		case f.hasOption("complex") && isComplexKind(fv.Kind()):
+			val, ok = complexObject(fv), true
+		default:
+			val, ok = s.value(fv)
+		}
+		if ok {
+			obj[f.name] = val
+			path := formatPath(s.path)
+			s.doc.order[path] = s.next
+			s.next++
+			if doc := sf.Tag.Get("doc"); doc != "" {
+				var lines []string
+				for _, line := range strings.Split(doc, "\n") {
+					lines = append(lines, strings.TrimRight("/// "+line, " "))
+				}
+				s.doc.Comments[path] = Comments{Before: lines}
+			}
+		}
+		s.path = s.path[:len(s.path)-1]
+	}
+	return obj
+}
+
+// expandable reports whether Skeleton lists the fields of type t.
+func expandable(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != bigIntType && t != bigFloatType
+}
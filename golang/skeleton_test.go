@@ -0,0 +1,80 @@
+package jhon
+
+import (
+	"reflect"
+	"testing"
+)
+
+type skeletonTLS struct {
+	Enabled  bool   `jhon:"enabled" doc:"Serve HTTPS."`
+	CertPath string `jhon:"cert_path"`
+}
+
+type skeletonConfig struct {
+	Name    string            `jhon:"name" doc:"Name shown in logs."`
+	Port    int               `jhon:"port" doc:"Port the server listens on.\nMust be free at startup."`
+	Ratio   float64           `jhon:"ratio"`
+	Tags    []string          `jhon:"tags"`
+	Labels  map[string]string `jhon:"labels"`
+	TLS     *skeletonTLS      `jhon:"tls" doc:"TLS settings."`
+	Hidden  string            `jhon:"-"`
+	private int
+}
+
+func TestSkeleton(t *testing.T) {
+	got := Skeleton(skeletonConfig{Port: 8080})
+	want := `/// Name shown in logs.
+name = ""
+/// Port the server listens on.
+/// Must be free at startup.
+port = 8080
+ratio = 0
+tags = []
+labels = {}
+/// TLS settings.
+tls = {
+  /// Serve HTTPS.
+  enabled = false
+  cert_path = ""
+}
+`
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestSkeletonParsesBack(t *testing.T) {
+	text := Skeleton(&skeletonConfig{Name: "api", Tags: []string{"a", "b"}})
+	v, docs, err := ParseWithDocs(text, ParseOptions{})
+	if err != nil {
+		t.Fatalf("skeleton does not parse: %v\n%s", err, text)
+	}
+	if docs["tls.enabled"] != "Serve HTTPS." || docs["port"] != "Port the server listens on.\nMust be free at startup." {
+		t.Fatalf("docs = %#v", docs)
+	}
+	var cfg skeletonConfig
+	if err := v.(Object).Decode(&cfg); err != nil {
+		t.Fatal(err)
+	}
+	want := skeletonConfig{Name: "api", Tags: []string{"a", "b"}, Labels: map[string]string{}, TLS: &skeletonTLS{}}
+	if !reflect.DeepEqual(cfg, want) {
+		t.Fatalf("got %#v want %#v", cfg, want)
+	}
+}
+
+func TestSkeletonSkipsUnsupportedFields(t *testing.T) {
+	got := Skeleton(struct {
+		A    int
+		Done chan bool
+	}{A: 1})
+	if got != "A = 1\n" {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestSkeletonHashComments(t *testing.T) {
+	got := SkeletonWithOptions(skeletonTLS{}, SerializeOptions{CommentStyle: "#"})
+	if want := "# Serve HTTPS.\nenabled = false\ncert_path = \"\"\n"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+}
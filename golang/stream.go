@@ -0,0 +1,683 @@
+package jhon
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// ============================================================================
+// Streaming decode
+// ============================================================================
+
+// A Decoder reads a JHON document from an input stream.
+type Decoder struct {
+	r    io.Reader
+	opts DecodeOptions
+	done bool
+}
+
+// NewDecoder returns a Decoder that reads from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// Reset discards the Decoder's state and makes it read from r, like
+// bufio.Reader.Reset, so one Decoder can be reused across many inputs.
+// Settings such as DisallowUnknownFields are kept.
+func (d *Decoder) Reset(r io.Reader) {
+	d.r = r
+	d.done = false
+}
+
+// DisallowUnknownFields makes Decode fail on input keys that match no field
+// of the destination struct. See DecodeOptions.DisallowUnknownFields.
+func (d *Decoder) DisallowUnknownFields() {
+	d.opts.DisallowUnknownFields = true
+}
+
+// Decode reads the whole document and stores it in the value pointed to by
+// v, as Unmarshal does. A JHON stream holds exactly one document, so any
+// later call returns io.EOF.
+func (d *Decoder) Decode(v interface{}) error {
+	input, err := d.readAll()
+	if err != nil {
+		return err
+	}
+	return UnmarshalWithOptions(string(input), v, d.opts)
+}
+
+// ArrayReader prepares to read the elements of an array-mode document (SPEC
+// §2.2) one at a time. The input is read incrementally: only the text of
+// the element being decoded is held, and each element is built only when
+// ArrayReader.Decode asks for it, so a consumer can process a
+// million-record document in constant memory. A document that is a single
+// bracketed array, `[ {...}, {...} ]`, yields that array's items; anything
+// after its closing ']' is an error. An empty document yields no elements;
+// an object-mode document is an error.
+func (d *Decoder) ArrayReader() (*ArrayReader, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+	d.done = true
+	a := &ArrayReader{r: bufio.NewReader(d.r), opts: d.opts, line: 1, col: 1}
+	if _, err := a.skip(); err != nil {
+		return nil, err
+	}
+	if c, ok := a.peek(); ok && c == '[' {
+		a.open = a.here()
+		a.read()
+		a.bracketed = true
+		if _, err := a.skip(); err != nil {
+			return nil, err
+		}
+	}
+	if err := a.fill(true); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (d *Decoder) readAll() ([]byte, error) {
+	if d.done {
+		return nil, io.EOF
+	}
+	d.done = true
+	return io.ReadAll(d.r)
+}
+
+// ArrayReader iterates over the elements of an array-mode document:
+//
+//	ar, err := dec.ArrayReader()
+//	for ar.More() {
+//		var rec Record
+//		if err := ar.Decode(&rec); err != nil { ... }
+//	}
+type ArrayReader struct {
+	r     *bufio.Reader
+	opts  DecodeOptions
+	index int
+	err   error
+	// line, col and offset locate the next byte of r.
+	line, col, offset int
+	// bracketed is set when the document is one `[...]`, opened at open,
+	// whose items are read instead.
+	bracketed bool
+	open      nodePos
+	// pending is the text of the next element, starting at pendingAt, or
+	// nil when there is none; after is the error found past it, reported
+	// once it has been decoded.
+	pending   []byte
+	pendingAt nodePos
+	after     error
+	// readErr is the first error of r other than io.EOF.
+	readErr error
+}
+
+// More reports whether another element (or a pending error) remains.
+func (a *ArrayReader) More() bool {
+	return a.err != nil || a.pending != nil
+}
+
+// Decode parses the next element and stores it in the value pointed to by
+// v, as Unmarshal does. It returns io.EOF after the last element.
+func (a *ArrayReader) Decode(v interface{}) error {
+	if a.err != nil {
+		return a.err
+	}
+	if a.pending == nil {
+		return io.EOF
+	}
+	p := newParser(a.pending)
+	p.line, p.col = a.pendingAt.line, a.pendingAt.col
+	p.keyPos = map[string]nodePos{}
+	p.pushIndex(a.index)
+	val, err := p.parseValue()
+	if err == nil && p.pos < len(p.input) {
+		err = p.syntaxErr("items on the same line must be separated by a comma")
+	}
+	if err != nil {
+		if pe, ok := err.(*ParseError); ok {
+			pe.Position += a.pendingAt.offset
+		}
+		a.pending, a.err = nil, err
+		return err
+	}
+	index := a.index
+	a.index++
+	a.pending = nil
+	if a.after != nil {
+		a.err, a.after = a.after, nil
+	} else if err := a.fill(false); err != nil {
+		a.err = err
+	}
+
+	rv, err := decodeTarget(v)
+	if err != nil {
+		return err
+	}
+	d := &decoder{opts: a.opts, keyPos: p.keyPos, path: []pathSeg{{index: index}}}
+	return d.decode(val, rv)
+}
+
+// fill reads the text of the next element into pending, or finds the end
+// of the array, and checks what separates it from the one after. For the
+// first element, an '=' after it means the document is in object mode.
+func (a *ArrayReader) fill(first bool) error {
+	err := a.next(first)
+	if a.readErr != nil {
+		a.pending = nil
+		return a.readErr
+	}
+	return err
+}
+
+func (a *ArrayReader) next(first bool) error {
+	c, ok := a.peek()
+	switch {
+	case !ok && a.bracketed:
+		err := a.errHere(fmt.Sprintf("unterminated array opened at %d:%d", a.open.line, a.open.col))
+		err.Kind = ParseErrorEOF
+		return err
+	case !ok:
+		return nil
+	case a.bracketed && c == ']':
+		a.read()
+		if _, err := a.skip(); err != nil {
+			return err
+		}
+		if _, ok := a.peek(); ok {
+			return a.errHere("ArrayReader reads a single top-level array, but more follows its closing ']'")
+		}
+		return nil
+	case c == '=':
+		return a.errHere("cannot mix key=value pairs and bare values at top level")
+	}
+	a.pendingAt = a.here()
+	a.pending = a.element()
+	sawNewline, err := a.skip()
+	if err != nil {
+		a.after = err
+		return nil
+	}
+	sawComma := false
+	if c, ok := a.peek(); ok && c == ',' {
+		a.read()
+		sawComma = true
+		more, err := a.skip()
+		if err != nil {
+			a.after = err
+			return nil
+		}
+		sawNewline = sawNewline || more
+	}
+	c, ok = a.peek()
+	switch {
+	case ok && c == '=' && first:
+		a.pending = nil
+		return a.errHere("document is in object mode, not an array")
+	case ok && c == '=':
+		a.pending = nil // a key, not an element
+		return a.errHere("cannot mix key=value pairs and bare values at top level")
+	case ok && !sawNewline && !sawComma && !(a.bracketed && c == ']'):
+		a.after = a.errHere("items on the same line must be separated by a comma")
+	}
+	return nil
+}
+
+// element reads the text of one value: a scalar up to the whitespace,
+// separator or comment that ends it, or a container through its closer,
+// skipping over strings and comments inside it.
+func (a *ArrayReader) element() []byte {
+	var text []byte
+	depth := 0
+	for {
+		c, ok := a.peek()
+		if !ok {
+			return text
+		}
+		if depth == 0 && len(text) > 0 {
+			switch c {
+			case ' ', '\t', '\r', '\n', ',', '=', ']', '}':
+				return text
+			case '/':
+				if a.commentAhead() {
+					return text
+				}
+			}
+		}
+		var prev byte
+		if len(text) > 0 {
+			prev = text[len(text)-1]
+		}
+		a.read()
+		text = append(text, c)
+		switch c {
+		case '"', '\'':
+			text = a.quoted(text, c)
+		case '{', '[':
+			depth++
+		case '}', ']':
+			if depth > 0 {
+				depth--
+			}
+		case '/':
+			if depth > 0 {
+				if next, ok := a.peek(); ok && (next == '/' || next == '*') {
+					text = a.comment(text)
+				}
+			}
+		case 'r', 'R':
+			if !isWordByte(prev) {
+				text = a.raw(text)
+			}
+		}
+		if depth == 0 && len(text) == 1 && (c == ',' || c == '=' || c == ']' || c == '}') {
+			return text // a stray separator, left for the parser to report
+		}
+	}
+}
+
+// quoted reads the rest of a string opened by quote onto text.
+func (a *ArrayReader) quoted(text []byte, quote byte) []byte {
+	escaped := false
+	for {
+		c, ok := a.peek()
+		if !ok {
+			return text
+		}
+		a.read()
+		text = append(text, c)
+		switch {
+		case escaped:
+			escaped = false
+		case c == '\\':
+			escaped = true
+		case c == quote:
+			return text
+		}
+	}
+}
+
+// raw reads the rest of a raw string onto text, whose 'r' was just read,
+// if one starts here.
+func (a *ArrayReader) raw(text []byte) []byte {
+	n := 0
+	for {
+		b, _ := a.r.Peek(n + 1)
+		if len(b) < n+1 || b[n] != '#' {
+			break
+		}
+		n++
+	}
+	if b, _ := a.r.Peek(n + 1); len(b) < n+1 || b[n] != '"' {
+		return text
+	}
+	for i := 0; i <= n; i++ {
+		text = append(text, a.read())
+	}
+	body := len(text)
+	closing := append([]byte{'"'}, bytes.Repeat([]byte{'#'}, n)...)
+	for {
+		if _, ok := a.peek(); !ok {
+			return text
+		}
+		text = append(text, a.read())
+		if len(text)-body >= len(closing) && bytes.HasSuffix(text, closing) {
+			return text
+		}
+	}
+}
+
+// comment reads the rest of a comment onto text, whose '/' was just read.
+func (a *ArrayReader) comment(text []byte) []byte {
+	opener := a.read()
+	text = append(text, opener)
+	block := opener == '*'
+	for {
+		c, ok := a.peek()
+		if !ok || (!block && c == '\n') {
+			return text
+		}
+		a.read()
+		text = append(text, c)
+		if block && c == '/' && len(text) >= 4 && text[len(text)-2] == '*' {
+			return text
+		}
+	}
+}
+
+// skip consumes whitespace and comments, reporting whether it passed a
+// newline.
+func (a *ArrayReader) skip() (sawNewline bool, err error) {
+	for {
+		c, ok := a.peek()
+		switch {
+		case !ok:
+			return sawNewline, nil
+		case c == ' ' || c == '\t' || c == '\r':
+			a.read()
+		case c == '\n':
+			a.read()
+			sawNewline = true
+		case c == '/' && a.commentAhead():
+			start := a.here()
+			a.read()
+			text := a.comment([]byte{'/'})
+			if text[1] == '*' && (len(text) < 4 || !bytes.HasSuffix(text, []byte("*/"))) {
+				return sawNewline, &ParseError{Kind: ParseErrorEOF, Line: start.line, Column: start.col, EndLine: a.line, EndColumn: a.col,
+					Position: start.offset, Message: "unterminated block comment"}
+			}
+			if bytes.IndexByte(text, '\n') >= 0 {
+				sawNewline = true
+			}
+		default:
+			return sawNewline, nil
+		}
+	}
+}
+
+// commentAhead reports whether a comment starts at the next byte.
+func (a *ArrayReader) commentAhead() bool {
+	b, _ := a.r.Peek(2)
+	return len(b) == 2 && b[0] == '/' && (b[1] == '/' || b[1] == '*')
+}
+
+func (a *ArrayReader) peek() (byte, bool) {
+	b, err := a.r.Peek(1)
+	if err != nil {
+		if err != io.EOF && a.readErr == nil {
+			a.readErr = err
+		}
+		return 0, false
+	}
+	return b[0], true
+}
+
+// read consumes the next byte, which the caller has peeked, keeping line,
+// col and offset up to date.
+func (a *ArrayReader) read() byte {
+	c, _ := a.r.ReadByte()
+	if c == '\n' {
+		a.line++
+		a.col = 1
+	} else {
+		a.col++
+	}
+	a.offset++
+	return c
+}
+
+func (a *ArrayReader) here() nodePos {
+	return nodePos{offset: a.offset, line: a.line, col: a.col}
+}
+
+func (a *ArrayReader) errHere(msg string) *ParseError {
+	return &ParseError{Kind: ParseErrorSyntax, Line: a.line, Column: a.col, EndLine: a.line, EndColumn: a.col + 1,
+		Position: a.offset, Message: msg}
+}
+
+// ParseReader reads one message from a stream of newline-framed JHON
+// messages and parses it, leaving the rest of the stream in r for the next
+// call. It returns io.EOF when r holds nothing but whitespace and comments.
+//
+// The framing rule: a message ends at the first newline that is outside
+// every string, comment, object and array, so a message may span lines
+// while a brace or bracket is open:
+//
+//	id=1, op="get"
+//	id=2, op="put", body={
+//	  key="k"
+//	}
+//
+// A message that starts with '{' instead ends at its matching '}' and
+// yields that Object (not the one-element array Parse would return for
+// it), so brace-delimited messages need no newline between them. Blank
+// lines between messages are skipped.
+func ParseReader(r *bufio.Reader) (Value, error) {
+	frame, braced, err := readFrame(r)
+	if err != nil {
+		return nil, err
+	}
+	v, err := Parse(string(frame))
+	if err != nil || !braced {
+		return v, err
+	}
+	if arr, ok := v.(Array); ok && len(arr) == 1 {
+		return arr[0], nil
+	}
+	return v, nil
+}
+
+// readFrame scans one message per the ParseReader framing rule, tracking
+// just enough of the lexical structure (strings, raw strings, comments,
+// nesting) to know where it ends.
+func readFrame(r *bufio.Reader) (frame []byte, braced bool, err error) {
+	const (
+		stNormal = iota
+		stString
+		stRaw
+		stLineComment
+		stBlockComment
+	)
+	state := stNormal
+	var quote byte   // stString: the quote character
+	var escaped bool // stString: previous byte was a backslash
+	var hashes int   // stRaw: number of '#' around the raw string
+	var prev byte    // stNormal: previous byte, to tell r"..." from a key
+	depth := 0
+	seen := false // any content outside comments yet
+	for {
+		c, err := r.ReadByte()
+		if err == io.EOF {
+			if !seen {
+				return nil, false, io.EOF
+			}
+			return frame, braced, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		switch state {
+		case stString:
+			frame = append(frame, c)
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == quote:
+				state = stNormal
+			}
+			continue
+		case stRaw:
+			frame = append(frame, c)
+			if c == '"' {
+				if next, _ := r.Peek(hashes); len(next) == hashes && bytes.Count(next, []byte{'#'}) == hashes {
+					frame = append(frame, next...)
+					r.Discard(hashes)
+					state = stNormal
+				}
+			}
+			continue
+		case stLineComment:
+			frame = append(frame, c)
+			if c == '\n' {
+				state = stNormal
+				if depth == 0 && seen {
+					return frame, braced, nil
+				}
+			}
+			continue
+		case stBlockComment:
+			frame = append(frame, c)
+			if c == '/' && prev == '*' {
+				state = stNormal
+				c = ' ' // so "*/" is not read back as a comment opener
+			}
+			prev = c
+			continue
+		}
+
+		// stNormal
+		if c == '\n' && depth == 0 && seen {
+			return frame, braced, nil
+		}
+		if !seen && (c == ' ' || c == '\t' || c == '\r' || c == '\n') {
+			continue // blank lines between messages
+		}
+		frame = append(frame, c)
+		switch c {
+		case '"', '\'':
+			state, quote, escaped = stString, c, false
+		case '/':
+			if next, _ := r.Peek(1); len(next) == 1 && (next[0] == '/' || next[0] == '*') {
+				opener := next[0]
+				frame = append(frame, opener)
+				r.Discard(1)
+				if opener == '/' {
+					state = stLineComment
+				} else {
+					state, prev = stBlockComment, 0
+				}
+				continue
+			}
+		case '{', '[':
+			if !seen && c == '{' {
+				braced = true
+			}
+			depth++
+		case '}', ']':
+			depth--
+			if (braced && depth == 0) || depth < 0 {
+				return frame, braced, nil // a stray closer is left for Parse to report
+			}
+		case 'r', 'R':
+			if !isAsciiAlphanumeric(prev) && prev != '_' && prev != '-' && prev < 0x80 {
+				n := 0
+				for {
+					next, _ := r.Peek(n + 1)
+					if len(next) < n+1 || next[n] != '#' {
+						break
+					}
+					n++
+				}
+				if next, _ := r.Peek(n + 1); len(next) == n+1 && next[n] == '"' {
+					frame = append(frame, next...)
+					r.Discard(n + 1)
+					state, hashes = stRaw, n
+				}
+			}
+		}
+		seen = true
+		prev = c
+	}
+}
+
+// ============================================================================
+// Streaming encode
+// ============================================================================
+
+// An Encoder writes JHON documents to an output stream.
+type Encoder struct {
+	w    io.Writer
+	opts SerializeOptions
+	err  error
+}
+
+// NewEncoder returns an Encoder that writes compact output to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// NewEncoderWithOptions returns an Encoder that writes to w as
+// SerializeWithOptions would with opts.
+func NewEncoderWithOptions(w io.Writer, opts SerializeOptions) *Encoder {
+	return &Encoder{w: w, opts: opts}
+}
+
+// Encode writes v as a document followed by a newline. Compact documents
+// are one line each, so a reader can take them back one at a time with
+// ParseReader.
+//
+// If the underlying io.Writer fails, Encode returns its error, or
+// io.ErrShortWrite for a short write without one, and every later call
+// returns the same error without writing. How much of the failed document
+// reached the stream is undefined, so the stream should not be read as
+// JHON past the last document that encoded successfully.
+func (e *Encoder) Encode(v Value) error {
+	if e.err != nil {
+		return e.err
+	}
+	out := SerializeWithOptions(v, e.opts) + "\n"
+	n, err := io.WriteString(e.w, out)
+	if err == nil && n < len(out) {
+		err = io.ErrShortWrite
+	}
+	e.err = err
+	return err
+}
+
+// ============================================================================
+// Length-prefixed frames
+// ============================================================================
+
+// MaxFrameSize is the largest document, in bytes, that WriteFrame writes
+// and ReadFrame accepts.
+const MaxFrameSize = 16 << 20
+
+// FrameSizeError is returned for a frame longer than MaxFrameSize.
+type FrameSizeError struct {
+	Size uint64
+}
+
+func (e *FrameSizeError) Error() string {
+	return fmt.Sprintf("jhon: frame of %d bytes exceeds MaxFrameSize (%d)", e.Size, MaxFrameSize)
+}
+
+// WriteFrame writes v to w as one frame: the length of its compact
+// serialization as a 4-byte big-endian integer, then the serialization
+// itself. Unlike Encode's newline framing, this lets a binary protocol
+// carry documents of any layout, and a reader find their ends without
+// scanning them. The frame is written with a single Write call; a short
+// write without an error is reported as io.ErrShortWrite.
+func WriteFrame(w io.Writer, v Value) error {
+	text := Serialize(v)
+	if len(text) > MaxFrameSize {
+		return &FrameSizeError{Size: uint64(len(text))}
+	}
+	frame := make([]byte, 4, 4+len(text))
+	binary.BigEndian.PutUint32(frame, uint32(len(text)))
+	frame = append(frame, text...)
+	n, err := w.Write(frame)
+	if err == nil && n < len(frame) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
+
+// ReadFrame reads one frame written by WriteFrame from r and parses it. It
+// returns io.EOF when r ends before the frame starts, io.ErrUnexpectedEOF
+// when it ends inside one, and a *FrameSizeError, before reading the body,
+// for a length over MaxFrameSize. The value is what Parse returns for the
+// text, so the top-level forms round-trip as SPEC §7.1 describes.
+func ReadFrame(r io.Reader) (Value, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	size := binary.BigEndian.Uint32(header[:])
+	if size > MaxFrameSize {
+		return nil, &FrameSizeError{Size: uint64(size)}
+	}
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return Parse(string(body))
+}
@@ -0,0 +1,405 @@
+package jhon
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestDecoderDecode(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`host="x", port=80`))
+	var got testServer
+	if err := dec.Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Host != "x" || got.Port != 80 {
+		t.Fatalf("got %#v", got)
+	}
+	if err := dec.Decode(&got); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestDecoderDisallowUnknownFields(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`hots="x"`))
+	dec.DisallowUnknownFields()
+	var got testServer
+	var ue *UnknownFieldError
+	if err := dec.Decode(&got); !errors.As(err, &ue) {
+		t.Fatalf("expected *UnknownFieldError, got %v", err)
+	}
+}
+
+func TestDecoderReset(t *testing.T) {
+	dec := NewDecoder(strings.NewReader(`host="a", port=1`))
+	dec.DisallowUnknownFields()
+	var first, second testServer
+	if err := dec.Decode(&first); err != nil {
+		t.Fatal(err)
+	}
+	dec.Reset(strings.NewReader(`host="b", port=2`))
+	if err := dec.Decode(&second); err != nil {
+		t.Fatal(err)
+	}
+	if first.Host != "a" || second.Host != "b" || second.Port != 2 {
+		t.Fatalf("got %#v, %#v", first, second)
+	}
+	dec.Reset(strings.NewReader(`hots="c"`))
+	var ue *UnknownFieldError
+	if err := dec.Decode(&second); !errors.As(err, &ue) {
+		t.Fatalf("Reset should keep DisallowUnknownFields, got %v", err)
+	}
+}
+
+func TestArrayReaderYieldsElements(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("{host=\"a\", port=1}\n{host=\"b\", port=2}, {host=\"c\", port=3}\n"))
+	ar, err := dec.ArrayReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var hosts []string
+	for ar.More() {
+		var s testServer
+		if err := ar.Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+		hosts = append(hosts, s.Host)
+	}
+	if !reflect.DeepEqual(hosts, []string{"a", "b", "c"}) {
+		t.Fatalf("got %v", hosts)
+	}
+	var v Value
+	if err := ar.Decode(&v); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestArrayReaderScalarsIntoValue(t *testing.T) {
+	ar, err := NewDecoder(strings.NewReader("1\n\"two\"\n[3]")).ArrayReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Array
+	for ar.More() {
+		var v Value
+		if err := ar.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	want := Array{int64(1), "two", Array{int64(3)}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v want %#v", got, want)
+	}
+}
+
+func TestArrayReaderEmptyDocument(t *testing.T) {
+	ar, err := NewDecoder(strings.NewReader("// nothing\n")).ArrayReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ar.More() {
+		t.Fatal("expected no elements")
+	}
+}
+
+func TestArrayReaderRejectsObjectMode(t *testing.T) {
+	if _, err := NewDecoder(strings.NewReader(`a=1`)).ArrayReader(); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestArrayReaderReportsSeparatorErrorAfterElement(t *testing.T) {
+	ar, err := NewDecoder(strings.NewReader(`1 2`)).ArrayReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v Value
+	if err := ar.Decode(&v); err != nil {
+		t.Fatal(err)
+	}
+	if !ar.More() {
+		t.Fatal("expected the pending error to be reported")
+	}
+	if err := ar.Decode(&v); err == nil {
+		t.Fatal("expected error")
+	}
+}
+
+func TestArrayReaderTypeErrorNamesIndex(t *testing.T) {
+	ar, err := NewDecoder(strings.NewReader("{port=1}\n{port=\"x\"}")).ArrayReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var s testServer
+	if err := ar.Decode(&s); err != nil {
+		t.Fatal(err)
+	}
+	var te *UnmarshalTypeError
+	if err := ar.Decode(&s); !errors.As(err, &te) || te.Path != "[1].port" {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestArrayReaderBracketedArray(t *testing.T) {
+	input := "[\n  {a=1}, // one\n  {a=[2, \"]\"]} /* two */\n  r#\"x\"]\"#,\n]\n// end\n"
+	ar, err := NewDecoder(strings.NewReader(input)).ArrayReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Array
+	for ar.More() {
+		var v Value
+		if err := ar.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+	want := Array{Object{"a": int64(1)}, Object{"a": Array{int64(2), "]"}}, `x"]`}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %#v want %#v", got, want)
+	}
+}
+
+func TestArrayReaderErrors(t *testing.T) {
+	cases := map[string]string{
+		"[1, 2] 3":  "parse error at 1:8: ArrayReader reads a single top-level array, but more follows its closing ']'",
+		"[1, 2":     "unexpected end of input at 1:6: unterminated array opened at 1:1",
+		"1 /* open": "unexpected end of input at 1:3: unterminated block comment",
+		"{a=1}x":    "parse error at 1:6 in [0]: items on the same line must be separated by a comma",
+		"1\n2\nx=3": "parse error at 3:2: cannot mix key=value pairs and bare values at top level",
+	}
+	for input, want := range cases {
+		ar, err := NewDecoder(strings.NewReader(input)).ArrayReader()
+		if err != nil {
+			t.Fatalf("%q: %v", input, err)
+		}
+		for ar.More() {
+			var v Value
+			if err = ar.Decode(&v); err != nil {
+				break
+			}
+		}
+		if err == nil || err.Error() != want {
+			t.Errorf("%q: got %v, want %s", input, err, want)
+		}
+	}
+	if _, err := NewDecoder(strings.NewReader("a\n= 1")).ArrayReader(); err == nil {
+		t.Error("object mode split across lines: expected an error")
+	}
+}
+
+// endlessRecords is an io.Reader of records that never ends.
+type endlessRecords struct{ buf bytes.Buffer }
+
+func (r *endlessRecords) Read(p []byte) (int, error) {
+	if r.buf.Len() == 0 {
+		r.buf.WriteString("{id=1, name=\"record\", tags=[\"a\", \"b\"]}\n")
+	}
+	return r.buf.Read(p)
+}
+
+func TestArrayReaderStreams(t *testing.T) {
+	// The input never ends, so reading it up front would not return.
+	ar, err := NewDecoder(&endlessRecords{}).ArrayReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		var rec struct {
+			ID int `jhon:"id"`
+		}
+		if err := ar.Decode(&rec); err != nil || rec.ID != 1 {
+			t.Fatalf("record %d: got %+v, %v", i, rec, err)
+		}
+	}
+}
+
+func TestParseReaderFrames(t *testing.T) {
+	input := "id=1, op=\"get\"\n\n" +
+		"// a comment line between messages\n" +
+		"id=2, body={\n  key=\"a\\nb // not a comment\"\n  raw=r#\"}\" {\"#\n} // trailing\n" +
+		"{id=3}{id=4}\n" +
+		"tags=[\n  \"x\"\n]\n" +
+		"last=true"
+	r := bufio.NewReader(strings.NewReader(input))
+	want := []Value{
+		Object{"id": int64(1), "op": "get"},
+		Object{"id": int64(2), "body": Object{"key": "a\nb // not a comment", "raw": `}" {`}},
+		Object{"id": int64(3)},
+		Object{"id": int64(4)},
+		Object{"tags": Array{"x"}},
+		Object{"last": true},
+	}
+	for i, w := range want {
+		v, err := ParseReader(r)
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(v, w) {
+			t.Fatalf("message %d: got %#v, want %#v", i, v, w)
+		}
+	}
+	if _, err := ParseReader(r); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestParseReaderLeavesRestUnread(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("a=1\nb=2\n"))
+	if _, err := ParseReader(r); err != nil {
+		t.Fatal(err)
+	}
+	rest, _ := io.ReadAll(r)
+	if string(rest) != "b=2\n" {
+		t.Fatalf("got %q", rest)
+	}
+}
+
+func TestParseReaderErrors(t *testing.T) {
+	for _, input := range []string{"a={\n b=1\n", "a=1}\nb=2", "a=\"open\n"} {
+		if _, err := ParseReader(bufio.NewReader(strings.NewReader(input))); err == nil || err == io.EOF {
+			t.Errorf("%q: expected a parse error, got %v", input, err)
+		}
+	}
+	if _, err := ParseReader(bufio.NewReader(strings.NewReader("  \n/* only */\n"))); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+// failingWriter accepts limit bytes, then fails every write.
+type failingWriter struct {
+	buf   bytes.Buffer
+	limit int
+	calls int
+}
+
+var errWriterFull = errors.New("connection reset")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	w.calls++
+	room := w.limit - w.buf.Len()
+	if room >= len(p) {
+		return w.buf.Write(p)
+	}
+	if room > 0 {
+		w.buf.Write(p[:room])
+	} else {
+		room = 0
+	}
+	return room, errWriterFull
+}
+
+func TestEncoderWritesDocuments(t *testing.T) {
+	var out bytes.Buffer
+	enc := NewEncoder(&out)
+	for _, v := range []Value{Object{"id": int64(1)}, Array{"a", "b"}} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out.String() != "id=1\n\"a\",\"b\"\n" {
+		t.Fatalf("got %q", out.String())
+	}
+	r := bufio.NewReader(&out)
+	if v, err := ParseReader(r); err != nil || !reflect.DeepEqual(v, Object{"id": int64(1)}) {
+		t.Fatalf("read back %#v, %v", v, err)
+	}
+}
+
+func TestEncoderStopsOnWriteError(t *testing.T) {
+	w := &failingWriter{limit: 12}
+	enc := NewEncoderWithOptions(w, SerializeOptions{Indent: "  "})
+	if err := enc.Encode(Object{"a": int64(1)}); err != nil {
+		t.Fatalf("first document fits: %v", err)
+	}
+	if err := enc.Encode(Object{"name": "a long value"}); err != errWriterFull {
+		t.Fatalf("expected the writer's error, got %v", err)
+	}
+	calls := w.calls
+	if err := enc.Encode(Object{"b": int64(2)}); err != errWriterFull {
+		t.Fatalf("error should be sticky, got %v", err)
+	}
+	if w.calls != calls {
+		t.Fatal("Encode wrote after an error")
+	}
+}
+
+type shortWriter struct{}
+
+func (shortWriter) Write(p []byte) (int, error) { return len(p) / 2, nil }
+
+func TestEncoderShortWrite(t *testing.T) {
+	if err := NewEncoder(shortWriter{}).Encode(Object{"a": int64(1)}); err != io.ErrShortWrite {
+		t.Fatalf("got %v", err)
+	}
+}
+
+func TestFrames(t *testing.T) {
+	msgs := []Value{
+		Object{"op": "put", "key": "a", "value": Array{int64(1), "two\nlines"}},
+		Array{Object{"id": int64(1)}, Object{"id": int64(2)}},
+		Object{"op": "ping"},
+	}
+	var buf bytes.Buffer
+	for _, m := range msgs {
+		if err := WriteFrame(&buf, m); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := buf.Bytes()[:4]; !bytes.Equal(got, []byte{0, 0, 0, byte(len(Serialize(msgs[0])))}) {
+		t.Fatalf("header %v", got)
+	}
+	for i, want := range msgs {
+		got, err := ReadFrame(&buf)
+		if err != nil {
+			t.Fatalf("frame %d: %v", i, err)
+		}
+		if !Equal(got, want) {
+			t.Fatalf("frame %d: got %#v want %#v", i, got, want)
+		}
+	}
+	if _, err := ReadFrame(&buf); err != io.EOF {
+		t.Fatalf("after the last frame: got %v, want io.EOF", err)
+	}
+}
+
+func TestReadFrameErrors(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteFrame(&buf, Object{"a": int64(1)}); err != nil {
+		t.Fatal(err)
+	}
+	frame := buf.Bytes()
+	for _, n := range []int{2, len(frame) - 1} {
+		if _, err := ReadFrame(bytes.NewReader(frame[:n])); err != io.ErrUnexpectedEOF {
+			t.Errorf("truncated to %d bytes: got %v, want io.ErrUnexpectedEOF", n, err)
+		}
+	}
+	// Reading one byte at a time is not a short frame.
+	if v, err := ReadFrame(iotest.OneByteReader(bytes.NewReader(frame))); err != nil || !Equal(v, Object{"a": int64(1)}) {
+		t.Errorf("one byte at a time: got %#v, %v", v, err)
+	}
+
+	huge := []byte{0xff, 0xff, 0xff, 0xff}
+	var sizeErr *FrameSizeError
+	if _, err := ReadFrame(bytes.NewReader(huge)); !errors.As(err, &sizeErr) || sizeErr.Size != 0xffffffff {
+		t.Errorf("oversized frame: got %v", err)
+	}
+	if err := WriteFrame(&buf, strings.Repeat("x", MaxFrameSize)); !errors.As(err, &sizeErr) {
+		t.Errorf("oversized value: got %v", err)
+	}
+
+	bad := []byte{0, 0, 0, 3, 'a', '=', '['}
+	var pe *ParseError
+	if _, err := ReadFrame(bytes.NewReader(bad)); !errors.As(err, &pe) {
+		t.Errorf("malformed body: got %v", err)
+	}
+	if err := WriteFrame(shortWriter{}, Object{"a": int64(1)}); err != io.ErrShortWrite {
+		t.Errorf("short write: got %v", err)
+	}
+}
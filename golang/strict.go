@@ -0,0 +1,300 @@
+package jhon
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ============================================================================
+// Strict JSON mode
+//
+// The opposite of the JSON5 mode: ParseStrict accepts RFC 8259 JSON and
+// nothing more, so a service can check that input is pure JSON with the
+// same parser and Value types it uses for JHON. It shares the JHON string
+// scanner, limited to JSON's escapes, and has its own grammar for the rest.
+// ============================================================================
+
+// ParseStrict parses a strict JSON document (RFC 8259) into the same Value
+// types Parse returns, and rejects every JHON extension with an error that
+// names it: comments, trailing commas, '=' between key and value, bare or
+// single-quoted keys and strings, raw strings, \x and \' escapes, line
+// continuations, underscores, leading '+' or zeros and hexadecimal in
+// numbers, and more than one top-level value. Empty input is an error, as
+// JSON requires a value.
+//
+// Integers decode as int64, or uint64 or float64 when they do not fit, and
+// other numbers as float64. A key that repeats takes its last value, as
+// encoding/json does.
+func ParseStrict(input string) (Value, error) {
+	p := newParser([]byte(input))
+	p.strictJSON = true
+	if err := p.skipJSONSpace(); err != nil {
+		return nil, err
+	}
+	v, err := p.parseStrictValue()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.skipJSONSpace(); err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.input) {
+		return nil, p.syntaxErr("unexpected content after JSON value")
+	}
+	return v, nil
+}
+
+// skipJSONSpace skips JSON's four white space characters and rejects
+// comments.
+func (p *parser) skipJSONSpace() error {
+	for {
+		c, ok := p.current()
+		switch {
+		case !ok:
+			return nil
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.advance()
+		case c == '/' && p.pos+1 < len(p.input) && (p.input[p.pos+1] == '/' || p.input[p.pos+1] == '*'):
+			return p.syntaxErr("comments are not allowed in JSON")
+		default:
+			return nil
+		}
+	}
+}
+
+func (p *parser) parseStrictValue() (Value, error) {
+	c, ok := p.current()
+	if !ok {
+		return nil, p.syntaxErr("expected value")
+	}
+	switch {
+	case c == '{':
+		return p.parseStrictObject()
+	case c == '[':
+		return p.parseStrictArray()
+	case c == '"':
+		return p.parseString(c)
+	case c == '\'':
+		return nil, p.syntaxErr("single-quoted strings are not allowed in JSON")
+	case c == '-' || isDigit(c):
+		return p.parseStrictNumber()
+	case c == '+':
+		return nil, p.syntaxErr("a leading '+' is not allowed in JSON numbers")
+	case (c == 'r' || c == 'R') && p.pos+1 < len(p.input) && (p.input[p.pos+1] == '"' || p.input[p.pos+1] == '#'):
+		return nil, p.syntaxErr("raw strings are not allowed in JSON")
+	}
+	for _, lit := range []string{"true", "false", "null"} {
+		if matchesLiteral(p.input, p.pos, lit) && !p.bareCharAt(p.pos+len(lit)) {
+			advanceN(p, len(lit))
+			switch lit {
+			case "true":
+				return true, nil
+			case "false":
+				return false, nil
+			}
+			return nil, nil
+		}
+	}
+	if p.bareCharAt(p.pos) {
+		return nil, p.syntaxErr("unquoted strings are not allowed in JSON")
+	}
+	return nil, p.syntaxErr(fmt.Sprintf("unexpected character %q", c))
+}
+
+// bareCharAt reports whether the byte at i continues a bare word.
+func (p *parser) bareCharAt(i int) bool {
+	if i >= len(p.input) {
+		return false
+	}
+	c := p.input[i]
+	return isAsciiAlphanumeric(c) || c == '_' || c == '-' || c >= 0x80
+}
+
+func (p *parser) parseStrictObject() (Value, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	open := p.here()
+	p.advance() // {
+	obj := Object{}
+	if err := p.skipJSONSpace(); err != nil {
+		return nil, err
+	}
+	if c, ok := p.current(); ok && c == '}' {
+		p.advance()
+		return obj, nil
+	}
+	for {
+		c, ok := p.current()
+		switch {
+		case !ok:
+			return nil, p.unterminatedErr("object", open)
+		case c == '}':
+			return nil, p.syntaxErr("trailing commas are not allowed in JSON")
+		case c == ']':
+			return nil, p.mismatchErr('}', "object", open)
+		case c != '"':
+			return nil, p.syntaxErr("object keys must be double-quoted strings in JSON")
+		}
+		key, err := p.parseString(c)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.skipJSONSpace(); err != nil {
+			return nil, err
+		}
+		if c, ok := p.current(); !ok || c != ':' {
+			if c == '=' {
+				return nil, p.syntaxErr("'=' is not allowed in JSON; use ':'")
+			}
+			return nil, p.syntaxErr(fmt.Sprintf("expected ':' after key %q", key))
+		}
+		p.advance()
+		if err := p.skipJSONSpace(); err != nil {
+			return nil, err
+		}
+		p.pushKey(key)
+		val, err := p.parseStrictValue()
+		p.pop()
+		if err != nil {
+			return nil, err
+		}
+		obj[key] = val
+		if done, err := p.strictItemEnd('}', "object", open); done || err != nil {
+			return obj, err
+		}
+	}
+}
+
+func (p *parser) parseStrictArray() (Value, error) {
+	if err := p.enter(); err != nil {
+		return nil, err
+	}
+	defer p.leave()
+	open := p.here()
+	p.advance() // [
+	arr := Array{}
+	if err := p.skipJSONSpace(); err != nil {
+		return nil, err
+	}
+	if c, ok := p.current(); ok && c == ']' {
+		p.advance()
+		return arr, nil
+	}
+	for {
+		c, ok := p.current()
+		switch {
+		case !ok:
+			return nil, p.unterminatedErr("array", open)
+		case c == ']':
+			return nil, p.syntaxErr("trailing commas are not allowed in JSON")
+		case c == '}':
+			return nil, p.mismatchErr(']', "array", open)
+		}
+		p.pushIndex(len(arr))
+		val, err := p.parseStrictValue()
+		p.pop()
+		if err != nil {
+			return nil, err
+		}
+		arr = append(arr, val)
+		if done, err := p.strictItemEnd(']', "array", open); done || err != nil {
+			return arr, err
+		}
+	}
+}
+
+// strictItemEnd consumes what follows an item: a comma and the space after
+// it, or the closing delimiter, in which case it reports done. A newline is
+// not a separator in JSON.
+func (p *parser) strictItemEnd(closer byte, construct string, open nodePos) (bool, error) {
+	if err := p.skipJSONSpace(); err != nil {
+		return false, err
+	}
+	c, ok := p.current()
+	switch {
+	case !ok:
+		return false, p.unterminatedErr(construct, open)
+	case c == ',':
+		p.advance()
+		return false, p.skipJSONSpace()
+	case c == closer:
+		p.advance()
+		return true, nil
+	case c == '}' || c == ']':
+		return false, p.mismatchErr(closer, construct, open)
+	}
+	return false, p.syntaxErr(fmt.Sprintf("expected ',' or '%c' after %s item", closer, construct))
+}
+
+// parseStrictNumber parses a JSON number:
+// -?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?
+func (p *parser) parseStrictNumber() (Value, error) {
+	start := p.pos
+	startPos := p.here()
+	bad := func(why string) error {
+		for p.bareCharAt(p.pos) || (p.pos < len(p.input) && (p.input[p.pos] == '.' || p.input[p.pos] == '+')) {
+			p.advance()
+		}
+		return p.errAt(startPos, fmt.Sprintf("invalid number %q: %s", string(p.input[start:p.pos]), why))
+	}
+	digits := func() int {
+		n := 0
+		for c, ok := p.current(); ok && isDigit(c); c, ok = p.current() {
+			p.advance()
+			n++
+		}
+		return n
+	}
+	neg := false
+	if c, _ := p.current(); c == '-' {
+		neg = true
+		p.advance()
+	}
+	intStart := p.pos
+	n := digits()
+	switch {
+	case n == 0:
+		return nil, bad("expected a digit")
+	case n > 1 && p.input[intStart] == '0':
+		return nil, bad("leading zeros are not allowed in JSON")
+	case n == 1 && p.input[intStart] == '0' && p.pos < len(p.input) && (p.input[p.pos] == 'x' || p.input[p.pos] == 'X'):
+		return nil, bad("hexadecimal numbers are not allowed in JSON")
+	}
+	isFloat := false
+	if c, _ := p.current(); c == '.' {
+		p.advance()
+		isFloat = true
+		if digits() == 0 {
+			return nil, bad("expected a digit after '.'")
+		}
+	}
+	if c, _ := p.current(); c == 'e' || c == 'E' {
+		p.advance()
+		isFloat = true
+		if c, _ := p.current(); c == '+' || c == '-' {
+			p.advance()
+		}
+		if digits() == 0 {
+			return nil, bad("expected a digit in the exponent")
+		}
+	}
+	if c, ok := p.current(); ok && c == '_' {
+		return nil, bad("underscores are not allowed in JSON numbers")
+	}
+	if p.bareCharAt(p.pos) || (p.pos < len(p.input) && p.input[p.pos] == '.') {
+		return nil, bad("unexpected character after number")
+	}
+	text := string(p.input[start:p.pos])
+	if !isFloat {
+		if u, err := strconv.ParseUint(string(p.input[intStart:p.pos]), 10, 64); err == nil {
+			return signedInteger(u, neg), nil
+		}
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil && !isRangeErr(err) {
+		return nil, bad(err.Error())
+	}
+	return f, nil
+}
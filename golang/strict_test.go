@@ -0,0 +1,99 @@
+package jhon
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseStrictAcceptsJSON(t *testing.T) {
+	input := `{
+  "name": "app\t\"x\"é\/",
+  "port": 8080,
+  "big": 18446744073709551615,
+  "ratio": -0.5e-3,
+  "tags": ["a", "b"],
+  "empty": {},
+  "none": [],
+  "flags": [true, false, null],
+  "name": "last wins"
+}`
+	v, err := ParseStrict(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Object{
+		"name":  "last wins",
+		"port":  int64(8080),
+		"big":   uint64(18446744073709551615),
+		"ratio": -0.0005,
+		"tags":  Array{"a", "b"},
+		"empty": Object{},
+		"none":  Array{},
+		"flags": Array{true, false, nil},
+	}
+	if !reflect.DeepEqual(v, want) {
+		t.Fatalf("got %#v\nwant %#v", v, want)
+	}
+	for input, want := range map[string]Value{`"s"`: "s", ` 1 `: int64(1), `-0`: int64(0), `[{"a":1}]`: Array{Object{"a": int64(1)}}, `"\ud83d\ude00"`: "😀"} {
+		if v, err := ParseStrict(input); err != nil || !reflect.DeepEqual(v, want) {
+			t.Errorf("%s: got %#v, %v", input, v, err)
+		}
+	}
+}
+
+func TestParseStrictRejectsExtensions(t *testing.T) {
+	cases := []struct {
+		name, input, msg string
+	}{
+		{"line comment", "{\"a\": 1 // note\n}", "comments"},
+		{"block comment", `/* c */ {"a": 1}`, "comments"},
+		{"trailing comma in object", `{"a": 1,}`, "trailing commas"},
+		{"trailing comma in array", `[1, 2,]`, "trailing commas"},
+		{"equals", `{"a" = 1}`, "'='"},
+		{"bare key", `{a: 1}`, "double-quoted"},
+		{"single-quoted key", `{'a': 1}`, "double-quoted"},
+		{"single-quoted string", `{"a": 'x'}`, "single-quoted"},
+		{"raw string", `{"a": r"x"}`, "raw strings"},
+		{"hashed raw string", `[r#"x"#]`, "raw strings"},
+		{"bare string", `{"a": hello}`, "unquoted"},
+		{"hex escape", `"\x41"`, `\x`},
+		{"quote escape", `"\'"`, `\'`},
+		{"line continuation", "\"a\\\nb\"", "line continuations"},
+		{"underscore", `1_000`, "underscores"},
+		{"leading plus", `+1`, "'+'"},
+		{"leading zero", `007`, "leading zeros"},
+		{"hexadecimal", `0xFF`, "hexadecimal"},
+		{"leading point", `.5`, "unexpected character"},
+		{"trailing point", `5.`, "after '.'"},
+		{"newline separator", "[1\n2]", "expected ','"},
+		{"top-level key=value", `a=1`, "unquoted"},
+		{"several values", `{} {}`, "after JSON value"},
+		{"empty", ``, "expected value"},
+		{"infinity", `Infinity`, "unquoted"},
+	}
+	for _, c := range cases {
+		_, err := ParseStrict(c.input)
+		if err == nil {
+			t.Errorf("%s: %q parsed", c.name, c.input)
+			continue
+		}
+		if !strings.Contains(err.Error(), c.msg) {
+			t.Errorf("%s: error %q does not mention %q", c.name, err, c.msg)
+		}
+		if _, ok := err.(*ParseError); !ok {
+			t.Errorf("%s: got %T, want *ParseError", c.name, err)
+		}
+	}
+}
+
+func TestParseStrictErrorPosition(t *testing.T) {
+	_, err := ParseStrict("{\n  \"a\": [1, 2,]\n}")
+	pe, ok := err.(*ParseError)
+	if !ok {
+		t.Fatalf("got %v", err)
+	}
+	if pe.Line != 2 || pe.Column != 14 || pe.Path != "a" {
+		t.Fatalf("got %d:%d in %q", pe.Line, pe.Column, pe.Path)
+	}
+}
@@ -0,0 +1,52 @@
+package jhon
+
+import "text/template"
+
+// ============================================================================
+// Template helpers
+// ============================================================================
+
+// FuncMap returns functions for rendering Values in text/template, so a
+// template can embed parts of a config without Go glue:
+//
+//	jhon        the value as a compact document
+//	jhonPretty  the value as a document pretty-printed with two-space indents
+//	get         the value at a path such as "server.ports.0", or nil
+//
+// As with Serialize, a document leaves out the braces or brackets of a
+// top-level object or array. get takes the path first, so it also
+// works at the end of a pipeline:
+//
+//	tmpl := template.New("page").Funcs(jhon.FuncMap())
+//	// {{ get "server.host" . }}, {{ . | get "server" | jhonPretty }}
+//
+// Values may also be the plain maps and slices encoding/json produces. For
+// html/template, convert the map: template.FuncMap(jhon.FuncMap()).
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"jhon": func(v Value) string {
+			return Serialize(v)
+		},
+		"jhonPretty": func(v Value) string {
+			return SerializeWithOptions(v, SerializeOptions{Indent: "  "})
+		},
+		"get": templateGet,
+	}
+}
+
+// templateGet is the template function get: Object.Path for any Value.
+func templateGet(path string, v Value) Value {
+	v, _ = normalizeValue(v)
+	segs, ok := splitQueryPath(path)
+	if !ok {
+		return nil
+	}
+	for _, seg := range segs {
+		next, ok := childAt(v, seg.key)
+		if !ok {
+			return nil
+		}
+		v = next
+	}
+	return v
+}
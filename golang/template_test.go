@@ -0,0 +1,46 @@
+package jhon
+
+import (
+	htmltemplate "html/template"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestFuncMap(t *testing.T) {
+	config := MustParse(`server = { host = "localhost", ports = [80, 443] }, limits = { conns = [10, 20] }`)
+	tmpl := template.Must(template.New("page").Funcs(FuncMap()).Parse(
+		`host={{ get "server.host" . }}
+port={{ get "server.ports.1" . }}
+missing={{ get "server.nope" . | jhon }}
+ports={{ . | get "server.ports" | jhon }}
+{{ get "limits" . | jhonPretty }}`))
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, config); err != nil {
+		t.Fatal(err)
+	}
+	want := `host=localhost
+port=443
+missing=
+ports=80,443
+conns = [
+  10
+  20
+]`
+	if got := sb.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFuncMapWithPlainMapsAndHTML(t *testing.T) {
+	data := map[string]interface{}{"tags": []interface{}{"a", "<b>"}}
+	tmpl := htmltemplate.Must(htmltemplate.New("page").Funcs(htmltemplate.FuncMap(FuncMap())).Parse(
+		`<pre>{{ get "tags.1" . }} {{ jhon . }}</pre>`))
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		t.Fatal(err)
+	}
+	if want := `<pre>&lt;b&gt; tags=[&#34;a&#34;,&#34;&lt;b&gt;&#34;]</pre>`; sb.String() != want {
+		t.Fatalf("got %s want %s", sb.String(), want)
+	}
+}
@@ -0,0 +1,96 @@
+package jhon
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ============================================================================
+// Grammar version — SpecVersion and the `// jhon: v2.1` header
+// ============================================================================
+
+// SpecVersion is the version of the JHON grammar (SPEC.md) this package
+// reads and writes.
+const SpecVersion = "2.1"
+
+// versionHeaderPrefix starts the header comment that names the grammar a
+// document is written for.
+const versionHeaderPrefix = "// jhon: v"
+
+// HeaderVersion returns the grammar version named by the header comment on
+// the first line of input, `// jhon: v2.1` as SerializeOptions.VersionHeader
+// writes it, and whether there is one. A bare major version (`v2`) is
+// returned as written.
+func HeaderVersion(input string) (string, bool) {
+	line := input
+	if i := strings.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, versionHeaderPrefix) {
+		return "", false
+	}
+	version := line[len(versionHeaderPrefix):]
+	if _, _, ok := splitVersion(version); !ok {
+		return "", false
+	}
+	return version, true
+}
+
+// splitVersion splits a version written `2` or `2.1` into its major and
+// minor numbers; a missing minor is 0.
+func splitVersion(version string) (major, minor int, ok bool) {
+	majorText, minorText, hasMinor := strings.Cut(version, ".")
+	if !hasMinor {
+		minorText = "0"
+	}
+	for _, part := range []string{majorText, minorText} {
+		if part == "" || len(part) > 9 {
+			return 0, 0, false
+		}
+		for i := 0; i < len(part); i++ {
+			if !isDigit(part[i]) {
+				return 0, 0, false
+			}
+		}
+	}
+	major, _ = strconv.Atoi(majorText)
+	minor, _ = strconv.Atoi(minorText)
+	return major, minor, true
+}
+
+// checkVersion rejects input whose header names a grammar this package
+// cannot read, for ParseOptions.CheckVersion: another major version, or a
+// later minor one.
+func (p *parser) checkVersion() error {
+	line := p.input
+	if i := bytes.IndexByte(line, '\n'); i >= 0 {
+		line = line[:i]
+	}
+	version, ok := HeaderVersion(string(line))
+	if !ok {
+		return nil
+	}
+	major, minor, _ := splitVersion(version)
+	ownMajor, ownMinor, _ := splitVersion(SpecVersion)
+	if major == ownMajor && minor <= ownMinor {
+		return nil
+	}
+	start := bytes.Index(line, []byte(versionHeaderPrefix))
+	return p.errAt(nodePos{offset: start, line: 1, col: start + 1}, fmt.Sprintf("document is written for JHON v%s, but this parser reads v%s", version, SpecVersion))
+}
+
+// withVersionHeader puts the header naming SpecVersion on the first line of
+// text, in place of any header text already has.
+func withVersionHeader(text string) string {
+	if _, ok := HeaderVersion(text); ok {
+		if i := strings.IndexByte(text, '\n'); i >= 0 {
+			text = text[i+1:]
+		} else {
+			text = ""
+		}
+	}
+	return versionHeaderPrefix + SpecVersion + "\n" + text
+}
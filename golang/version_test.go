@@ -0,0 +1,74 @@
+package jhon
+
+import "testing"
+
+func TestVersionHeader(t *testing.T) {
+	header := "// jhon: v" + SpecVersion + "\n"
+	v := Object{"a": int64(1), "b": Array{true}}
+	got := SerializeWithOptions(v, SerializeOptions{VersionHeader: true})
+	if want := header + "a=1,b=[true]"; got != want {
+		t.Fatalf("got %q want %q", got, want)
+	}
+	if version, ok := HeaderVersion(got); !ok || version != SpecVersion {
+		t.Fatalf("HeaderVersion: got %q, %v", version, ok)
+	}
+	back, err := ParseWithOptions(got, ParseOptions{CheckVersion: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(back, v) {
+		t.Fatalf("parses back as %#v", back)
+	}
+
+	// Format replaces an old header rather than stacking a second one.
+	formatted, err := Format("// jhon: v2.0\n// port\nport = 80", SerializeOptions{VersionHeader: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := header + "// port\nport = 80\n"; formatted != want {
+		t.Fatalf("Format: got %q want %q", formatted, want)
+	}
+}
+
+func TestHeaderVersionForms(t *testing.T) {
+	cases := map[string]string{
+		"// jhon: v2.1\na=1":   "2.1",
+		"  // jhon: v2  \r\n":  "2",
+		"// jhon: v10.42":      "10.42",
+		"a=1 // jhon: v2.1":    "",
+		"\n// jhon: v2.1":      "",
+		"// jhon: v2.x":        "",
+		"// jhon: v+2":         "",
+		"// jhon: version 2.1": "",
+		"":                     "",
+	}
+	for input, want := range cases {
+		got, ok := HeaderVersion(input)
+		if got != want || ok != (want != "") {
+			t.Errorf("%q: got %q, %v, want %q", input, got, ok, want)
+		}
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	for _, input := range []string{"a=1", "// jhon: v2\na=1", "// jhon: v2.0\na=1", "// jhon: v" + SpecVersion + "\na=1"} {
+		if _, err := ParseWithOptions(input, ParseOptions{CheckVersion: true}); err != nil {
+			t.Errorf("%q: %v", input, err)
+		}
+	}
+	for _, input := range []string{"// jhon: v2.99\na=1", "// jhon: v3.0\na=1", "// jhon: v1\na=1"} {
+		if _, err := Parse(input); err != nil {
+			t.Errorf("%q without CheckVersion: %v", input, err)
+		}
+		_, err := ParseWithOptions(input, ParseOptions{CheckVersion: true})
+		pe, ok := err.(*ParseError)
+		if !ok {
+			t.Fatalf("%q: expected *ParseError, got %v", input, err)
+		}
+		version, _ := HeaderVersion(input)
+		want := "document is written for JHON v" + version + ", but this parser reads v" + SpecVersion
+		if pe.Message != want || pe.Line != 1 || pe.Column != 1 {
+			t.Errorf("%q: got %q at %d:%d", input, pe.Message, pe.Line, pe.Column)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package jhon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ============================================================================
+// Writer — emits a document token by token, without building a Value tree.
+// ============================================================================
+
+// A Writer emits JHON to an io.Writer one token at a time:
+//
+//	w := jhon.NewWriter(out)
+//	w.BeginObject()
+//	w.Key("name")
+//	w.String("x")
+//	w.EndObject()
+//	err := w.Close()
+//
+// The outermost object or array is the document itself, so it is written
+// without braces (SPEC §2), exactly as Serialize writes an Object or Array;
+// containers begun inside it keep theirs. Output is byte-for-byte what
+// SerializeWithOptions would produce for the same tree with keys in call
+// order and MaxInlineWidth 0.
+//
+// Misuse — a value in an object without a Key, a Key outside an object, an
+// End that does not match its Begin, or anything after the document is
+// complete — returns an error. Errors are sticky: once a call fails, every
+// later call returns the same error.
+type Writer struct {
+	w      io.Writer
+	opts   SerializeOptions
+	stack  []writerFrame
+	done   bool
+	err    error
+	buf    strings.Builder
+	pretty bool
+}
+
+// writerFrame is one open container.
+type writerFrame struct {
+	object bool
+	root   bool // the document itself: written without delimiters
+	depth  int  // nesting depth passed to the pretty printer
+	count  int  // entries written so far
+	keyed  bool // object only: Key written, value pending
+}
+
+// NewWriter returns a Writer that emits compact output to w.
+func NewWriter(w io.Writer) *Writer {
+	return NewWriterWithOptions(w, SerializeOptions{})
+}
+
+// NewWriterWithOptions returns a Writer that emits to w, pretty-printed when
+// opts selects an indent. Of the key and array ordering options only those
+// affecting subtrees passed to Value apply; keys written with Key keep their
+// call order.
+func NewWriterWithOptions(w io.Writer, opts SerializeOptions) *Writer {
+	opts = resolveIndent(opts)
+	return &Writer{w: w, opts: opts, pretty: opts.Indent != ""}
+}
+
+// BeginObject opens an object.
+func (w *Writer) BeginObject() error { return w.begin(true) }
+
+// BeginArray opens an array.
+func (w *Writer) BeginArray() error { return w.begin(false) }
+
+// EndObject closes the innermost container, which must be an object.
+func (w *Writer) EndObject() error { return w.end(true) }
+
+// EndArray closes the innermost container, which must be an array.
+func (w *Writer) EndArray() error { return w.end(false) }
+
+// Key writes an object key. The next call must write its value.
+func (w *Writer) Key(key string) error {
+	if w.err != nil {
+		return w.err
+	}
+	f := w.top()
+	if f == nil || !f.object {
+		return w.fail("jhon: Writer: Key outside an object")
+	}
+	if f.keyed {
+		return w.fail(fmt.Sprintf("jhon: Writer: Key %q follows a key with no value", key))
+	}
+	w.separate(f)
+	serializeKey(key, w.opts, &w.buf)
+	if w.pretty {
+		w.buf.WriteString(" = ")
+	} else {
+		w.buf.WriteByte('=')
+	}
+	f.keyed = true
+	return w.flush()
+}
+
+// String writes a string value.
+func (w *Writer) String(s string) error { return w.Value(s) }
+
+// Int writes an integer value.
+func (w *Writer) Int(i int64) error { return w.Value(i) }
+
+// Uint writes an unsigned integer value.
+func (w *Writer) Uint(u uint64) error { return w.Value(u) }
+
+// Float writes a floating-point value.
+func (w *Writer) Float(f float64) error { return w.Value(f) }
+
+// Bool writes a boolean value.
+func (w *Writer) Bool(b bool) error { return w.Value(b) }
+
+// Null writes null.
+func (w *Writer) Null() error { return w.Value(nil) }
+
+// Value writes any Value, including a whole Object or Array subtree, as one
+// value. At the document root it writes the complete document.
+func (w *Writer) Value(v Value) error {
+	if w.err != nil {
+		return w.err
+	}
+	v, _ = normalizeValue(v)
+	if w.opts.SortArrays {
+		v = sortArrays(v)
+	}
+	f, err := w.beforeValue()
+	if err != nil {
+		return err
+	}
+	switch {
+	case f == nil:
+		if w.pretty {
+			serializeTopPrettyInline(v, w.opts, &w.buf)
+		} else {
+			serializeTopCompact(v, w.opts, &w.buf)
+		}
+		w.done = true
+	case w.pretty:
+		renderPrettyInline(v, w.opts, w.childDepth(f), &w.buf)
+	default:
+		if obj, ok := v.(Object); ok {
+			w.buf.WriteByte('{')
+			serializeObjectCompact(obj, w.opts, &w.buf)
+			w.buf.WriteByte('}')
+		} else {
+			serializeCompact(v, w.opts, &w.buf)
+		}
+	}
+	return w.flush()
+}
+
+// Close reports an error if any container is still open. It does not close
+// the underlying io.Writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if f := w.top(); f != nil {
+		if f.object {
+			return w.fail("jhon: Writer: unclosed object")
+		}
+		return w.fail("jhon: Writer: unclosed array")
+	}
+	return nil
+}
+
+func (w *Writer) begin(object bool) error {
+	if w.err != nil {
+		return w.err
+	}
+	f, err := w.beforeValue()
+	if err != nil {
+		return err
+	}
+	if f == nil {
+		w.stack = append(w.stack, writerFrame{object: object, root: true})
+		return nil
+	}
+	if object {
+		w.buf.WriteByte('{')
+	} else {
+		w.buf.WriteByte('[')
+	}
+	w.stack = append(w.stack, writerFrame{object: object, depth: w.childDepth(f)})
+	return w.flush()
+}
+
+func (w *Writer) end(object bool) error {
+	if w.err != nil {
+		return w.err
+	}
+	f := w.top()
+	switch {
+	case f == nil:
+		return w.fail("jhon: Writer: End with nothing open")
+	case f.object != object && f.object:
+		return w.fail("jhon: Writer: EndArray closes an object")
+	case f.object != object:
+		return w.fail("jhon: Writer: EndObject closes an array")
+	case f.keyed:
+		return w.fail("jhon: Writer: object ends after a key with no value")
+	}
+	w.stack = w.stack[:len(w.stack)-1]
+	if f.root {
+		w.done = true
+		return nil
+	}
+	if w.pretty && f.count > 0 {
+		if w.opts.TrailingComma {
+			w.buf.WriteByte(',')
+		}
+		w.buf.WriteByte('\n')
+		writeIndent(&w.buf, w.opts.Indent, f.depth)
+	}
+	if object {
+		w.buf.WriteByte('}')
+	} else {
+		w.buf.WriteByte(']')
+	}
+	return w.flush()
+}
+
+// beforeValue checks that a value may be written now and writes the
+// separator in front of it. It returns the enclosing frame, or nil for the
+// document root.
+func (w *Writer) beforeValue() (*writerFrame, error) {
+	if w.done {
+		return nil, w.fail("jhon: Writer: document is already complete")
+	}
+	f := w.top()
+	if f == nil {
+		return nil, nil
+	}
+	if f.object {
+		if !f.keyed {
+			return nil, w.fail("jhon: Writer: value in an object without a Key")
+		}
+		f.keyed = false
+		return f, nil
+	}
+	w.separate(f)
+	return f, nil
+}
+
+// separate writes what goes before the next entry of f.
+func (w *Writer) separate(f *writerFrame) {
+	switch {
+	case w.pretty && !f.root:
+		w.buf.WriteByte('\n')
+		writeIndent(&w.buf, w.opts.Indent, f.depth+1)
+	case f.count == 0:
+	case w.pretty:
+		w.buf.WriteByte('\n')
+	default:
+		w.buf.WriteByte(',')
+	}
+	f.count++
+}
+
+// childDepth is the pretty-printer depth of a value written inside f.
+func (w *Writer) childDepth(f *writerFrame) int {
+	if f.root {
+		return 0
+	}
+	return f.depth + 1
+}
+
+func (w *Writer) top() *writerFrame {
+	if len(w.stack) == 0 {
+		return nil
+	}
+	return &w.stack[len(w.stack)-1]
+}
+
+func (w *Writer) flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := io.WriteString(w.w, w.buf.String())
+	w.buf.Reset()
+	if err != nil {
+		w.err = err
+	}
+	return err
+}
+
+func (w *Writer) fail(msg string) error {
+	w.err = errors.New(msg)
+	return w.err
+}
@@ -0,0 +1,116 @@
+package jhon
+
+import (
+	"strings"
+	"testing"
+)
+
+// writeSample writes the same document as sampleTree, keys in sorted order.
+func writeSample(w *Writer) {
+	w.BeginObject()
+	w.Key("name")
+	w.String("app")
+	w.Key("ports")
+	w.BeginArray()
+	w.Int(80)
+	w.BeginObject()
+	w.Key("tls")
+	w.Bool(true)
+	w.EndObject()
+	w.BeginArray()
+	w.EndArray()
+	w.EndArray()
+	w.Key("server")
+	w.BeginObject()
+	w.Key("host")
+	w.Null()
+	w.Key("limits")
+	w.Value(Object{"rate": 0.5})
+	w.EndObject()
+	w.EndObject()
+}
+
+var sampleTree = Object{
+	"name":   "app",
+	"ports":  Array{int64(80), Object{"tls": true}, Array{}},
+	"server": Object{"host": nil, "limits": Object{"rate": 0.5}},
+}
+
+func TestWriterMatchesSerialize(t *testing.T) {
+	for _, opts := range []SerializeOptions{
+		{},
+		{Indent: "  "},
+		{UseTabs: true, TrailingComma: true},
+	} {
+		var sb strings.Builder
+		w := NewWriterWithOptions(&sb, opts)
+		writeSample(w)
+		if err := w.Close(); err != nil {
+			t.Fatalf("%+v: unexpected error: %v", opts, err)
+		}
+		opts.SortKeys = true
+		if want := SerializeWithOptions(sampleTree, opts); sb.String() != want {
+			t.Errorf("%+v:\ngot:\n%s\nwant:\n%s", opts, sb.String(), want)
+		}
+	}
+}
+
+func TestWriterTopLevelArray(t *testing.T) {
+	var sb strings.Builder
+	w := NewWriter(&sb)
+	w.BeginArray()
+	w.String("a")
+	w.BeginObject()
+	w.EndObject()
+	w.Float(1.5)
+	if err := w.EndArray(); err != nil {
+		t.Fatal(err)
+	}
+	if got := sb.String(); got != `"a",{},1.5` {
+		t.Fatalf("got %q", got)
+	}
+}
+
+func TestWriterMisuse(t *testing.T) {
+	cases := map[string]func(w *Writer) error{
+		"value without key": func(w *Writer) error {
+			w.BeginObject()
+			return w.Int(1)
+		},
+		"key in array": func(w *Writer) error {
+			w.BeginArray()
+			return w.Key("a")
+		},
+		"two keys": func(w *Writer) error {
+			w.BeginObject()
+			w.Key("a")
+			return w.Key("b")
+		},
+		"mismatched end": func(w *Writer) error {
+			w.BeginObject()
+			return w.EndArray()
+		},
+		"end with nothing open": func(w *Writer) error {
+			return w.EndObject()
+		},
+		"after completion": func(w *Writer) error {
+			w.Int(1)
+			return w.Int(2)
+		},
+		"unclosed": func(w *Writer) error {
+			w.BeginArray()
+			return w.Close()
+		},
+	}
+	for name, fn := range cases {
+		w := NewWriter(&strings.Builder{})
+		err := fn(w)
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+			continue
+		}
+		if again := w.Int(3); again != err {
+			t.Errorf("%s: error is not sticky: %v then %v", name, err, again)
+		}
+	}
+}
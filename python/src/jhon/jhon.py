@@ -379,9 +379,7 @@ class Parser:
             elif esc == "u":
                 v = self._parse_hex_digits(4, "\\u")
                 if 0xD800 <= v <= 0xDFFF:
-                    raise self._syntax_err(
-                        f"surrogate code point U+{v:04X} requires a pair; surrogate handling is not yet implemented"
-                    )
+                    v = self._parse_surrogate_pair(v)
                 out.append(chr(v))
             else:
                 raise self._syntax_err(f"unknown escape \\{esc}")
@@ -396,6 +394,23 @@ class Parser:
             self._advance()
         return v
 
+    def _parse_surrogate_pair(self, hi: int) -> int:
+        """Complete a \\u escape whose value is a UTF-16 surrogate, as JSON does."""
+        if hi >= 0xDC00:
+            raise self._syntax_err(f"unpaired low surrogate U+{hi:04X}")
+        if not self.input.startswith("\\u", self.pos):
+            raise self._syntax_err(
+                f"high surrogate U+{hi:04X} must be followed by a \\u low surrogate"
+            )
+        self._advance()
+        self._advance()
+        lo = self._parse_hex_digits(4, "\\u")
+        if not 0xDC00 <= lo <= 0xDFFF:
+            raise self._syntax_err(
+                f"high surrogate U+{hi:04X} must be followed by a \\u low surrogate, not U+{lo:04X}"
+            )
+        return 0x10000 + ((hi - 0xD800) << 10) + (lo - 0xDC00)
+
     def _parse_raw_string(self) -> str:
         self._advance()  # r or R
         hash_count = 0
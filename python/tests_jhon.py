@@ -188,6 +188,17 @@ def test_string_escape_unicode():
     assert parse('copy="©"') == {"copy": "©"}
 
 
+def test_string_escape_hex_is_code_point():
+    assert parse(r'key="\xe9\x41"') == {"key": "éA"}
+
+
+def test_string_escape_surrogate_pair():
+    assert parse(r'key="\ud83d\ude00"') == {"key": "😀"}
+    for bad in (r'key="\ude00"', r'key="\ud83d"', r'key="\ud83d\u0041"'):
+        with pytest.raises(JhonParseError):
+            parse(bad)
+
+
 def test_string_escape_quote_and_backslash():
     assert parse(r'q="say \"hi\"",bs="a\\b"') == {"q": 'say "hi"', "bs": "a\\b"}
 
@@ -651,17 +651,16 @@ impl<'a> Parser<'a> {
                     b'\'' => bytes.push(b'\''),
                     b'/' => bytes.push(b'/'),
                     b'x' => {
+                        // `\xXX` names a code point (U+0000..U+00FF), not a raw byte.
                         let v = self.parse_hex_digits(2, "\\x")?;
-                        bytes.push(v as u8);
+                        let c = char::from_u32(v).expect("two hex digits are always a valid char");
+                        let mut buf = [0u8; 4];
+                        bytes.extend_from_slice(c.encode_utf8(&mut buf).as_bytes());
                     }
                     b'u' => {
-                        let code = self.parse_hex_digits(4, "\\u")?;
+                        let mut code = self.parse_hex_digits(4, "\\u")?;
                         if (0xD800..=0xDFFF).contains(&code) {
-                            return Err(syntax_err!(
-                                "surrogate code point U+{:04X} requires a pair; \
-                                 surrogate handling is not yet implemented",
-                                code
-                            ));
+                            code = self.parse_surrogate_pair(code)?;
                         }
                         let c = char::from_u32(code).ok_or_else(|| {
                             syntax_err!("Invalid Unicode code point U+{:04X}", code)
@@ -680,6 +679,32 @@ impl<'a> Parser<'a> {
         Err(syntax_err!("Unterminated string"))
     }
 
+    /// Complete a `\u` escape whose value `hi` is a UTF-16 surrogate: it must
+    /// be a high surrogate followed directly by a `\u` low surrogate, as in
+    /// JSON. Returns the code point the pair encodes.
+    fn parse_surrogate_pair(&mut self, hi: u32) -> Result<u32> {
+        if hi >= 0xDC00 {
+            return Err(syntax_err!("Unpaired low surrogate U+{:04X}", hi));
+        }
+        if !self.input[self.pos..].starts_with(b"\\u") {
+            return Err(syntax_err!(
+                "High surrogate U+{:04X} must be followed by a \\u low surrogate",
+                hi
+            ));
+        }
+        self.advance();
+        self.advance();
+        let lo = self.parse_hex_digits(4, "\\u")?;
+        if !(0xDC00..=0xDFFF).contains(&lo) {
+            return Err(syntax_err!(
+                "High surrogate U+{:04X} must be followed by a \\u low surrogate, not U+{:04X}",
+                hi,
+                lo
+            ));
+        }
+        Ok(0x10000 + ((hi - 0xD800) << 10) + (lo - 0xDC00))
+    }
+
     /// Parse `count` hex digits and return the assembled value.
     fn parse_hex_digits(&mut self, count: usize, label: &str) -> Result<u32> {
         let mut value = 0u32;
@@ -1997,6 +2022,22 @@ mod tests {
         assert_eq!(parse(r#"key="é""#).unwrap(), json!({"key": "é"}));
     }
 
+    #[test]
+    fn string_escape_hex_is_code_point() {
+        assert_eq!(parse(r#"key="\xe9\x41""#).unwrap(), json!({"key": "éA"}));
+    }
+
+    #[test]
+    fn string_escape_surrogate_pair() {
+        assert_eq!(
+            parse(r#"key="\ud83d\ude00""#).unwrap(),
+            json!({"key": "😀"})
+        );
+        assert!(parse(r#"key="\ude00""#).is_err());
+        assert!(parse(r#"key="\ud83d""#).is_err());
+        assert!(parse(r#"key="\ud83d\u0041""#).is_err());
+    }
+
     #[test]
     fn string_escape_quote_and_backslash() {
         assert_eq!(
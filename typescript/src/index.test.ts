@@ -146,6 +146,15 @@ describe('Rust parity: §3.4 strings', () => {
   test('string escape unicode', () => {
     expect(parse(`copy="\\u00A9"`)).toEqual({ copy: '©' });
   });
+  test('string escape hex is code point', () => {
+    expect(parse(`key="\\xe9\\x41"`)).toEqual({ key: 'éA' });
+  });
+  test('string escape surrogate pair', () => {
+    expect(parse(`key="\\ud83d\\ude00"`)).toEqual({ key: '😀' });
+    expect(() => parse(`key="\\ude00"`)).toThrow(JhonParseError);
+    expect(() => parse(`key="\\ud83d"`)).toThrow(JhonParseError);
+    expect(() => parse(`key="\\ud83d\\u0041"`)).toThrow(JhonParseError);
+  });
   test('string escape quote and backslash', () => {
     expect(parse(`q="say \\"hi\\"",bs="a\\\\b"`)).toEqual({
       q: 'say "hi"',
@@ -325,11 +325,9 @@ export class Parser {
             break;
           }
           case 'u': {
-            const codePoint = this.parseHexDigits(4, '\\u');
+            let codePoint = this.parseHexDigits(4, '\\u');
             if (codePoint >= 0xd800 && codePoint <= 0xdfff) {
-              throw this.syntaxErr(
-                `surrogate code point U+${codePoint.toString(16).padStart(4, '0').toUpperCase()} requires a pair; surrogate handling is not yet implemented`
-              );
+              codePoint = this.parseSurrogatePair(codePoint);
             }
             chars.push(String.fromCodePoint(codePoint));
             break;
@@ -357,6 +355,29 @@ export class Parser {
     return value;
   }
 
+  /**
+   * Complete a `\u` escape whose value is a UTF-16 surrogate: it must be a
+   * high surrogate followed directly by a `\u` low surrogate, as in JSON.
+   */
+  private parseSurrogatePair(hi: number): number {
+    const hex = (v: number) => v.toString(16).padStart(4, '0').toUpperCase();
+    if (hi >= 0xdc00) {
+      throw this.syntaxErr(`unpaired low surrogate U+${hex(hi)}`);
+    }
+    if (this.current() !== '\\' || this.peek(1) !== 'u') {
+      throw this.syntaxErr(`high surrogate U+${hex(hi)} must be followed by a \\u low surrogate`);
+    }
+    this.advance();
+    this.advance();
+    const lo = this.parseHexDigits(4, '\\u');
+    if (lo < 0xdc00 || lo > 0xdfff) {
+      throw this.syntaxErr(
+        `high surrogate U+${hex(hi)} must be followed by a \\u low surrogate, not U+${hex(lo)}`
+      );
+    }
+    return 0x10000 + ((hi - 0xd800) << 10) + (lo - 0xdc00);
+  }
+
   private parseRawString(): { value: string; hashCount: number } {
     // current() is 'r' or 'R'
     this.advance();